@@ -1,13 +1,135 @@
 package cmd
 
 import (
+	"bufio"
 	"fmt"
 	"log"
+	"strings"
+	"time"
 
+	"github.com/laetho/slingboard/internal/commands"
 	sc "github.com/laetho/slingboard/internal/slingclient"
 	"github.com/spf13/cobra"
 )
 
+// retentionFlags holds the board retention flags shared by board create and
+// board config.
+type retentionFlags struct {
+	retention   string
+	maxAge      string
+	maxMessages int64
+	maxBytes    int64
+	storage     string
+}
+
+func (f *retentionFlags) register(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&f.retention, "retention", "", "Retention policy: limits or interest")
+	cmd.Flags().StringVar(&f.maxAge, "max-age", "", "Maximum sling age, e.g. 72h (0s keeps slings forever)")
+	cmd.Flags().Int64Var(&f.maxMessages, "max-messages", 0, "Maximum number of stored messages (-1 for no limit)")
+	cmd.Flags().Int64Var(&f.maxBytes, "max-bytes", 0, "Maximum stored bytes (-1 for no limit)")
+	cmd.Flags().StringVar(&f.storage, "storage", "", "Storage type: file or memory")
+}
+
+// request returns the retention to send, or nil when no flag was given.
+func (f *retentionFlags) request(cmd *cobra.Command) *commands.BoardRetention {
+	changed := false
+	for _, name := range []string{"retention", "max-age", "max-messages", "max-bytes", "storage"} {
+		changed = changed || cmd.Flags().Changed(name)
+	}
+	if !changed {
+		return nil
+	}
+
+	return &commands.BoardRetention{
+		Retention:   f.retention,
+		MaxAge:      f.maxAge,
+		MaxMessages: f.maxMessages,
+		MaxBytes:    f.maxBytes,
+		Storage:     f.storage,
+	}
+}
+
+func printRetention(cmd *cobra.Command, retention *commands.BoardRetention) {
+	if retention == nil {
+		return
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "  Retention    : %s\n", retention.Retention)
+	fmt.Fprintf(cmd.OutOrStdout(), "  Max age      : %s\n", retention.MaxAge)
+	fmt.Fprintf(cmd.OutOrStdout(), "  Max messages : %d\n", retention.MaxMessages)
+	fmt.Fprintf(cmd.OutOrStdout(), "  Max bytes    : %d\n", retention.MaxBytes)
+	fmt.Fprintf(cmd.OutOrStdout(), "  Storage      : %s\n", retention.Storage)
+}
+
+// metadataFlags holds the board metadata flags shared by board create and
+// board update.
+type metadataFlags struct {
+	title       string
+	description string
+	owner       string
+	tags        []string
+	codeStyle   string
+}
+
+func (f *metadataFlags) register(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&f.title, "title", "", "Display title of the board")
+	cmd.Flags().StringVar(&f.description, "description", "", "Short description of the board")
+	cmd.Flags().StringVar(&f.owner, "owner", "", "Owner of the board")
+	cmd.Flags().StringSliceVar(&f.tags, "tag", nil, "Board tag, repeat or comma-separate for several (replaces existing tags)")
+	cmd.Flags().StringVar(&f.codeStyle, "code-style", "", "Chroma style for highlighted code on the board (e.g. monokai, github, dracula)")
+}
+
+// request returns the metadata to send, or nil when no flag was given.
+func (f *metadataFlags) request(cmd *cobra.Command) *commands.BoardMetadata {
+	changed := false
+	for _, name := range []string{"title", "description", "owner", "tag", "code-style"} {
+		changed = changed || cmd.Flags().Changed(name)
+	}
+	if !changed {
+		return nil
+	}
+
+	metadata := &commands.BoardMetadata{
+		Title:       f.title,
+		Description: f.description,
+		Owner:       f.owner,
+		CodeStyle:   f.codeStyle,
+	}
+	if cmd.Flags().Changed("tag") {
+		metadata.Tags = append([]string{}, f.tags...)
+	}
+	return metadata
+}
+
+func printMetadata(cmd *cobra.Command, metadata *commands.BoardMetadata) {
+	if metadata == nil {
+		return
+	}
+	if metadata.Title != "" {
+		fmt.Fprintf(cmd.OutOrStdout(), "  Title        : %s\n", metadata.Title)
+	}
+	if metadata.Description != "" {
+		fmt.Fprintf(cmd.OutOrStdout(), "  Description  : %s\n", metadata.Description)
+	}
+	if metadata.Owner != "" {
+		fmt.Fprintf(cmd.OutOrStdout(), "  Owner        : %s\n", metadata.Owner)
+	}
+	if !metadata.CreatedAt.IsZero() {
+		fmt.Fprintf(cmd.OutOrStdout(), "  Created      : %s\n", metadata.CreatedAt.Local().Format(time.RFC1123))
+	}
+	if len(metadata.Tags) > 0 {
+		fmt.Fprintf(cmd.OutOrStdout(), "  Tags         : %s\n", strings.Join(metadata.Tags, ", "))
+	}
+	if metadata.CodeStyle != "" {
+		fmt.Fprintf(cmd.OutOrStdout(), "  Code style   : %s\n", metadata.CodeStyle)
+	}
+}
+
+var createRetention retentionFlags
+var configRetention retentionFlags
+var createMetadata metadataFlags
+var updateMetadata metadataFlags
+var deleteForce bool
+
 var boardCmd = &cobra.Command{
 	Use:   "board",
 	Short: "Manage slingBoards",
@@ -22,8 +144,24 @@ var boardListCmd = &cobra.Command{
 		if err != nil {
 			log.Fatalf("Unable to list slingBoards: %v", err)
 		}
-		for _, board := range response.Boards {
-			fmt.Fprintln(cmd.OutOrStdout(), board)
+		if len(response.Metadata) == 0 {
+			for _, board := range response.Boards {
+				fmt.Fprintln(cmd.OutOrStdout(), board)
+			}
+			return
+		}
+		for _, metadata := range response.Metadata {
+			line := metadata.Name
+			if metadata.Title != "" && metadata.Title != metadata.Name {
+				line += " - " + metadata.Title
+			}
+			if metadata.Owner != "" {
+				line += " (" + metadata.Owner + ")"
+			}
+			if len(metadata.Tags) > 0 {
+				line += " [" + strings.Join(metadata.Tags, ", ") + "]"
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), line)
 		}
 	},
 }
@@ -35,18 +173,95 @@ var boardCreateCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		board := requireBoard(args[0])
 		client := sc.NewClient(apiURL)
-		response, err := client.BoardCreate(board)
+		response, err := client.BoardCreate(board,
+			sc.WithRetention(createRetention.request(cmd)),
+			sc.WithMetadata(createMetadata.request(cmd)),
+		)
 		if err != nil {
 			log.Fatalf("Unable to create slingBoard: %v", err)
 		}
 		if response.Board != "" {
 			fmt.Fprintf(cmd.OutOrStdout(), "Created slingBoard: %s\n", response.Board)
+			printMetadata(cmd, response.Metadata)
+			printRetention(cmd, response.Retention)
 		}
 	},
 }
 
+var boardConfigCmd = &cobra.Command{
+	Use:   "config <name>",
+	Short: "Show or change the retention of a slingBoard",
+	Long:  "Show the retention of a slingBoard, or change it when any retention flag is given. The storage type cannot be changed once a board exists.",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		board := requireBoard(args[0])
+		client := sc.NewClient(apiURL)
+		response, err := client.BoardConfig(board, configRetention.request(cmd))
+		if err != nil {
+			log.Fatalf("Unable to configure slingBoard: %v", err)
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "slingBoard: %s\n", response.Board)
+		printRetention(cmd, response.Retention)
+	},
+}
+
+var boardUpdateCmd = &cobra.Command{
+	Use:   "update <name>",
+	Short: "Change the title, description, owner or tags of a slingBoard",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		board := requireBoard(args[0])
+		client := sc.NewClient(apiURL)
+		response, err := client.BoardUpdate(board, updateMetadata.request(cmd))
+		if err != nil {
+			log.Fatalf("Unable to update slingBoard: %v", err)
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Updated slingBoard: %s\n", response.Board)
+		printMetadata(cmd, response.Metadata)
+	},
+}
+
+var boardDeleteCmd = &cobra.Command{
+	Use:   "delete <name>",
+	Short: "Delete a slingBoard",
+	Long:  "Delete a slingBoard with all its slings and pins. Open screens are told the board is gone. Asks for confirmation unless --force is given.",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		board := requireBoard(args[0])
+		if !deleteForce && !confirmBoardDelete(cmd, board) {
+			fmt.Fprintln(cmd.OutOrStdout(), "Aborted")
+			return
+		}
+
+		client := sc.NewClient(apiURL)
+		response, err := client.BoardDelete(board)
+		if err != nil {
+			log.Fatalf("Unable to delete slingBoard: %v", err)
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Deleted slingBoard: %s\n", response.Board)
+	},
+}
+
+// confirmBoardDelete asks the user to type the board name before deleting it.
+func confirmBoardDelete(cmd *cobra.Command, board string) bool {
+	fmt.Fprintf(cmd.OutOrStdout(), "This deletes slingBoard %s and all its slings. Type the board name to confirm: ", board)
+	answer, err := bufio.NewReader(cmd.InOrStdin()).ReadString('\n')
+	if err != nil && answer == "" {
+		return false
+	}
+	return strings.TrimSpace(answer) == board
+}
+
 func init() {
 	boardCmd.AddCommand(boardListCmd)
 	boardCmd.AddCommand(boardCreateCmd)
+	boardCmd.AddCommand(boardConfigCmd)
+	boardCmd.AddCommand(boardUpdateCmd)
+	boardCmd.AddCommand(boardDeleteCmd)
+	createMetadata.register(boardCreateCmd)
+	updateMetadata.register(boardUpdateCmd)
+	boardDeleteCmd.Flags().BoolVar(&deleteForce, "force", false, "Delete without asking for confirmation")
+	createRetention.register(boardCreateCmd)
+	configRetention.register(boardConfigCmd)
 	rootCmd.AddCommand(boardCmd)
 }
@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+
+	sc "github.com/laetho/slingboard/internal/slingclient"
+	"github.com/spf13/cobra"
+)
+
+var deleteBoard string
+
+var slingDelete = &cobra.Command{
+	Use:   "delete <id>",
+	Short: "Delete a sling",
+	Long:  "Delete a sling from the board and remove it from every connected screen.",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		board := requireBoard(deleteBoard)
+
+		client := sc.NewClient(apiURL)
+		response, err := client.SlingDelete(board, args[0])
+		if err != nil {
+			log.Fatalf("Unable to delete sling: %v", err)
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Deleted sling %s from %s\n", response.ID, response.Board)
+	},
+}
+
+func init() {
+	slingDelete.Flags().StringVarP(&deleteBoard, "board", "b", "", "Board name (required)")
+	rootCmd.AddCommand(slingDelete)
+}
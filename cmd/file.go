@@ -1,13 +1,25 @@
 package cmd
 
 import (
+	"fmt"
 	"log"
+	"path/filepath"
+	"time"
 
 	sc "github.com/laetho/slingboard/internal/slingclient"
 	"github.com/spf13/cobra"
 )
 
 var fileBoard string
+var fileTTL time.Duration
+var fileLanguage string
+var fileLines string
+var fileHighlight string
+var fileTitle string
+var fileAutoplay bool
+var fileMuted bool
+var fileLoop bool
+var fileSort string
 
 var slingFile = &cobra.Command{
 	Use:   "file <filename>",
@@ -21,13 +33,44 @@ var slingFile = &cobra.Command{
 		board := requireBoard(fileBoard)
 
 		client := sc.NewClient(apiURL)
-		if err := client.SendFile(board, filename); err != nil {
+		client.OnProgress(func(sent int64, total int64) {
+			fmt.Fprintf(cmd.ErrOrStderr(), "\rUploading %s: %d%%", filepath.Base(filename), sent*100/total)
+			if sent == total {
+				fmt.Fprintln(cmd.ErrOrStderr())
+			}
+		})
+		opts := []sc.SendOption{
+			sc.WithTTL(fileTTL),
+			sc.WithExcerpt(fileLines, fileHighlight),
+			sc.WithPlayback(fileAutoplay, fileMuted, fileLoop),
+		}
+		if fileLanguage != "" {
+			opts = append(opts, sc.WithLanguage(fileLanguage))
+		}
+		if fileTitle != "" {
+			opts = append(opts, sc.WithTitle(fileTitle))
+		}
+		if fileSort != "" {
+			opts = append(opts, sc.WithSortBy(fileSort))
+		}
+		response, err := client.SendFile(board, filename, opts...)
+		if err != nil {
 			log.Fatalf("Unable to send message: %v", err)
 		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Slung %s to %s\n", response.ID, response.Board)
 	},
 }
 
 func init() {
 	slingFile.Flags().StringVarP(&fileBoard, "board", "b", "", "Board name (required)")
+	slingFile.Flags().DurationVar(&fileTTL, "ttl", 0, "Remove the sling after this duration (e.g. 15m)")
+	slingFile.Flags().StringVar(&fileLanguage, "lang", "", "Highlight the file as this language (e.g. python, yaml, terraform)")
+	slingFile.Flags().StringVar(&fileLines, "lines", "", "Only show this line range of the file (e.g. 40-80)")
+	slingFile.Flags().StringVar(&fileHighlight, "highlight", "", "Highlight these lines (e.g. 57 or 57,60-62)")
+	slingFile.Flags().StringVar(&fileTitle, "title", "", "Header shown above the code instead of the filename")
+	slingFile.Flags().BoolVar(&fileAutoplay, "autoplay", false, "Start video and audio as soon as the board shows it (use with --mute)")
+	slingFile.Flags().BoolVar(&fileMuted, "mute", false, "Start video and audio muted")
+	slingFile.Flags().BoolVar(&fileLoop, "loop", false, "Play video and audio in a loop")
+	slingFile.Flags().StringVar(&fileSort, "sort", "", "Sort a CSV or TSV file by this column name or number, prefix with - for descending")
 	rootCmd.AddCommand(slingFile)
 }
@@ -1,14 +1,18 @@
 package cmd
 
 import (
+	"fmt"
 	"log"
 	"strings"
+	"time"
 
 	sc "github.com/laetho/slingboard/internal/slingclient"
 	"github.com/spf13/cobra"
 )
 
 var messageBoard string
+var messageTTL time.Duration
+var messageReplyTo string
 
 var slingMessage = &cobra.Command{
 	Use:   "message <string>",
@@ -21,9 +25,15 @@ var slingMessage = &cobra.Command{
 		message := strings.Join(args, " ")
 		board := requireBoard(messageBoard)
 		client := sc.NewClient(apiURL)
-		if err := client.SendText(board, message); err != nil {
+		opts := []sc.SendOption{sc.WithTTL(messageTTL)}
+		if messageReplyTo != "" {
+			opts = append(opts, sc.WithReplyTo(messageReplyTo))
+		}
+		response, err := client.SendText(board, message, opts...)
+		if err != nil {
 			log.Fatalf("Unable to send message: %v", err)
 		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Slung %s to %s\n", response.ID, response.Board)
 	},
 }
 
@@ -48,5 +58,7 @@ func requireBoard(board string) string {
 
 func init() {
 	slingMessage.Flags().StringVarP(&messageBoard, "board", "b", "", "Board name (required)")
+	slingMessage.Flags().DurationVar(&messageTTL, "ttl", 0, "Remove the sling after this duration (e.g. 15m)")
+	slingMessage.Flags().StringVar(&messageReplyTo, "reply-to", "", "Post the message as a reply to the sling with this ID")
 	rootCmd.AddCommand(slingMessage)
 }
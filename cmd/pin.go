@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+
+	sc "github.com/laetho/slingboard/internal/slingclient"
+	"github.com/spf13/cobra"
+)
+
+var pinBoard string
+
+var slingPin = &cobra.Command{
+	Use:   "pin <id>",
+	Short: "Pin a sling to the top of the board",
+	Long:  "Pin a sling so it stays above the stream of slings on every screen, including after reloads.",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		board := requireBoard(pinBoard)
+
+		client := sc.NewClient(apiURL)
+		response, err := client.SlingPin(board, args[0])
+		if err != nil {
+			log.Fatalf("Unable to pin sling: %v", err)
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Pinned sling %s on %s\n", response.ID, response.Board)
+	},
+}
+
+var slingUnpin = &cobra.Command{
+	Use:   "unpin <id>",
+	Short: "Unpin a sling",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		board := requireBoard(pinBoard)
+
+		client := sc.NewClient(apiURL)
+		response, err := client.SlingUnpin(board, args[0])
+		if err != nil {
+			log.Fatalf("Unable to unpin sling: %v", err)
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Unpinned sling %s on %s\n", response.ID, response.Board)
+	},
+}
+
+func init() {
+	slingPin.Flags().StringVarP(&pinBoard, "board", "b", "", "Board name (required)")
+	slingUnpin.Flags().StringVarP(&pinBoard, "board", "b", "", "Board name (required)")
+	rootCmd.AddCommand(slingPin, slingUnpin)
+}
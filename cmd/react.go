@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os/user"
+
+	sc "github.com/laetho/slingboard/internal/slingclient"
+	"github.com/spf13/cobra"
+)
+
+var (
+	reactBoard  string
+	reactAuthor string
+)
+
+var slingReact = &cobra.Command{
+	Use:   "react <id> <emoji>",
+	Short: "React to a sling with an emoji",
+	Long:  "Add an emoji reaction to a sling. Reaction counts update live on every connected screen.",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		board := requireBoard(reactBoard)
+
+		client := sc.NewClient(apiURL)
+		response, err := client.SlingReact(board, args[0], args[1], reactAuthor)
+		if err != nil {
+			log.Fatalf("Unable to react to sling: %v", err)
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Reacted %s to sling %s on %s\n", args[1], response.ID, response.Board)
+	},
+}
+
+var slingUnreact = &cobra.Command{
+	Use:   "unreact <id> <emoji>",
+	Short: "Take back your emoji reaction to a sling",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		board := requireBoard(reactBoard)
+
+		client := sc.NewClient(apiURL)
+		response, err := client.SlingUnreact(board, args[0], args[1], reactAuthor)
+		if err != nil {
+			log.Fatalf("Unable to remove reaction: %v", err)
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Removed %s from sling %s on %s\n", args[1], response.ID, response.Board)
+	},
+}
+
+func init() {
+	author := ""
+	if current, err := user.Current(); err == nil {
+		author = current.Username
+	}
+	for _, command := range []*cobra.Command{slingReact, slingUnreact} {
+		command.Flags().StringVarP(&reactBoard, "board", "b", "", "Board name (required)")
+		command.Flags().StringVarP(&reactAuthor, "author", "a", author, "Name the reaction is recorded under")
+	}
+	rootCmd.AddCommand(slingReact, slingUnreact)
+}
@@ -12,6 +12,11 @@ import (
 var serveNatsURL string
 var serveNatsCreds string
 var serveFQDN string
+var serveBoardRetention string
+var serveBoardMaxAge string
+var serveBoardMaxMessages int64
+var serveBoardMaxBytes int64
+var serveBoardStorage string
 
 var serveCmd = &cobra.Command{
 	Use:   "serve",
@@ -26,6 +31,21 @@ var serveCmd = &cobra.Command{
 		if serveFQDN != "" {
 			viper.Set("fqdn", serveFQDN)
 		}
+		if serveBoardRetention != "" {
+			viper.Set("board_retention", serveBoardRetention)
+		}
+		if serveBoardMaxAge != "" {
+			viper.Set("board_max_age", serveBoardMaxAge)
+		}
+		if serveBoardMaxMessages != 0 {
+			viper.Set("board_max_messages", serveBoardMaxMessages)
+		}
+		if serveBoardMaxBytes != 0 {
+			viper.Set("board_max_bytes", serveBoardMaxBytes)
+		}
+		if serveBoardStorage != "" {
+			viper.Set("board_storage", serveBoardStorage)
+		}
 		fmt.Println("Starting Sling Board server...")
 		server.Start()
 	},
@@ -36,5 +56,10 @@ func init() {
 	serveCmd.Flags().StringVar(&serveNatsCreds, "nats-creds", "", "NATS credentials file")
 	serveCmd.Flags().StringVar(&serveNatsCreds, "nats-credentials", "", "NATS credentials file")
 	serveCmd.Flags().StringVar(&serveFQDN, "fqdn", "", "FQDN for h8s subjects")
+	serveCmd.Flags().StringVar(&serveBoardRetention, "board-retention", "", "Default retention policy for new boards: limits or interest")
+	serveCmd.Flags().StringVar(&serveBoardMaxAge, "board-max-age", "", "Default maximum sling age for new boards (default 24h)")
+	serveCmd.Flags().Int64Var(&serveBoardMaxMessages, "board-max-messages", 0, "Default maximum number of messages for new boards")
+	serveCmd.Flags().Int64Var(&serveBoardMaxBytes, "board-max-bytes", 0, "Default maximum stored bytes for new boards")
+	serveCmd.Flags().StringVar(&serveBoardStorage, "board-storage", "", "Default storage type for new boards: file or memory")
 	rootCmd.AddCommand(serveCmd)
 }
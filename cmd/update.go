@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	sc "github.com/laetho/slingboard/internal/slingclient"
+	"github.com/spf13/cobra"
+)
+
+var updateBoard string
+var updateFile string
+
+var slingUpdate = &cobra.Command{
+	Use:   "update <id> [message]",
+	Short: "Edit a text or markdown sling",
+	Long:  "Replace the content of a published text or markdown sling. The card is updated in place on every connected screen.",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		board := requireBoard(updateBoard)
+
+		content := strings.Join(args[1:], " ")
+		if updateFile != "" {
+			data, err := os.ReadFile(updateFile)
+			if err != nil {
+				log.Fatalf("Unable to read file: %v", err)
+			}
+			content = string(data)
+		}
+		if content == "" {
+			log.Fatalf("No message provided")
+		}
+
+		client := sc.NewClient(apiURL)
+		response, err := client.SlingUpdate(board, args[0], content)
+		if err != nil {
+			log.Fatalf("Unable to update sling: %v", err)
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Updated sling %s on %s\n", response.ID, response.Board)
+	},
+}
+
+func init() {
+	slingUpdate.Flags().StringVarP(&updateBoard, "board", "b", "", "Board name (required)")
+	slingUpdate.Flags().StringVarP(&updateFile, "file", "f", "", "Read the new content from a file")
+	rootCmd.AddCommand(slingUpdate)
+}
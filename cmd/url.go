@@ -1,13 +1,16 @@
 package cmd
 
 import (
+	"fmt"
 	"log"
+	"time"
 
 	sc "github.com/laetho/slingboard/internal/slingclient"
 	"github.com/spf13/cobra"
 )
 
 var urlBoard string
+var urlTTL time.Duration
 
 var slingUrl = &cobra.Command{
 	Use:   "url <string>",
@@ -21,13 +24,16 @@ var slingUrl = &cobra.Command{
 		board := requireBoard(urlBoard)
 
 		client := sc.NewClient(apiURL)
-		if err := client.SendURL(board, url); err != nil {
+		response, err := client.SendURL(board, url, sc.WithTTL(urlTTL))
+		if err != nil {
 			log.Fatalf("Unable to send message: %v", err)
 		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Slung %s to %s\n", response.ID, response.Board)
 	},
 }
 
 func init() {
 	slingUrl.Flags().StringVarP(&urlBoard, "board", "b", "", "Board name (required)")
+	slingUrl.Flags().DurationVar(&urlTTL, "ttl", 0, "Remove the sling after this duration (e.g. 15m)")
 	rootCmd.AddCommand(slingUrl)
 }
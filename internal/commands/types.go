@@ -5,27 +5,85 @@ import "time"
 type CommandType string
 
 const (
-	CommandText        CommandType = "text"
-	CommandURL         CommandType = "url"
-	CommandFile        CommandType = "file"
-	CommandBoardList   CommandType = "board.list"
-	CommandBoardCreate CommandType = "board.create"
+	CommandText         CommandType = "text"
+	CommandURL          CommandType = "url"
+	CommandFile         CommandType = "file"
+	CommandBoardList    CommandType = "board.list"
+	CommandBoardCreate  CommandType = "board.create"
+	CommandBoardConfig  CommandType = "board.config"
+	CommandBoardDelete  CommandType = "board.delete"
+	CommandBoardUpdate  CommandType = "board.update"
+	CommandSlingDelete  CommandType = "sling.delete"
+	CommandSlingUpdate  CommandType = "sling.update"
+	CommandSlingPin     CommandType = "sling.pin"
+	CommandSlingUnpin   CommandType = "sling.unpin"
+	CommandSlingReact   CommandType = "sling.react"
+	CommandSlingUnreact CommandType = "sling.unreact"
+
+	// Files too large for a single command are uploaded in chunks: start
+	// announces the file and returns an upload ID, append adds the chunk at
+	// an offset and commit slings the assembled file.
+	CommandUploadStart  CommandType = "upload.start"
+	CommandUploadAppend CommandType = "upload.append"
+	CommandUploadCommit CommandType = "upload.commit"
 )
 
 type CommandRequest struct {
-	Type     CommandType `json:"type"`
-	Board    string      `json:"board,omitempty"`
-	Author   string      `json:"author,omitempty"`
-	Content  string      `json:"content"`
-	MimeType string      `json:"mime_type,omitempty"`
-	Filename string      `json:"filename,omitempty"`
+	Type      CommandType     `json:"type"`
+	Board     string          `json:"board,omitempty"`
+	Author    string          `json:"author,omitempty"`
+	Content   string          `json:"content"`
+	MimeType  string          `json:"mime_type,omitempty"`
+	Filename  string          `json:"filename,omitempty"`
+	ID        string          `json:"id,omitempty"`
+	TTL       string          `json:"ttl,omitempty"`
+	Reaction  string          `json:"reaction,omitempty"`
+	ParentID  string          `json:"parent_id,omitempty"`
+	Language  string          `json:"language,omitempty"`
+	Lines     string          `json:"lines,omitempty"`
+	Highlight string          `json:"highlight,omitempty"`
+	Title     string          `json:"title,omitempty"`
+	Autoplay  bool            `json:"autoplay,omitempty"`
+	Muted     bool            `json:"muted,omitempty"`
+	Loop      bool            `json:"loop,omitempty"`
+	SortBy    string          `json:"sort_by,omitempty"`
+	Size      int64           `json:"size,omitempty"`
+	Offset    int64           `json:"offset,omitempty"`
+	Retention *BoardRetention `json:"retention,omitempty"`
+	Metadata  *BoardMetadata  `json:"metadata,omitempty"`
+}
+
+// BoardMetadata describes a board for people browsing the board list. Empty
+// fields leave the stored value unchanged on create and update.
+type BoardMetadata struct {
+	Name        string    `json:"name,omitempty"`
+	Title       string    `json:"title,omitempty"`
+	Description string    `json:"description,omitempty"`
+	Owner       string    `json:"owner,omitempty"`
+	CreatedAt   time.Time `json:"created_at,omitzero"`
+	Tags        []string  `json:"tags,omitempty"`
+	CodeStyle   string    `json:"code_style,omitempty"`
+}
+
+// BoardRetention describes how long a board keeps its slings. Zero values
+// leave a setting unchanged; -1 removes the message or byte limit.
+type BoardRetention struct {
+	Retention   string `json:"retention,omitempty"` // "limits" or "interest"
+	MaxAge      string `json:"max_age,omitempty"`   // Go duration, "0s" keeps slings forever
+	MaxMessages int64  `json:"max_messages,omitempty"`
+	MaxBytes    int64  `json:"max_bytes,omitempty"`
+	Storage     string `json:"storage,omitempty"` // "file" or "memory"
 }
 
 type CommandResponse struct {
-	ID        string    `json:"id,omitempty"`
-	Status    string    `json:"status"`
-	Message   string    `json:"message,omitempty"`
-	Board     string    `json:"board,omitempty"`
-	Boards    []string  `json:"boards,omitempty"`
-	Timestamp time.Time `json:"timestamp,omitempty"`
+	ID            string          `json:"id,omitempty"`
+	Status        string          `json:"status"`
+	Message       string          `json:"message,omitempty"`
+	Board         string          `json:"board,omitempty"`
+	Boards        []string        `json:"boards,omitempty"`
+	BoardMetadata []BoardMetadata `json:"board_metadata,omitempty"`
+	Timestamp     time.Time       `json:"timestamp,omitempty"`
+	Retention     *BoardRetention `json:"retention,omitempty"`
+	Metadata      *BoardMetadata  `json:"metadata,omitempty"`
+	Offset        int64           `json:"offset,omitempty"` // bytes of a chunked upload received so far
 }
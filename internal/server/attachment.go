@@ -0,0 +1,45 @@
+package server
+
+import (
+	"fmt"
+
+	"github.com/laetho/slingboard/internal/slingmessage"
+	"github.com/laetho/slingboard/templates"
+)
+
+// attachmentView prepares the card of a file sling the board has no card
+// for, or that is too large to render, with a link to its stored object.
+func attachmentView(board string, sling *slingmessage.SlingMessage) templates.Attachment {
+	filename := sling.Filename
+	if filename == "" {
+		filename = "attachment"
+	}
+	mimeType := sling.MimeType
+	if mimeType == "" {
+		mimeType = defaultObjectMime
+	}
+	size := sling.Size
+	if sling.ObjectID == "" {
+		size = int64(len(sling.Content))
+	}
+	return templates.Attachment{
+		Filename: filename,
+		MimeType: mimeType,
+		Size:     formatSize(size),
+		URL:      objectSource(board, sling),
+	}
+}
+
+// formatSize returns a byte count the way file managers show it.
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	value, exponent := float64(size)/unit, 0
+	for value >= unit && exponent < 4 {
+		value /= unit
+		exponent++
+	}
+	return fmt.Sprintf("%.1f %cB", value, "KMGTP"[exponent])
+}
@@ -0,0 +1,111 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/yuin/goldmark/util"
+)
+
+const (
+	dotCommand = "dot"
+	dotTimeout = 5 * time.Second
+
+	// maxDotSource and maxDotSVG keep a single fence from holding up
+	// rendering or bloating the sling.
+	maxDotSource = 64 << 10
+	maxDotSVG    = 512 << 10
+
+	maxDiagramCache = 256
+)
+
+var errDotTooLarge = errors.New("graph is too large to render")
+
+// dotCache holds rendered graphs by source digest. Every open screen renders
+// a sling again, and rendering runs an external process.
+var dotCache = struct {
+	sync.Mutex
+	svgs map[[sha256.Size]byte][]byte
+}{svgs: make(map[[sha256.Size]byte][]byte)}
+
+// renderDot renders Graphviz dot source to SVG with the dot command.
+func renderDot(source []byte) ([]byte, error) {
+	if len(source) > maxDotSource {
+		return nil, errDotTooLarge
+	}
+	key := sha256.Sum256(source)
+	dotCache.Lock()
+	svg, ok := dotCache.svgs[key]
+	dotCache.Unlock()
+	if ok {
+		return svg, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), dotTimeout)
+	defer cancel()
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, dotCommand, "-Tsvg")
+	cmd.Stdin = bytes.NewReader(source)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if message := bytes.TrimSpace(stderr.Bytes()); len(message) > 0 {
+			return nil, errors.New(string(message))
+		}
+		return nil, err
+	}
+	if stdout.Len() > maxDotSVG {
+		return nil, errDotTooLarge
+	}
+
+	svg = stdout.Bytes()
+	dotCache.Lock()
+	if len(dotCache.svgs) >= maxDiagramCache {
+		clear(dotCache.svgs)
+	}
+	dotCache.svgs[key] = svg
+	dotCache.Unlock()
+	return svg, nil
+}
+
+// writeDotDiagram writes a dot fence as its rendered graph. The SVG is shown
+// as an image so links and scripts in it stay inert. Graphs that fail to
+// render show the error above their source.
+func writeDotDiagram(w util.BufWriter, source []byte) {
+	svg, err := renderDot(source)
+	_, _ = w.WriteString(`<figure class="md-diagram md-diagram--dot">`)
+	if err != nil {
+		_, _ = w.WriteString(`<p class="md-diagram__error">Graphviz: `)
+		_, _ = w.Write(util.EscapeHTML([]byte(err.Error())))
+		_, _ = w.WriteString(`</p>`)
+	} else {
+		_, _ = w.WriteString(`<img class="md-diagram__image" alt="Graphviz diagram" src="data:image/svg+xml;base64,`)
+		_, _ = w.WriteString(base64.StdEncoding.EncodeToString(svg))
+		_, _ = w.WriteString(`">`)
+	}
+	writeDiagramSource(w, source)
+	_, _ = w.WriteString("</figure>\n")
+}
+
+// writeMermaidDiagram writes a mermaid fence for the mermaid script of the
+// board page, which replaces the text of the element with the diagram.
+func writeMermaidDiagram(w util.BufWriter, source []byte) {
+	_, _ = w.WriteString(`<figure class="md-diagram md-diagram--mermaid"><pre class="mermaid">`)
+	_, _ = w.Write(util.EscapeHTML(source))
+	_, _ = w.WriteString(`</pre>`)
+	writeDiagramSource(w, source)
+	_, _ = w.WriteString("</figure>\n")
+}
+
+// writeDiagramSource writes the source of a diagram, shown when hovering it.
+func writeDiagramSource(w util.BufWriter, source []byte) {
+	_, _ = w.WriteString(`<pre class="md-diagram__source"><code>`)
+	_, _ = w.Write(util.EscapeHTML(source))
+	_, _ = w.WriteString(`</code></pre>`)
+}
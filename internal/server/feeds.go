@@ -0,0 +1,330 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/laetho/slingboard/internal/slingmessage"
+	"github.com/laetho/slingboard/templates"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nuid"
+)
+
+// boardFeed delivers the slings of a board to every websocket this instance
+// serves for it. Each board has one consumer per instance, however many
+// screens show it, and every sling is rendered once and sent to all of them.
+type boardFeed struct {
+	board        string
+	streamName   string
+	consumerName string
+	stop         chan struct{}
+
+	// ready is closed once the feed's consumer runs, or err is set when it
+	// could not be started.
+	ready chan struct{}
+	err   error
+
+	// mu is held while slings are sent, so a screen joining the feed gets
+	// the end of its replay before any newer sling. lastSeq is the last
+	// stream sequence sent.
+	mu      sync.Mutex
+	lastSeq uint64
+}
+
+// startWebsocket adds a websocket to the feed of its board, starting the
+// feed for the first screen. The slings published after since, the last
+// sequence the page was rendered with, are replayed to it before it gets
+// live slings; with no since, the latest slings are.
+func (s *service) startWebsocket(board string, reply string, since uint64) {
+	if reply == "" {
+		return
+	}
+
+	streamName, err := s.ensureBoardStream(board)
+	if err != nil {
+		log.Printf("Failed to ensure board stream: %v", err)
+		return
+	}
+
+	for {
+		feed, err := s.boardFeed(board, streamName)
+		if err != nil {
+			log.Printf("Failed to start board feed: %v", err)
+			return
+		}
+
+		s.feedMu.Lock()
+		if s.feeds[board] != feed {
+			// The last screen on the board left and stopped the feed.
+			s.feedMu.Unlock()
+			continue
+		}
+		s.wsMu.Lock()
+		_, exists := s.wsConns[reply]
+		if !exists {
+			s.wsConns[reply] = &wsConnection{board: board, reply: reply, replaying: true}
+		}
+		s.wsMu.Unlock()
+		s.feedMu.Unlock()
+		if !exists {
+			s.replayBoardFeed(feed, reply, since)
+		}
+		return
+	}
+}
+
+// stopWebsocket removes a websocket and stops the feed of its board when it
+// was the last screen on it. A feed still starting is left alone, since the
+// screen it starts for joins it next.
+func (s *service) stopWebsocket(reply string) {
+	s.wsMu.Lock()
+	conn, ok := s.wsConns[reply]
+	if ok {
+		delete(s.wsConns, reply)
+	}
+	s.wsMu.Unlock()
+	if !ok {
+		return
+	}
+
+	s.feedMu.Lock()
+	feed, ok := s.feeds[conn.board]
+	if !ok || !feed.started() || len(s.websocketReplies(conn.board)) > 0 {
+		s.feedMu.Unlock()
+		return
+	}
+	delete(s.feeds, conn.board)
+	s.feedMu.Unlock()
+
+	close(feed.stop)
+	_ = s.js.DeleteConsumer(feed.streamName, feed.consumerName)
+}
+
+// feedSeq returns the last stream sequence the feed of a board has sent,
+// or 0 without a feed.
+func (s *service) feedSeq(board string) uint64 {
+	s.feedMu.Lock()
+	feed, ok := s.feeds[board]
+	s.feedMu.Unlock()
+	if !ok {
+		return 0
+	}
+	feed.mu.Lock()
+	defer feed.mu.Unlock()
+	return feed.lastSeq
+}
+
+// boardFeed returns the running feed of a board or starts one. The feed is
+// started outside feedMu, which only guards the map, so opening and closing
+// websockets never waits on JetStream; callers asking for a feed that is
+// starting wait for it.
+func (s *service) boardFeed(board string, streamName string) (*boardFeed, error) {
+	s.feedMu.Lock()
+	feed, ok := s.feeds[board]
+	if !ok {
+		feed = &boardFeed{
+			board:        board,
+			streamName:   streamName,
+			consumerName: s.websocketConsumerName(nuid.Next()),
+			stop:         make(chan struct{}),
+			ready:        make(chan struct{}),
+		}
+		s.feeds[board] = feed
+	}
+	s.feedMu.Unlock()
+
+	if !ok {
+		feed.err = s.openBoardFeed(feed)
+		if feed.err != nil {
+			s.feedMu.Lock()
+			if s.feeds[board] == feed {
+				delete(s.feeds, board)
+			}
+			s.feedMu.Unlock()
+		}
+		close(feed.ready)
+	}
+	<-feed.ready
+	if feed.err != nil {
+		return nil, feed.err
+	}
+	return feed, nil
+}
+
+// started reports whether the feed's consumer runs.
+func (feed *boardFeed) started() bool {
+	select {
+	case <-feed.ready:
+		return feed.err == nil
+	default:
+		return false
+	}
+}
+
+// openBoardFeed creates the consumer of a feed and starts consuming it. The
+// consumer starts after the last sling on the stream, so nothing published
+// while it is created is missed.
+func (s *service) openBoardFeed(feed *boardFeed) error {
+	info, err := s.js.StreamInfo(feed.streamName)
+	if err != nil {
+		return err
+	}
+	feed.mu.Lock()
+	feed.lastSeq = info.State.LastSeq
+	feed.mu.Unlock()
+	_, err = s.js.AddConsumer(feed.streamName, &nats.ConsumerConfig{
+		Durable:       feed.consumerName,
+		AckPolicy:     nats.AckExplicitPolicy,
+		AckWait:       30 * time.Second,
+		FilterSubject: boardSlingsSubject(feed.board),
+		DeliverPolicy: nats.DeliverByStartSequencePolicy,
+		OptStartSeq:   info.State.LastSeq + 1,
+		ReplayPolicy:  nats.ReplayInstantPolicy,
+		// Consumers of an instance that died are removed by the server.
+		InactiveThreshold: 4 * instanceLeaseTTL,
+	})
+	if err != nil {
+		return err
+	}
+
+	sub, err := s.js.PullSubscribe(boardSlingsSubject(feed.board), feed.consumerName, nats.Bind(feed.streamName, feed.consumerName), nats.ManualAck())
+	if err != nil {
+		_ = s.js.DeleteConsumer(feed.streamName, feed.consumerName)
+		return err
+	}
+
+	go s.consumeBoardFeed(feed, sub)
+	return nil
+}
+
+func (s *service) consumeBoardFeed(feed *boardFeed, sub *nats.Subscription) {
+	defer sub.Unsubscribe()
+	for {
+		select {
+		case <-feed.stop:
+			return
+		default:
+			msgs, err := sub.Fetch(1, nats.MaxWait(2*time.Second))
+			if err != nil {
+				if errors.Is(err, nats.ErrTimeout) {
+					continue
+				}
+				if errors.Is(err, nats.ErrConnectionClosed) || errors.Is(err, nats.ErrBadSubscription) || errors.Is(err, nats.ErrConsumerDeleted) {
+					return
+				}
+				log.Printf("Failed to fetch messages: %v", err)
+				continue
+			}
+			for _, msg := range msgs {
+				var seq uint64
+				if meta, err := msg.Metadata(); err == nil {
+					seq = meta.Sequence.Stream
+				}
+				payload, err := s.renderBoardMessage(feed.board, seq, msg.Data)
+				if err != nil {
+					log.Printf("Error rendering sling message: %v", err)
+				}
+
+				feed.mu.Lock()
+				feed.lastSeq = max(feed.lastSeq, seq)
+				if err == nil {
+					for _, reply := range s.feedReplies(feed.board) {
+						if err := s.nc.Publish(reply, payload); err != nil {
+							log.Printf("Error sending websocket reply: %v", err)
+						}
+					}
+				}
+				feed.mu.Unlock()
+				_ = msg.Ack()
+			}
+		}
+	}
+}
+
+// replayBoardFeed sends a screen that just joined the feed the slings it
+// missed, then lets it have live slings. The bulk of the replay is read
+// without holding feed.mu; only the slings the feed passed meanwhile are
+// read under it.
+func (s *service) replayBoardFeed(feed *boardFeed, reply string, since uint64) {
+	feed.mu.Lock()
+	upTo := feed.lastSeq
+	feed.mu.Unlock()
+
+	from := since + 1
+	if since == 0 && upTo > defaultHistoryLimit {
+		from = upTo - defaultHistoryLimit + 1
+	}
+	payloads := s.renderFeedRange(feed, from, upTo)
+
+	feed.mu.Lock()
+	defer feed.mu.Unlock()
+	payloads = append(payloads, s.renderFeedRange(feed, max(from, upTo+1), feed.lastSeq)...)
+	for _, payload := range payloads {
+		if err := s.nc.Publish(reply, payload); err != nil {
+			log.Printf("Error sending websocket reply: %v", err)
+		}
+	}
+	s.wsMu.Lock()
+	if conn, ok := s.wsConns[reply]; ok {
+		conn.replaying = false
+	}
+	s.wsMu.Unlock()
+}
+
+// renderFeedRange renders the slings of a feed's board stored between the
+// stream sequences from and to.
+func (s *service) renderFeedRange(feed *boardFeed, from uint64, to uint64) [][]byte {
+	var payloads [][]byte
+	if from > to {
+		return nil
+	}
+	err := s.eachSubjectSling(feed.streamName, boardSlingsSubject(feed.board), from, func(seq uint64, sling *slingmessage.SlingMessage) bool {
+		if seq > to {
+			return false
+		}
+		payload, err := s.renderFeedSling(feed.board, seq, sling)
+		if err != nil {
+			log.Printf("Error rendering sling message: %v", err)
+			return true
+		}
+		payloads = append(payloads, payload)
+		return true
+	})
+	if err != nil {
+		log.Printf("Failed to replay slings of board %s: %v", feed.board, err)
+	}
+	return payloads
+}
+
+// renderBoardMessage renders a board stream message for websockets.
+func (s *service) renderBoardMessage(board string, seq uint64, data []byte) ([]byte, error) {
+	var sling slingmessage.SlingMessage
+	if err := json.Unmarshal(data, &sling); err != nil {
+		return nil, err
+	}
+	return s.renderFeedSling(board, seq, &sling)
+}
+
+// renderFeedSling renders a sling read from a board stream for websockets.
+func (s *service) renderFeedSling(board string, seq uint64, sling *slingmessage.SlingMessage) ([]byte, error) {
+	card := templates.SlingMeta{Board: board}
+	if seq > 0 {
+		card.Seq = strconv.FormatUint(seq, 10)
+	}
+	// Edits replace the whole card, so they carry its reactions.
+	if !sling.EditedAt.IsZero() {
+		if reactions, err := s.slingReactions(board, sling.ID); err == nil {
+			card.Reactions = reactionView(reactions)
+		}
+	}
+	s.loadObjectContent(board, sling)
+	payload, err := renderStreamSling(sling, card)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(payload), nil
+}
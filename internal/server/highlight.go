@@ -0,0 +1,188 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/alecthomas/chroma"
+	"github.com/alecthomas/chroma/formatters/html"
+	"github.com/alecthomas/chroma/lexers"
+	"github.com/alecthomas/chroma/styles"
+	"github.com/laetho/slingboard/internal/commands"
+	"github.com/laetho/slingboard/internal/slingmessage"
+)
+
+const (
+	defaultCodeStyle = "monokai"
+	plaintextLexer   = "plaintext"
+)
+
+var (
+	errUnknownLanguage  = errors.New("unknown language")
+	errUnknownCodeStyle = errors.New("unknown code style")
+	errInvalidLineRange = errors.New("line ranges must look like 57 or 40-80")
+	errExcerptNotText   = errors.New("line ranges and titles only apply to text slings")
+)
+
+// codeStyleFormatter only writes style sheets. Line numbers are enabled so
+// the sheet also covers numbered and highlighted lines.
+var codeStyleFormatter = html.New(html.WithClasses(true), html.WithLineNumbers(true))
+
+// languageLexer resolves a language name or alias given by the sender.
+func languageLexer(language string) (chroma.Lexer, error) {
+	lexer := lexers.Get(strings.ToLower(strings.TrimSpace(language)))
+	if lexer == nil {
+		return nil, errUnknownLanguage
+	}
+	return lexer, nil
+}
+
+// codeLexer picks the lexer for a textual sling: the language given when it
+// was slung, then its filename, its MIME type and finally an analysis of the
+// content. Chat messages and plain text files are not code and get nil.
+func codeLexer(sling *slingmessage.SlingMessage) chroma.Lexer {
+	if sling.Language != "" {
+		lexer, _ := languageLexer(sling.Language)
+		return lexer
+	}
+
+	var lexer chroma.Lexer
+	if sling.Filename != "" {
+		lexer = lexers.Match(sling.Filename)
+	}
+	// Some lexers claim text/plain, which says nothing about the language.
+	if mediaType, _, err := mime.ParseMediaType(sling.MimeType); lexer == nil && err == nil && mediaType != "text/plain" {
+		lexer = lexers.MatchMimeType(mediaType)
+	}
+	if lexer == nil && sling.Filename != "" && isTextMime(sling.MimeType) {
+		lexer = lexers.Analyse(string(sling.Content))
+	}
+	if lexer == nil || lexer.Config().Name == plaintextLexer {
+		return nil
+	}
+	return lexer
+}
+
+// highlightCode renders source with the lexer as numbered, class-based
+// HTML. The colours come from the code style sheet of the board, so changing
+// the style of a board does not require rendering its slings again.
+func highlightCode(lexer chroma.Lexer, source string, excerpt *slingmessage.CodeExcerpt) (string, error) {
+	iterator, err := chroma.Coalesce(lexer).Tokenise(nil, source)
+	if err != nil {
+		return "", err
+	}
+
+	options := []html.Option{html.WithClasses(true), html.WithLineNumbers(true)}
+	if excerpt != nil {
+		options = append(options, html.HighlightLines(excerpt.Highlight))
+		if excerpt.FirstLine > 1 {
+			options = append(options, html.BaseLineNumber(excerpt.FirstLine))
+		}
+	}
+
+	var buf strings.Builder
+	if err := html.New(options...).Format(&buf, styles.Fallback, iterator); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// codeExcerpt applies the line range, highlighted lines and title of a
+// command to the content of a text sling. Only the requested lines are kept;
+// the excerpt remembers where they start so the card numbers them as in the
+// source file.
+func codeExcerpt(request commands.CommandRequest, content []byte) (*slingmessage.CodeExcerpt, []byte, error) {
+	title := strings.TrimSpace(request.Title)
+	if request.Lines == "" && request.Highlight == "" && title == "" {
+		return nil, content, nil
+	}
+	if !utf8.Valid(content) {
+		return nil, nil, errExcerptNotText
+	}
+
+	excerpt := &slingmessage.CodeExcerpt{Title: title, FirstLine: 1}
+	if request.Lines != "" {
+		ranges, err := parseLineRanges(request.Lines)
+		if err != nil || len(ranges) != 1 {
+			return nil, nil, errInvalidLineRange
+		}
+		lines := strings.SplitAfter(strings.TrimSuffix(string(content), "\n"), "\n")
+		first, last := ranges[0][0], min(ranges[0][1], len(lines))
+		if first > len(lines) {
+			return nil, nil, fmt.Errorf("the file has only %d lines", len(lines))
+		}
+		content = []byte(strings.Join(lines[first-1:last], ""))
+		excerpt.FirstLine = first
+	}
+	if request.Highlight != "" {
+		ranges, err := parseLineRanges(request.Highlight)
+		if err != nil {
+			return nil, nil, err
+		}
+		excerpt.Highlight = ranges
+	}
+	return excerpt, content, nil
+}
+
+// parseLineRanges parses comma-separated line numbers and inclusive ranges
+// such as "57" or "40-80,95".
+func parseLineRanges(value string) ([][2]int, error) {
+	var ranges [][2]int
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		from, to, isRange := strings.Cut(part, "-")
+		first, err := strconv.Atoi(strings.TrimSpace(from))
+		if err != nil || first < 1 {
+			return nil, errInvalidLineRange
+		}
+		last := first
+		if isRange {
+			last, err = strconv.Atoi(strings.TrimSpace(to))
+			if err != nil || last < first {
+				return nil, errInvalidLineRange
+			}
+		}
+		ranges = append(ranges, [2]int{first, last})
+	}
+	return ranges, nil
+}
+
+// codeHeader returns the title and line span shown above a code card.
+func codeHeader(sling *slingmessage.SlingMessage) (string, string) {
+	title := sling.Filename
+	if sling.Excerpt == nil {
+		return title, ""
+	}
+	if sling.Excerpt.Title != "" {
+		title = sling.Excerpt.Title
+	}
+	if sling.Excerpt.FirstLine <= 1 {
+		return title, ""
+	}
+	last := sling.Excerpt.FirstLine + strings.Count(strings.TrimSuffix(string(sling.Content), "\n"), "\n")
+	return title, fmt.Sprintf("lines %d–%d", sling.Excerpt.FirstLine, last)
+}
+
+// validCodeStyle reports whether chroma knows the style name.
+func validCodeStyle(name string) bool {
+	_, ok := styles.Registry[name]
+	return ok
+}
+
+// codeStyleCSS returns the style sheet for highlighted code on a board using
+// the named chroma style, or the default style when name is empty.
+func codeStyleCSS(name string) (string, error) {
+	if name == "" || !validCodeStyle(name) {
+		name = defaultCodeStyle
+	}
+
+	var buf strings.Builder
+	if err := codeStyleFormatter.WriteCSS(&buf, styles.Get(name)); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
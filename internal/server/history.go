@@ -0,0 +1,221 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/laetho/slingboard/internal/slingmessage"
+	"github.com/laetho/slingboard/templates"
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	originalQueryHeader = "X-H8s-Original-Query"
+	defaultHistoryLimit = 20
+	maxHistoryLimit     = 100
+)
+
+// slingHistory is a page of rendered slings read back from a board stream.
+type slingHistory struct {
+	html    string // sling cards, newest first
+	more    bool   // older slings remain before the oldest card
+	lastSeq uint64 // last stream sequence when the page was read
+}
+
+// renderSlingHistory renders up to limit slings published before the stream
+// sequence before, newest first. A zero before starts from the newest sling.
+// Edited slings keep the position of their oldest retained message and show
+// their latest revision, replies are nested under their parent, and slings in
+// skip, such as pinned ones, are left out.
+func (s *service) renderSlingHistory(board string, before uint64, limit int, skip map[string]struct{}) (slingHistory, error) {
+	streamName := streamPrefix + board
+	info, err := s.js.StreamInfo(streamName)
+	if err != nil {
+		return slingHistory{}, err
+	}
+	reactions, err := s.boardReactions(board)
+	if err != nil {
+		return slingHistory{}, err
+	}
+
+	history := slingHistory{lastSeq: info.State.LastSeq}
+	var buf strings.Builder
+	count := 0
+	now := time.Now()
+
+	last := info.State.LastSeq
+	if before > 0 && before-1 < last {
+		last = before - 1
+	}
+	// Top-level slings are read in windows walking back from the newest,
+	// each window twice the size of the one before, so replies are skipped
+	// by the server and a page reads about as many messages as it shows.
+	window := uint64(2 * limit)
+	for last > 0 && last >= info.State.FirstSeq {
+		first := info.State.FirstSeq
+		if last-first >= window {
+			first = last - window + 1
+		}
+		slings, err := s.topLevelSlings(board, first, last)
+		if err != nil {
+			return slingHistory{}, err
+		}
+
+		for i := len(slings) - 1; i >= 0; i-- {
+			seq, sling := slings[i].seq, slings[i].sling
+			if _, ok := skip[sling.ID]; ok {
+				continue
+			}
+			if !sling.ExpiresAt.IsZero() && !sling.ExpiresAt.After(now) {
+				continue
+			}
+			// A revision only takes the card's place once the original
+			// has aged out.
+			if !sling.EditedAt.IsZero() {
+				oldest, err := s.js.GetMsg(streamName, info.State.FirstSeq, nats.DirectGetNext(slingSubject(board, "", sling.ID)))
+				if err != nil || oldest.Sequence != seq {
+					continue
+				}
+			}
+
+			if count == limit {
+				history.more = true
+				history.html = buf.String()
+				return history, nil
+			}
+
+			current, err := s.findStoredSling(board, sling.ID)
+			if err != nil {
+				return slingHistory{}, err
+			}
+			if current == nil {
+				continue
+			}
+			thread, err := s.slingThread(board, sling.ID, seq)
+			if err != nil {
+				return slingHistory{}, err
+			}
+			replyCards, err := s.renderReplies(board, thread, reactions)
+			if err != nil {
+				return slingHistory{}, err
+			}
+			s.loadObjectContent(board, current)
+			card, err := renderSlingCard(current, templates.SlingMeta{
+				Board:     board,
+				Seq:       strconv.FormatUint(seq, 10),
+				Reactions: reactionView(reactions[sling.ID]),
+				Replies:   replyCards,
+			})
+			if err != nil {
+				return slingHistory{}, err
+			}
+			buf.WriteString(card)
+			count++
+		}
+
+		last = first - 1
+		window *= 2
+	}
+
+	history.html = buf.String()
+	return history, nil
+}
+
+// topLevelSlings returns the messages of top-level slings, originals and
+// revisions, stored between the stream sequences first and last, oldest
+// first.
+func (s *service) topLevelSlings(board string, first uint64, last uint64) ([]storedSling, error) {
+	var slings []storedSling
+	err := s.eachSubjectSling(streamPrefix+board, topLevelSlingsSubject(board), first, func(seq uint64, sling *slingmessage.SlingMessage) bool {
+		if seq > last {
+			return false
+		}
+		slings = append(slings, storedSling{seq: seq, sling: sling})
+		return true
+	})
+	return slings, err
+}
+
+// handleBoardHistory serves GET /board/{name}/history?before=<seq>&limit=n
+// with the sling cards published before the given sequence, followed by the
+// load older control.
+func (s *service) handleBoardHistory(msg *nats.Msg) {
+	subject := msg.Subject
+	if index := strings.LastIndex(subject, "."); index > 0 {
+		subject = subject[:index]
+	}
+	board, ok := boardFromSubject(subject, boardSubjectPrefix)
+	if !ok {
+		s.respondError(msg, http.StatusNotFound, "board not found")
+		return
+	}
+
+	query, err := url.ParseQuery(msg.Header.Get(originalQueryHeader))
+	if err != nil {
+		s.respondError(msg, http.StatusBadRequest, "invalid query")
+		return
+	}
+	before, limit, err := historyRange(query)
+	if err != nil {
+		s.respondError(msg, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	streamName := streamPrefix + board
+	if _, err := s.js.StreamInfo(streamName); err != nil {
+		if errors.Is(err, nats.ErrStreamNotFound) {
+			s.respondError(msg, http.StatusNotFound, "board not found")
+			return
+		}
+		s.respondError(msg, http.StatusInternalServerError, "failed to read board stream")
+		return
+	}
+
+	pinned, err := s.pinnedSlings(board)
+	if err != nil {
+		s.respondError(msg, http.StatusInternalServerError, "failed to load pinned slings")
+		return
+	}
+
+	history, err := s.renderSlingHistory(board, before, limit, slingIDs(pinned))
+	if err != nil {
+		s.respondError(msg, http.StatusInternalServerError, "failed to load board history")
+		return
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(history.html)
+	if err := templates.SlingsOlder(history.more).Render(context.Background(), &buf); err != nil {
+		s.respondError(msg, http.StatusInternalServerError, "failed to render board history")
+		return
+	}
+
+	s.respond(msg, http.StatusOK, contentTypeHTML, buf.Bytes())
+}
+
+func historyRange(query url.Values) (uint64, int, error) {
+	var before uint64
+	if value := query.Get("before"); value != "" {
+		parsed, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return 0, 0, errors.New("before must be a stream sequence")
+		}
+		before = parsed
+	}
+
+	limit := defaultHistoryLimit
+	if value := query.Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			return 0, 0, errors.New("limit must be a positive number")
+		}
+		limit = min(parsed, maxHistoryLimit)
+	}
+	return before, limit, nil
+}
@@ -0,0 +1,158 @@
+package server
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/alecthomas/chroma"
+	chromahtml "github.com/alecthomas/chroma/formatters/html"
+	"github.com/alecthomas/chroma/lexers"
+	"github.com/alecthomas/chroma/styles"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/util"
+)
+
+// markdownRenderer renders markdown slings with GitHub flavoured tables,
+// task lists, strikethrough and autolinks, footnotes, definition lists,
+// typographic punctuation and LaTeX math. Raw HTML in the source is left out.
+var markdownRenderer = goldmark.New(
+	goldmark.WithExtensions(
+		extension.GFM,
+		extension.Footnote,
+		extension.Typographer,
+		extension.DefinitionList,
+		mathExtension{},
+	),
+	goldmark.WithParserOptions(parser.WithAutoHeadingID()),
+	goldmark.WithRendererOptions(
+		renderer.WithNodeRenderers(util.Prioritized(markdownBlockRenderer{}, 100)),
+	),
+)
+
+// markdownParseOptions gives the headings of a sling IDs of their own,
+// sling-<id>-<slug>, so they neither collide with the IDs of the page nor
+// with the headings of other slings on the board.
+func markdownParseOptions(slingID string) parser.ParseOption {
+	ids := &slingHeadingIDs{prefix: "sling-" + slingID + "-", used: map[string]bool{}}
+	return parser.WithContext(parser.NewContext(parser.WithIDs(ids)))
+}
+
+// slingHeadingIDs generates the heading IDs of one sling, slugged like
+// goldmark does and numbered when a heading repeats.
+type slingHeadingIDs struct {
+	prefix string
+	used   map[string]bool
+}
+
+func (s *slingHeadingIDs) Generate(value []byte, kind ast.NodeKind) []byte {
+	var slug []byte
+	for _, r := range strings.TrimSpace(string(value)) {
+		switch {
+		case r >= 'A' && r <= 'Z':
+			slug = append(slug, byte(r-'A'+'a'))
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			slug = append(slug, byte(r))
+		case r == ' ' || r == '\t' || r == '-' || r == '_':
+			slug = append(slug, '-')
+		}
+	}
+	if len(slug) == 0 {
+		slug = []byte("heading")
+	}
+
+	id := s.prefix + string(slug)
+	for i := 1; s.used[id]; i++ {
+		id = s.prefix + string(slug) + "-" + strconv.Itoa(i)
+	}
+	s.used[id] = true
+	return []byte(id)
+}
+
+func (s *slingHeadingIDs) Put(value []byte) {
+	s.used[string(value)] = true
+}
+
+// fenceFormatter highlights code fences with the classes of the board code
+// style sheet, without line numbers.
+var fenceFormatter = chromahtml.New(chromahtml.WithClasses(true))
+
+// markdownBlockRenderer replaces the goldmark renderers of code fences, to
+// highlight them with chroma or draw mermaid and dot diagrams, and of
+// headings, to add an anchor link.
+type markdownBlockRenderer struct{}
+
+func (r markdownBlockRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindFencedCodeBlock, r.renderFencedCodeBlock)
+	reg.Register(ast.KindHeading, r.renderHeading)
+}
+
+func (r markdownBlockRenderer) renderFencedCodeBlock(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	n := node.(*ast.FencedCodeBlock)
+
+	var code strings.Builder
+	for i := 0; i < n.Lines().Len(); i++ {
+		line := n.Lines().At(i)
+		code.Write(line.Value(source))
+	}
+
+	language := string(n.Language(source))
+	switch language {
+	case "mermaid":
+		writeMermaidDiagram(w, []byte(code.String()))
+		return ast.WalkSkipChildren, nil
+	case "dot", "graphviz":
+		writeDotDiagram(w, []byte(code.String()))
+		return ast.WalkSkipChildren, nil
+	}
+
+	lexer := lexers.Get(language)
+	if lexer == nil {
+		_, _ = w.WriteString("<pre><code>")
+		_, _ = w.Write(util.EscapeHTML([]byte(code.String())))
+		_, _ = w.WriteString("</code></pre>\n")
+		return ast.WalkSkipChildren, nil
+	}
+
+	iterator, err := chroma.Coalesce(lexer).Tokenise(nil, code.String())
+	if err != nil {
+		return ast.WalkStop, err
+	}
+	if err := fenceFormatter.Format(w, styles.Fallback, iterator); err != nil {
+		return ast.WalkStop, err
+	}
+	return ast.WalkSkipChildren, nil
+}
+
+func (r markdownBlockRenderer) renderHeading(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	n := node.(*ast.Heading)
+	level := "0123456"[n.Level]
+	if entering {
+		_, _ = w.WriteString("<h")
+		_ = w.WriteByte(level)
+		if n.Attributes() != nil {
+			html.RenderAttributes(w, node, html.HeadingAttributeFilter)
+		}
+		_ = w.WriteByte('>')
+		return ast.WalkContinue, nil
+	}
+
+	if id, ok := n.AttributeString("id"); ok {
+		if id, ok := id.([]byte); ok {
+			_, _ = w.WriteString(`<a class="heading-anchor" href="#`)
+			_, _ = w.Write(util.EscapeHTML(id))
+			_, _ = w.WriteString(`" aria-hidden="true">#</a>`)
+		}
+	}
+	_, _ = w.WriteString("</h")
+	_ = w.WriteByte(level)
+	_, _ = w.WriteString(">\n")
+	return ast.WalkContinue, nil
+}
@@ -0,0 +1,194 @@
+package server
+
+import (
+	"bytes"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// mathExtension adds $inline$ and $$display$$ LaTeX math to markdown,
+// rendered to MathML on the server so kiosks need no script or CDN to show
+// formulas.
+type mathExtension struct{}
+
+func (mathExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		parser.WithBlockParsers(util.Prioritized(mathBlockParser{}, 90)),
+		parser.WithInlineParsers(util.Prioritized(mathInlineParser{}, 150)),
+	)
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(util.Prioritized(mathRenderer{}, 100)))
+}
+
+var (
+	kindMathInline = ast.NewNodeKind("MathInline")
+	kindMathBlock  = ast.NewNodeKind("MathBlock")
+)
+
+// mathInline is a formula inside a paragraph. Formulas written as $$...$$
+// inside a line are shown in display style.
+type mathInline struct {
+	ast.BaseInline
+	tex     []byte
+	display bool
+}
+
+func (n *mathInline) Kind() ast.NodeKind { return kindMathInline }
+
+func (n *mathInline) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"TeX": string(n.tex)}, nil)
+}
+
+// mathBlock is a display formula on lines of its own between $$ markers.
+type mathBlock struct {
+	ast.BaseBlock
+	closed bool
+}
+
+func (n *mathBlock) Kind() ast.NodeKind { return kindMathBlock }
+
+func (n *mathBlock) IsRaw() bool { return true }
+
+func (n *mathBlock) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, nil, nil)
+}
+
+type mathInlineParser struct{}
+
+func (mathInlineParser) Trigger() []byte {
+	return []byte{'$'}
+}
+
+// Parse reads a formula on the current line. As in pandoc, a single $ only
+// opens a formula when followed by a non-space and closes it when preceded
+// by a non-space and not followed by a digit, so prices such as "$5 and $10"
+// stay text.
+func (mathInlineParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, _ := block.PeekLine()
+	delimiter := []byte("$")
+	if bytes.HasPrefix(line, []byte("$$")) {
+		delimiter = []byte("$$")
+	}
+	rest := line[len(delimiter):]
+	if len(rest) == 0 || rest[0] == ' ' || rest[0] == '\t' || rest[0] == '\n' {
+		return nil
+	}
+
+	for offset := 0; offset < len(rest); {
+		end := bytes.Index(rest[offset:], delimiter)
+		if end < 0 {
+			return nil
+		}
+		end += offset
+		after := end + len(delimiter)
+		closes := end > 0 && rest[end-1] != '\\' && rest[end-1] != ' ' && rest[end-1] != '\t'
+		beforeDigit := len(delimiter) == 1 && after < len(rest) && rest[after] >= '0' && rest[after] <= '9'
+		if closes && !beforeDigit {
+			block.Advance(len(delimiter) + after)
+			return &mathInline{tex: bytes.Clone(rest[:end]), display: len(delimiter) == 2}
+		}
+		offset = end + 1
+	}
+	return nil
+}
+
+type mathBlockParser struct{}
+
+func (mathBlockParser) Trigger() []byte {
+	return []byte{'$'}
+}
+
+func (mathBlockParser) Open(parent ast.Node, reader text.Reader, pc parser.Context) (ast.Node, parser.State) {
+	line, segment := reader.PeekLine()
+	pos := pc.BlockOffset()
+	if pos < 0 || !bytes.HasPrefix(line[pos:], []byte("$$")) {
+		return nil, parser.NoChildren
+	}
+	start := pos + 2
+	rest := line[start:]
+	node := &mathBlock{}
+
+	// $$x$$ on one line is a display formula when nothing follows it;
+	// otherwise the line is a paragraph with an inline formula.
+	if end := bytes.Index(rest, []byte("$$")); end >= 0 {
+		if !util.IsBlank(rest[end+2:]) {
+			return nil, parser.NoChildren
+		}
+		node.Lines().Append(text.NewSegment(segment.Start+start, segment.Start+start+end))
+		node.closed = true
+	} else if !util.IsBlank(rest) {
+		node.Lines().Append(text.NewSegment(segment.Start+start, segment.Stop))
+	}
+	advanceLine(reader, line, segment)
+	return node, parser.NoChildren
+}
+
+func (mathBlockParser) Continue(node ast.Node, reader text.Reader, pc parser.Context) parser.State {
+	n := node.(*mathBlock)
+	if n.closed {
+		return parser.Close
+	}
+	line, segment := reader.PeekLine()
+	if line == nil {
+		return parser.Close
+	}
+	if end := bytes.Index(line, []byte("$$")); end >= 0 {
+		n.Lines().Append(text.NewSegment(segment.Start, segment.Start+end))
+		advanceLine(reader, line, segment)
+		return parser.Close
+	}
+	n.Lines().Append(segment)
+	advanceLine(reader, line, segment)
+	return parser.Continue | parser.NoChildren
+}
+
+// advanceLine moves the reader to the end of the line, short of its newline.
+func advanceLine(reader text.Reader, line []byte, segment text.Segment) {
+	newline := 0
+	if len(line) > 0 && line[len(line)-1] == '\n' {
+		newline = 1
+	}
+	reader.Advance(segment.Len() - newline + segment.Padding)
+}
+
+func (mathBlockParser) Close(node ast.Node, reader text.Reader, pc parser.Context) {}
+
+func (mathBlockParser) CanInterruptParagraph() bool {
+	return true
+}
+
+func (mathBlockParser) CanAcceptIndentedLine() bool {
+	return false
+}
+
+type mathRenderer struct{}
+
+func (r mathRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(kindMathInline, r.renderMathInline)
+	reg.Register(kindMathBlock, r.renderMathBlock)
+}
+
+func (mathRenderer) renderMathInline(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if entering {
+		n := node.(*mathInline)
+		_, _ = w.WriteString(mathML(string(n.tex), n.display))
+	}
+	return ast.WalkSkipChildren, nil
+}
+
+func (mathRenderer) renderMathBlock(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if entering {
+		var tex bytes.Buffer
+		for i := 0; i < node.Lines().Len(); i++ {
+			line := node.Lines().At(i)
+			tex.Write(line.Value(source))
+		}
+		_, _ = w.WriteString(mathML(string(bytes.TrimSpace(tex.Bytes())), true))
+		_ = w.WriteByte('\n')
+	}
+	return ast.WalkSkipChildren, nil
+}
@@ -0,0 +1,585 @@
+package server
+
+import (
+	"html"
+	"strings"
+	"unicode"
+)
+
+// maxTeXDepth bounds the nesting of groups, fractions and environments in a
+// formula.
+const maxTeXDepth = 64
+
+const emptyMathRow = "<mrow></mrow>"
+
+// mathML converts a LaTeX formula to MathML, which browsers lay out without
+// any script. It covers the notation found in notes and runbooks: scripts,
+// fractions, roots, Greek letters, operators and relations, functions,
+// accents, font styles, delimiters and matrix environments. Commands it does
+// not know are shown as errors inside the formula. The source is kept as an
+// annotation.
+func mathML(tex string, display bool) string {
+	p := &texParser{tokens: tokenizeTeX(tex), display: display}
+	body := mathRow(p.parseUntil(nil))
+
+	var b strings.Builder
+	b.WriteString(`<math xmlns="http://www.w3.org/1998/Math/MathML"`)
+	if display {
+		b.WriteString(` display="block"`)
+	}
+	b.WriteString(`><semantics>`)
+	b.WriteString(body)
+	b.WriteString(`<annotation encoding="application/x-tex">`)
+	b.WriteString(html.EscapeString(tex))
+	b.WriteString(`</annotation></semantics></math>`)
+	return b.String()
+}
+
+// tokenizeTeX splits a formula into control sequences, single characters
+// and runs of whitespace, which become a single space token.
+func tokenizeTeX(tex string) []string {
+	var tokens []string
+	runes := []rune(tex)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '\\':
+			j := i + 1
+			for j < len(runes) && isASCIILetter(runes[j]) {
+				j++
+			}
+			if j == i+1 && j < len(runes) {
+				j++
+			}
+			tokens = append(tokens, string(runes[i:j]))
+			i = j - 1
+		case unicode.IsSpace(r):
+			if len(tokens) == 0 || tokens[len(tokens)-1] != " " {
+				tokens = append(tokens, " ")
+			}
+		default:
+			tokens = append(tokens, string(r))
+		}
+	}
+	return tokens
+}
+
+func isASCIILetter(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+}
+
+type texParser struct {
+	tokens  []string
+	pos     int
+	depth   int
+	display bool
+	variant string // font style applied to letters and digits, such as "bold"
+}
+
+// peek returns the next token that is not whitespace, or "" at the end.
+func (p *texParser) peek() string {
+	for p.pos < len(p.tokens) && p.tokens[p.pos] == " " {
+		p.pos++
+	}
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *texParser) next() string {
+	token := p.peek()
+	if token != "" {
+		p.pos++
+	}
+	return token
+}
+
+// parseUntil parses atoms and their scripts until the end of the formula or
+// a token for which stop returns true, which is left unread.
+func (p *texParser) parseUntil(stop func(token string) bool) []string {
+	var items []string
+	for {
+		token := p.peek()
+		if token == "" || (stop != nil && stop(token)) {
+			return items
+		}
+		if token == "}" {
+			p.pos++
+			continue
+		}
+		atom, limits := p.parseAtom()
+		if atom = p.parseScripts(atom, limits); atom != "" {
+			items = append(items, atom)
+		}
+	}
+}
+
+// parseScripts attaches subscripts and superscripts to base. Large
+// operators with limits put them under and over the operator in display
+// formulas.
+func (p *texParser) parseScripts(base string, limits bool) string {
+	var sub, sup string
+	for {
+		token := p.peek()
+		if (token != "_" || sub != "") && (token != "^" || sup != "") {
+			break
+		}
+		p.pos++
+		if token == "_" {
+			sub = p.parseArgument()
+		} else {
+			sup = p.parseArgument()
+		}
+	}
+	if sub == "" && sup == "" {
+		return base
+	}
+	if base == "" {
+		base = emptyMathRow
+	}
+
+	subTag, supTag, bothTag := "msub", "msup", "msubsup"
+	if limits && p.display {
+		subTag, supTag, bothTag = "munder", "mover", "munderover"
+	}
+	switch {
+	case sup == "":
+		return "<" + subTag + ">" + base + sub + "</" + subTag + ">"
+	case sub == "":
+		return "<" + supTag + ">" + base + sup + "</" + supTag + ">"
+	default:
+		return "<" + bothTag + ">" + base + sub + sup + "</" + bothTag + ">"
+	}
+}
+
+// parseArgument parses the argument of a command or script: a group or a
+// single atom.
+func (p *texParser) parseArgument() string {
+	if p.peek() == "" {
+		return emptyMathRow
+	}
+	atom, _ := p.parseAtom()
+	if atom == "" {
+		return emptyMathRow
+	}
+	return atom
+}
+
+// parseAtom parses a group, number, letter, operator or command. The flag
+// reports large operators that take limits.
+func (p *texParser) parseAtom() (string, bool) {
+	if p.depth >= maxTeXDepth {
+		p.pos = len(p.tokens)
+		return "<merror><mtext>formula is nested too deeply</mtext></merror>", false
+	}
+	p.depth++
+	defer func() { p.depth-- }()
+
+	token := p.next()
+	r := []rune(token)[0]
+	switch {
+	case token == "{":
+		items := p.parseUntil(func(token string) bool { return token == "}" })
+		p.next()
+		return mathRow(items), false
+	case token == "^" || token == "_":
+		// Scripts without a base attach to an empty row.
+		p.pos--
+		return emptyMathRow, false
+	case token == "&" || token == `\\`:
+		// Column and row separators outside of an environment.
+		return "", false
+	case strings.HasPrefix(token, `\`):
+		return p.command(token[1:])
+	case unicode.IsDigit(r):
+		number := token
+		for {
+			next := p.peek()
+			if next != "" && (unicode.IsDigit([]rune(next)[0]) || (next == "." && p.pos+1 < len(p.tokens) && isDigitToken(p.tokens[p.pos+1]))) {
+				number += p.next()
+				continue
+			}
+			break
+		}
+		return "<mn>" + html.EscapeString(p.styled(number)) + "</mn>", false
+	case unicode.IsLetter(r):
+		if p.variant == "normal" {
+			return `<mi mathvariant="normal">` + html.EscapeString(token) + "</mi>", false
+		}
+		return "<mi>" + html.EscapeString(p.styled(token)) + "</mi>", false
+	case token == "~":
+		return mathSpace("0.333em"), false
+	case token == "'":
+		return "<mo>′</mo>", false
+	case token == "-":
+		return "<mo>−</mo>", false
+	case token == "*":
+		return "<mo>∗</mo>", false
+	default:
+		return "<mo>" + html.EscapeString(token) + "</mo>", false
+	}
+}
+
+func isDigitToken(token string) bool {
+	return token != "" && unicode.IsDigit([]rune(token)[0])
+}
+
+// command converts a control sequence and its arguments.
+func (p *texParser) command(name string) (string, bool) {
+	if symbol, ok := texGreek[name]; ok {
+		if unicode.IsUpper(symbol) {
+			return `<mi mathvariant="normal">` + string(symbol) + "</mi>", false
+		}
+		return "<mi>" + string(symbol) + "</mi>", false
+	}
+	if symbol, ok := texOperators[name]; ok {
+		return "<mo>" + html.EscapeString(symbol) + "</mo>", false
+	}
+	if symbol, ok := texLargeOperators[name]; ok {
+		return "<mo>" + symbol + "</mo>", !strings.Contains("∫∬∭∮", symbol)
+	}
+	if limits, ok := texFunctions[name]; ok {
+		return "<mi>" + name + "</mi>", limits
+	}
+	if width, ok := texSpaces[name]; ok {
+		return mathSpace(width), false
+	}
+	if accent, ok := texAccents[name]; ok {
+		base := p.parseArgument()
+		tag := "mover"
+		if accent.under {
+			tag = "munder"
+		}
+		return "<" + tag + ` accent="true">` + base + `<mo stretchy="` + boolAttr(accent.stretchy) + `">` + accent.symbol + "</mo></" + tag + ">", false
+	}
+	if variant, ok := texVariants[name]; ok {
+		saved := p.variant
+		p.variant = variant
+		argument := p.parseArgument()
+		p.variant = saved
+		return argument, false
+	}
+
+	switch name {
+	case "frac", "dfrac", "tfrac", "cfrac":
+		numerator := p.parseArgument()
+		denominator := p.parseArgument()
+		return "<mfrac>" + numerator + denominator + "</mfrac>", false
+	case "binom":
+		top := p.parseArgument()
+		bottom := p.parseArgument()
+		return mathFence("(", ")", `<mfrac linethickness="0">`+top+bottom+"</mfrac>"), false
+	case "sqrt":
+		if p.peek() == "[" {
+			p.next()
+			index := mathRow(p.parseUntil(func(token string) bool { return token == "]" }))
+			p.next()
+			return "<mroot>" + p.parseArgument() + index + "</mroot>", false
+		}
+		return "<msqrt>" + p.parseArgument() + "</msqrt>", false
+	case "text", "textrm", "textit", "textbf", "mbox":
+		return "<mtext>" + html.EscapeString(p.rawGroup()) + "</mtext>", false
+	case "operatorname":
+		return "<mi>" + html.EscapeString(p.rawGroup()) + "</mi>", false
+	case "left":
+		left := texDelimiter(p.next())
+		items := p.parseUntil(func(token string) bool { return token == `\right` })
+		p.next()
+		right := texDelimiter(p.next())
+		return mathFence(left, right, mathRow(items)), false
+	case "middle":
+		return `<mo stretchy="true">` + html.EscapeString(texDelimiter(p.next())) + "</mo>", false
+	case "right":
+		p.next()
+		return "", false
+	case "begin":
+		return p.environment(p.rawGroup()), false
+	case "end":
+		p.rawGroup()
+		return "", false
+	case "displaystyle", "textstyle", "limits", "nolimits":
+		return "", false
+	case "{", "}", "%", "$", "#", "&", "_":
+		return "<mo>" + html.EscapeString(name) + "</mo>", false
+	case "|":
+		return "<mo>‖</mo>", false
+	}
+	return `<merror><mtext>\` + html.EscapeString(name) + "</mtext></merror>", false
+}
+
+// rawGroup reads a braced argument as text, for \text and environment
+// names.
+func (p *texParser) rawGroup() string {
+	if p.peek() != "{" {
+		return ""
+	}
+	p.pos++
+	var b strings.Builder
+	for depth := 0; p.pos < len(p.tokens); p.pos++ {
+		token := p.tokens[p.pos]
+		switch {
+		case token == "{":
+			depth++
+		case token == "}" && depth == 0:
+			p.pos++
+			return b.String()
+		case token == "}":
+			depth--
+		}
+		if len(token) == 2 && token[0] == '\\' && !isASCIILetter(rune(token[1])) {
+			token = token[1:]
+		}
+		b.WriteString(token)
+	}
+	return b.String()
+}
+
+// environment converts the rows and columns of a matrix, cases or aligned
+// environment to a table.
+func (p *texParser) environment(name string) string {
+	if name == "array" {
+		p.rawGroup()
+	}
+
+	var rows [][]string
+	var row []string
+	isSeparator := func(token string) bool { return token == "&" || token == `\\` || token == `\end` }
+	for {
+		row = append(row, mathRow(p.parseUntil(isSeparator)))
+		token := p.next()
+		if token == "&" {
+			continue
+		}
+		if token == `\\` {
+			rows = append(rows, row)
+			row = nil
+			continue
+		}
+		// A trailing \\ leaves a last row with one empty cell.
+		if len(row) > 1 || row[0] != emptyMathRow {
+			rows = append(rows, row)
+		}
+		if token == `\end` {
+			p.rawGroup()
+		}
+		break
+	}
+
+	var b strings.Builder
+	b.WriteString("<mtable")
+	switch strings.TrimSuffix(name, "*") {
+	case "cases":
+		b.WriteString(` columnalign="left"`)
+	case "aligned", "align", "split", "eqnarray":
+		b.WriteString(` columnalign="right left"`)
+	}
+	b.WriteString(">")
+	for _, cells := range rows {
+		b.WriteString("<mtr>")
+		for _, cell := range cells {
+			b.WriteString("<mtd>" + cell + "</mtd>")
+		}
+		b.WriteString("</mtr>")
+	}
+	b.WriteString("</mtable>")
+	table := b.String()
+
+	switch name {
+	case "pmatrix":
+		return mathFence("(", ")", table)
+	case "bmatrix":
+		return mathFence("[", "]", table)
+	case "Bmatrix":
+		return mathFence("{", "}", table)
+	case "vmatrix":
+		return mathFence("|", "|", table)
+	case "Vmatrix":
+		return mathFence("‖", "‖", table)
+	case "cases":
+		return mathFence("{", "", table)
+	}
+	return table
+}
+
+// styled applies the font style of \mathbf, \mathbb and friends to letters
+// and digits by mapping them to the mathematical alphanumeric symbols.
+func (p *texParser) styled(text string) string {
+	alphabet, ok := mathAlphabets[p.variant]
+	if !ok {
+		return text
+	}
+	return strings.Map(func(r rune) rune {
+		if mapped, ok := alphabet.exceptions[r]; ok {
+			return mapped
+		}
+		switch {
+		case r >= 'A' && r <= 'Z' && alphabet.upper != 0:
+			return alphabet.upper + r - 'A'
+		case r >= 'a' && r <= 'z' && alphabet.lower != 0:
+			return alphabet.lower + r - 'a'
+		case r >= '0' && r <= '9' && alphabet.digit != 0:
+			return alphabet.digit + r - '0'
+		}
+		return r
+	}, text)
+}
+
+func mathRow(items []string) string {
+	switch len(items) {
+	case 0:
+		return emptyMathRow
+	case 1:
+		return items[0]
+	}
+	return "<mrow>" + strings.Join(items, "") + "</mrow>"
+}
+
+// mathFence wraps content in stretchy delimiters. An empty delimiter is left
+// out, as with \left. in LaTeX.
+func mathFence(left string, right string, content string) string {
+	var b strings.Builder
+	b.WriteString("<mrow>")
+	if left != "" {
+		b.WriteString(`<mo fence="true" stretchy="true">` + html.EscapeString(left) + "</mo>")
+	}
+	b.WriteString(content)
+	if right != "" {
+		b.WriteString(`<mo fence="true" stretchy="true">` + html.EscapeString(right) + "</mo>")
+	}
+	b.WriteString("</mrow>")
+	return b.String()
+}
+
+func mathSpace(width string) string {
+	return `<mspace width="` + width + `"></mspace>`
+}
+
+func boolAttr(value bool) string {
+	if value {
+		return "true"
+	}
+	return "false"
+}
+
+// texDelimiter maps the delimiter after \left, \middle or \right.
+func texDelimiter(token string) string {
+	switch token {
+	case ".", "":
+		return ""
+	case `\{`, `\lbrace`:
+		return "{"
+	case `\}`, `\rbrace`:
+		return "}"
+	case `\langle`:
+		return "⟨"
+	case `\rangle`:
+		return "⟩"
+	case `\|`, `\Vert`:
+		return "‖"
+	case `\vert`, `\lvert`, `\rvert`:
+		return "|"
+	case `\lfloor`:
+		return "⌊"
+	case `\rfloor`:
+		return "⌋"
+	case `\lceil`:
+		return "⌈"
+	case `\rceil`:
+		return "⌉"
+	}
+	return token
+}
+
+var texGreek = map[string]rune{
+	"alpha": 'α', "beta": 'β', "gamma": 'γ', "delta": 'δ', "epsilon": 'ϵ', "varepsilon": 'ε',
+	"zeta": 'ζ', "eta": 'η', "theta": 'θ', "vartheta": 'ϑ', "iota": 'ι', "kappa": 'κ',
+	"lambda": 'λ', "mu": 'μ', "nu": 'ν', "xi": 'ξ', "pi": 'π', "varpi": 'ϖ', "rho": 'ρ',
+	"varrho": 'ϱ', "sigma": 'σ', "varsigma": 'ς', "tau": 'τ', "upsilon": 'υ', "phi": 'ϕ',
+	"varphi": 'φ', "chi": 'χ', "psi": 'ψ', "omega": 'ω',
+	"Gamma": 'Γ', "Delta": 'Δ', "Theta": 'Θ', "Lambda": 'Λ', "Xi": 'Ξ', "Pi": 'Π',
+	"Sigma": 'Σ', "Upsilon": 'Υ', "Phi": 'Φ', "Psi": 'Ψ', "Omega": 'Ω',
+}
+
+var texOperators = map[string]string{
+	"pm": "±", "mp": "∓", "times": "×", "div": "÷", "cdot": "⋅", "ast": "∗", "star": "⋆",
+	"circ": "∘", "bullet": "∙", "oplus": "⊕", "otimes": "⊗", "wedge": "∧", "land": "∧",
+	"vee": "∨", "lor": "∨", "neg": "¬", "lnot": "¬", "setminus": "∖",
+	"leq": "≤", "le": "≤", "geq": "≥", "ge": "≥", "neq": "≠", "ne": "≠", "ll": "≪", "gg": "≫",
+	"approx": "≈", "equiv": "≡", "sim": "∼", "simeq": "≃", "cong": "≅", "propto": "∝",
+	"in": "∈", "notin": "∉", "ni": "∋", "subset": "⊂", "supset": "⊃", "subseteq": "⊆",
+	"supseteq": "⊇", "cup": "∪", "cap": "∩", "emptyset": "∅", "varnothing": "∅",
+	"forall": "∀", "exists": "∃", "nexists": "∄", "partial": "∂", "nabla": "∇", "infty": "∞",
+	"to": "→", "rightarrow": "→", "leftarrow": "←", "gets": "←", "leftrightarrow": "↔",
+	"Rightarrow": "⇒", "Leftarrow": "⇐", "Leftrightarrow": "⇔", "implies": "⟹", "iff": "⟺",
+	"mapsto": "↦", "uparrow": "↑", "downarrow": "↓", "longrightarrow": "⟶",
+	"ldots": "…", "dots": "…", "cdots": "⋯", "vdots": "⋮", "ddots": "⋱",
+	"mid": "∣", "parallel": "∥", "perp": "⊥", "angle": "∠", "prime": "′", "degree": "°",
+	"langle": "⟨", "rangle": "⟩", "lbrace": "{", "rbrace": "}", "vert": "|", "Vert": "‖",
+	"lfloor": "⌊", "rfloor": "⌋", "lceil": "⌈", "rceil": "⌉",
+	"hbar": "ℏ", "ell": "ℓ", "Re": "ℜ", "Im": "ℑ", "aleph": "ℵ",
+}
+
+var texLargeOperators = map[string]string{
+	"sum": "∑", "prod": "∏", "coprod": "∐", "bigcup": "⋃", "bigcap": "⋂",
+	"bigoplus": "⨁", "bigotimes": "⨂", "bigvee": "⋁", "bigwedge": "⋀",
+	"int": "∫", "iint": "∬", "iiint": "∭", "oint": "∮",
+}
+
+// texFunctions maps function names to whether they take limits.
+var texFunctions = map[string]bool{
+	"sin": false, "cos": false, "tan": false, "cot": false, "sec": false, "csc": false,
+	"arcsin": false, "arccos": false, "arctan": false, "sinh": false, "cosh": false, "tanh": false,
+	"log": false, "ln": false, "lg": false, "exp": false, "deg": false, "dim": false,
+	"ker": false, "arg": false, "hom": false,
+	"lim": true, "liminf": true, "limsup": true, "max": true, "min": true, "sup": true,
+	"inf": true, "det": true, "gcd": true, "Pr": true, "argmax": true, "argmin": true,
+}
+
+var texSpaces = map[string]string{
+	",": "0.167em", ":": "0.222em", ">": "0.222em", ";": "0.278em", "!": "-0.167em",
+	" ": "0.333em", "quad": "1em", "qquad": "2em",
+}
+
+type texAccent struct {
+	symbol   string
+	stretchy bool
+	under    bool
+}
+
+var texAccents = map[string]texAccent{
+	"hat": {symbol: "^"}, "widehat": {symbol: "^", stretchy: true},
+	"bar": {symbol: "¯"}, "overline": {symbol: "‾", stretchy: true},
+	"vec": {symbol: "→"}, "overrightarrow": {symbol: "→", stretchy: true},
+	"dot": {symbol: "˙"}, "ddot": {symbol: "¨"},
+	"tilde": {symbol: "~"}, "widetilde": {symbol: "~", stretchy: true},
+	"overbrace":  {symbol: "⏞", stretchy: true},
+	"underline":  {symbol: "_", stretchy: true, under: true},
+	"underbrace": {symbol: "⏟", stretchy: true, under: true},
+}
+
+var texVariants = map[string]string{
+	"mathrm": "normal", "mathbf": "bold", "mathit": "italic", "boldsymbol": "bold-italic",
+	"bm": "bold-italic", "mathbb": "double-struck", "mathcal": "script", "mathsf": "sans-serif",
+}
+
+type mathAlphabet struct {
+	upper, lower, digit rune
+	exceptions          map[rune]rune
+}
+
+// mathAlphabets holds the start of each style in the Mathematical
+// Alphanumeric Symbols block, and the letters encoded elsewhere.
+var mathAlphabets = map[string]mathAlphabet{
+	"bold":        {upper: 0x1D400, lower: 0x1D41A, digit: 0x1D7CE},
+	"italic":      {upper: 0x1D434, lower: 0x1D44E, exceptions: map[rune]rune{'h': 'ℎ'}},
+	"bold-italic": {upper: 0x1D468, lower: 0x1D482},
+	"double-struck": {upper: 0x1D538, lower: 0x1D552, digit: 0x1D7D8, exceptions: map[rune]rune{
+		'C': 'ℂ', 'H': 'ℍ', 'N': 'ℕ', 'P': 'ℙ', 'Q': 'ℚ', 'R': 'ℝ', 'Z': 'ℤ',
+	}},
+	"script": {upper: 0x1D49C, lower: 0x1D4B6, exceptions: map[rune]rune{
+		'B': 'ℬ', 'E': 'ℰ', 'F': 'ℱ', 'H': 'ℋ', 'I': 'ℐ', 'L': 'ℒ', 'M': 'ℳ', 'R': 'ℛ',
+		'e': 'ℯ', 'g': 'ℊ', 'o': 'ℴ',
+	}},
+	"sans-serif": {upper: 0x1D5A0, lower: 0x1D5BA, digit: 0x1D7E2},
+}
@@ -0,0 +1,220 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/laetho/slingboard/internal/commands"
+	"github.com/laetho/slingboard/templates"
+	"github.com/nats-io/nats.go"
+)
+
+const boardMetadataBucket = "sb_boards"
+
+// metadataBucket returns the KV bucket holding the metadata of every board,
+// keyed by board name.
+func (s *service) metadataBucket(create bool) (nats.KeyValue, error) {
+	kv, err := s.js.KeyValue(boardMetadataBucket)
+	if err == nil || !create || !errors.Is(err, nats.ErrBucketNotFound) {
+		return kv, err
+	}
+
+	return s.js.CreateKeyValue(&nats.KeyValueConfig{
+		Bucket:  boardMetadataBucket,
+		Storage: nats.FileStorage,
+	})
+}
+
+// boardMetadata returns the stored metadata of a board. Boards without stored
+// metadata, such as boards created by opening them, get their name and the
+// creation time of their stream.
+func (s *service) boardMetadata(kv nats.KeyValue, board string) (commands.BoardMetadata, error) {
+	metadata := commands.BoardMetadata{Name: board}
+	if kv != nil {
+		entry, err := kv.Get(board)
+		if err != nil && !errors.Is(err, nats.ErrKeyNotFound) {
+			return metadata, err
+		}
+		if err == nil {
+			if err := json.Unmarshal(entry.Value(), &metadata); err != nil {
+				return commands.BoardMetadata{Name: board}, err
+			}
+			metadata.Name = board
+		}
+	}
+
+	if metadata.CreatedAt.IsZero() {
+		info, err := s.js.StreamInfo(streamPrefix + board)
+		if err != nil {
+			if errors.Is(err, nats.ErrStreamNotFound) {
+				return metadata, errBoardNotFound
+			}
+			return metadata, err
+		}
+		metadata.CreatedAt = info.Created.UTC()
+	}
+	return metadata, nil
+}
+
+// boardMetadataList returns the metadata of the given boards in order.
+func (s *service) boardMetadataList(boards []string) ([]commands.BoardMetadata, error) {
+	kv, err := s.metadataBucket(false)
+	if err != nil && !errors.Is(err, nats.ErrBucketNotFound) {
+		return nil, err
+	}
+
+	list := make([]commands.BoardMetadata, 0, len(boards))
+	for _, board := range boards {
+		metadata, err := s.boardMetadata(kv, board)
+		if errors.Is(err, errBoardNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, metadata)
+	}
+	return list, nil
+}
+
+// saveBoardMetadata merges update into the stored metadata of an existing
+// board and returns the result.
+func (s *service) saveBoardMetadata(board string, update *commands.BoardMetadata) (commands.BoardMetadata, error) {
+	kv, err := s.metadataBucket(true)
+	if err != nil {
+		return commands.BoardMetadata{}, err
+	}
+
+	metadata, err := s.boardMetadata(kv, board)
+	if err != nil {
+		return metadata, err
+	}
+	if update != nil {
+		if title := strings.TrimSpace(update.Title); title != "" {
+			metadata.Title = title
+		}
+		if description := strings.TrimSpace(update.Description); description != "" {
+			metadata.Description = description
+		}
+		if owner := strings.TrimSpace(update.Owner); owner != "" {
+			metadata.Owner = owner
+		}
+		if update.Tags != nil {
+			metadata.Tags = normalizeTags(update.Tags)
+		}
+		if style := strings.TrimSpace(update.CodeStyle); style != "" {
+			if !validCodeStyle(style) {
+				return metadata, errUnknownCodeStyle
+			}
+			metadata.CodeStyle = style
+		}
+	}
+
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return metadata, err
+	}
+	if _, err := kv.Put(board, data); err != nil {
+		return metadata, err
+	}
+	return metadata, nil
+}
+
+func (s *service) deleteBoardMetadata(board string) error {
+	kv, err := s.metadataBucket(false)
+	if err != nil {
+		if errors.Is(err, nats.ErrBucketNotFound) {
+			return nil
+		}
+		return err
+	}
+	if err := kv.Delete(board); err != nil && !errors.Is(err, nats.ErrKeyNotFound) {
+		return err
+	}
+	return nil
+}
+
+func normalizeTags(tags []string) []string {
+	seen := make(map[string]struct{}, len(tags))
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" {
+			continue
+		}
+		if _, ok := seen[tag]; ok {
+			continue
+		}
+		seen[tag] = struct{}{}
+		normalized = append(normalized, tag)
+	}
+	return normalized
+}
+
+func (s *service) handleBoardUpdate(msg *nats.Msg, request commands.CommandRequest) {
+	board := normalizeBoardName(request.Board)
+	if board == "" {
+		s.respondCommandError(msg, http.StatusBadRequest, "board is required")
+		return
+	}
+
+	metadata, err := s.saveBoardMetadata(board, request.Metadata)
+	if err != nil {
+		switch {
+		case errors.Is(err, errBoardNotFound):
+			s.respondCommandError(msg, http.StatusNotFound, err.Error())
+		case errors.Is(err, errUnknownCodeStyle):
+			s.respondCommandError(msg, http.StatusBadRequest, err.Error())
+		default:
+			s.respondCommandError(msg, http.StatusInternalServerError, "failed to update board metadata")
+		}
+		return
+	}
+
+	// Open screens restyle their code in place when the code style changes.
+	if request.Metadata != nil && request.Metadata.CodeStyle != "" {
+		css, err := codeStyleCSS(metadata.CodeStyle)
+		if err != nil {
+			s.respondCommandError(msg, http.StatusInternalServerError, "failed to render code style")
+			return
+		}
+		var buf bytes.Buffer
+		if err := templates.CodeStyleChanged(css).Render(context.Background(), &buf); err != nil {
+			s.respondCommandError(msg, http.StatusInternalServerError, "failed to render code style")
+			return
+		}
+		if err := s.broadcast(board, buf.Bytes()); err != nil {
+			s.respondCommandError(msg, http.StatusBadGateway, "failed to broadcast code style")
+			return
+		}
+	}
+
+	s.respondJSON(msg, http.StatusOK, commands.CommandResponse{
+		Status:   "ok",
+		Board:    board,
+		Metadata: &metadata,
+	})
+}
+
+// boardMeta prepares board metadata for the index cards and board header.
+func boardMeta(metadata commands.BoardMetadata) templates.BoardMeta {
+	meta := templates.BoardMeta{
+		Name:        metadata.Name,
+		Title:       metadata.Title,
+		Description: metadata.Description,
+		Owner:       metadata.Owner,
+		Tags:        metadata.Tags,
+	}
+	if meta.Title == "" {
+		meta.Title = metadata.Name
+	}
+	if !metadata.CreatedAt.IsZero() {
+		meta.CreatedAt = metadata.CreatedAt.UTC().Format(time.DateOnly)
+	}
+	return meta
+}
@@ -0,0 +1,496 @@
+package server
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/laetho/slingboard/internal/slingmessage"
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	objectBucketPrefix = "sb_objects_"
+	objectPathSegment  = "objects"
+	objectCacheHeader  = "public, max-age=31536000, immutable"
+	defaultObjectMime  = "application/octet-stream"
+	pdfMimeType        = "application/pdf"
+
+	rangeHeaderAllowance = 4 << 10
+
+	// maxRenderedObject is the largest stored file whose content is rendered
+	// into its card, such as code, markdown or a table. Larger files are
+	// offered for download.
+	maxRenderedObject = 1 << 20
+
+	objectSweepInterval = time.Hour
+)
+
+// objectBucket returns the Object Store bucket holding the file bodies of a
+// board, keyed by sling ID. Objects have no max age of their own, since
+// pinned slings outlive the board retention; they are deleted together with
+// their sling or board, and swept once no sling refers to them.
+func (s *service) objectBucket(board string, create bool) (nats.ObjectStore, error) {
+	bucket := objectBucketPrefix + board
+	obs, err := s.js.ObjectStore(bucket)
+	if err == nil || !create || !errors.Is(err, nats.ErrStreamNotFound) {
+		return obs, err
+	}
+
+	config := &nats.ObjectStoreConfig{
+		Bucket:  bucket,
+		Storage: nats.FileStorage,
+	}
+	if info, err := s.js.StreamInfo(streamPrefix + board); err == nil {
+		config.Storage = info.Config.Storage
+	}
+	return s.js.CreateObjectStore(config)
+}
+
+// linksObject reports whether cards of the MIME type load the stored object
+// by URL, like images, PDFs, video and audio.
+func linksObject(mimeType string) bool {
+	return strings.HasPrefix(mimeType, "image/") || mimeType == pdfMimeType || isMediaMime(mimeType)
+}
+
+// rendersContent reports whether the card of a file sling may show its
+// content, such as text, code, markdown, tables and structured data. Named
+// files of any other type are loaded too, and show their text when it turns
+// out to be UTF-8.
+func rendersContent(sling *slingmessage.SlingMessage) bool {
+	switch {
+	case linksObject(sling.MimeType):
+		return false
+	case isTextMime(sling.MimeType), structuredFormat(sling) != "":
+		return true
+	}
+	return sling.Filename != ""
+}
+
+// isMediaMime reports whether the MIME type is played in a video or audio
+// player.
+func isMediaMime(mimeType string) bool {
+	return strings.HasPrefix(mimeType, "video/") || strings.HasPrefix(mimeType, "audio/")
+}
+
+// storeObject puts the body of a file sling into the board object store
+// under the sling ID.
+func (s *service) storeObject(board string, id string, filename string, mimeType string, data io.Reader) error {
+	obs, err := s.objectBucket(board, true)
+	if err != nil {
+		return err
+	}
+
+	_, err = obs.Put(&nats.ObjectMeta{
+		Name:        id,
+		Description: filename,
+		Headers:     nats.Header{"Content-Type": []string{mimeType}},
+	}, data)
+	return err
+}
+
+// loadObjectContent reads the stored body of a file sling whose card shows
+// its content. Cards of other files link to the object instead, and files
+// too large to render keep no content and are offered for download.
+func (s *service) loadObjectContent(board string, sling *slingmessage.SlingMessage) {
+	if sling.ObjectID == "" || sling.Content != nil || sling.Size > maxRenderedObject || !rendersContent(sling) {
+		return
+	}
+	obs, err := s.objectBucket(board, false)
+	if err != nil {
+		log.Printf("Failed to open object store of board %s: %v", board, err)
+		return
+	}
+	data, err := obs.GetBytes(sling.ObjectID)
+	if err != nil {
+		log.Printf("Failed to read object %s of board %s: %v", sling.ObjectID, board, err)
+		return
+	}
+	sling.Content = data
+}
+
+// deleteObject drops the stored body of a deleted sling.
+func (s *service) deleteObject(board string, id string) error {
+	obs, err := s.objectBucket(board, false)
+	if err != nil {
+		if errors.Is(err, nats.ErrStreamNotFound) {
+			return nil
+		}
+		return err
+	}
+	if err := obs.Delete(id); err != nil && !errors.Is(err, nats.ErrObjectNotFound) {
+		return err
+	}
+	return nil
+}
+
+// objectSource returns where a card loads the body of a file sling from:
+// its board object, or a data URL for slings stored inline.
+func objectSource(board string, sling *slingmessage.SlingMessage) string {
+	if sling.ObjectID == "" {
+		return "data:" + sling.MimeType + ";base64," + base64.StdEncoding.EncodeToString(sling.Content)
+	}
+	return objectURL(board, sling.ObjectID)
+}
+
+// objectURL is the board-scoped path a stored object is served from.
+func objectURL(board string, id string) string {
+	return "/board/" + url.PathEscape(board) + "/" + objectPathSegment + "/" + url.PathEscape(id)
+}
+
+// handleBoardObject serves GET /board/{name}/objects/{id} with the stored
+// body of a file sling, or the byte range the request asks for. Objects
+// never change once stored, so they are cached for good.
+func (s *service) handleBoardObject(msg *nats.Msg) {
+	tokens := strings.Split(strings.TrimPrefix(msg.Subject, boardSubjectPrefix), ".")
+	if len(tokens) != 3 || !strings.EqualFold(tokens[1], objectPathSegment) {
+		s.respondError(msg, http.StatusNotFound, "object not found")
+		return
+	}
+	board, ok := boardFromSubject(boardSubjectPrefix+tokens[0], boardSubjectPrefix)
+	if !ok {
+		s.respondError(msg, http.StatusNotFound, "board not found")
+		return
+	}
+	id, err := url.PathUnescape(tokens[2])
+	if err != nil || id == "" {
+		s.respondError(msg, http.StatusNotFound, "object not found")
+		return
+	}
+
+	obs, err := s.objectBucket(board, false)
+	if err != nil {
+		if errors.Is(err, nats.ErrStreamNotFound) {
+			s.respondError(msg, http.StatusNotFound, "object not found")
+			return
+		}
+		s.respondError(msg, http.StatusInternalServerError, "failed to open object store")
+		return
+	}
+	info, err := obs.GetInfo(id)
+	if err != nil {
+		if errors.Is(err, nats.ErrObjectNotFound) {
+			s.respondError(msg, http.StatusNotFound, "object not found")
+			return
+		}
+		s.respondError(msg, http.StatusInternalServerError, "failed to read object")
+		return
+	}
+
+	contentType := info.Headers.Get("Content-Type")
+	if contentType == "" {
+		contentType = defaultObjectMime
+	}
+	header := nats.Header{
+		"Content-Type":  []string{contentType},
+		"Cache-Control": []string{objectCacheHeader},
+		"Accept-Ranges": []string{"bytes"},
+		"ETag":          []string{strconv.Quote(info.Digest)},
+	}
+	// Objects are uploaded by anyone, so browsers must not run them as
+	// pages of the board: only media the board shows itself is inline.
+	// PDFs are left out of the sandbox, which browsers' PDF viewers refuse.
+	header.Set("X-Content-Type-Options", "nosniff")
+	disposition := "attachment"
+	if inlineObject(contentType) {
+		disposition = "inline"
+	}
+	if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType != pdfMimeType {
+		header.Set("Content-Security-Policy", "sandbox")
+	}
+	params := map[string]string{}
+	if info.Description != "" {
+		params["filename"] = info.Description
+	}
+	header.Set("Content-Disposition", mime.FormatMediaType(disposition, params))
+
+	// Players seek in video and audio with range requests, so a single byte
+	// range is served as partial content, cut to what fits in one NATS
+	// message; players request the rest as they go.
+	limit := s.maxRangeLength()
+	value := msg.Header.Get("Range")
+	if value == "" && info.Size > limit {
+		header.Set("Status-Code", strconv.Itoa(http.StatusOK))
+		s.streamObject(msg, header, obs, id, limit)
+		return
+	}
+	status := http.StatusOK
+	start, length := uint64(0), info.Size
+	if value != "" {
+		var ok bool
+		start, length, ok = byteRange(value, info.Size)
+		if !ok {
+			header.Set("Content-Range", fmt.Sprintf("bytes */%d", info.Size))
+			header.Set("Status-Code", strconv.Itoa(http.StatusRequestedRangeNotSatisfiable))
+			s.respondObject(msg, header, nil)
+			return
+		}
+		if length > limit {
+			length = limit
+		}
+		status = http.StatusPartialContent
+		header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, start+length-1, info.Size))
+	}
+
+	data, err := s.objectRange(info, start, length)
+	if err != nil {
+		s.respondError(msg, http.StatusInternalServerError, "failed to read object")
+		return
+	}
+	header.Set("Status-Code", strconv.Itoa(status))
+	s.respondObject(msg, header, data)
+}
+
+// streamObject answers a request for a whole object too large for one NATS
+// message by streaming it in parts.
+func (s *service) streamObject(msg *nats.Msg, header nats.Header, obs nats.ObjectStore, id string, partSize uint64) {
+	object, err := obs.Get(id)
+	if err != nil {
+		s.respondError(msg, http.StatusInternalServerError, "failed to read object")
+		return
+	}
+	defer object.Close()
+	s.streamResponse(msg, header, object, partSize)
+}
+
+// streamResponse answers a request with a chunked response read from body:
+// the first part carries the headers and no Content-Length, the rest follow
+// as plain parts of at most partSize bytes, and an empty message ends the
+// body.
+func (s *service) streamResponse(msg *nats.Msg, header nats.Header, body io.Reader, partSize uint64) {
+	buf := make([]byte, partSize)
+	for {
+		n, err := io.ReadFull(body, buf)
+		if n > 0 {
+			part := &nats.Msg{Subject: msg.Reply, Header: header, Data: buf[:n]}
+			if perr := s.nc.PublishMsg(part); perr != nil {
+				log.Printf("Failed to respond to %s: %v", msg.Subject, perr)
+				return
+			}
+			// Only the first part carries headers.
+			header = nil
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			// The status is sent already, so the body just ends early.
+			log.Printf("Failed to stream response to %s: %v", msg.Subject, err)
+			break
+		}
+	}
+	if err := s.nc.Publish(msg.Reply, nil); err != nil {
+		log.Printf("Failed to respond to %s: %v", msg.Subject, err)
+	}
+}
+
+// objectRange reads length bytes of a stored object from start. Objects are
+// stored as chunks on a subject of their own, so a headers-only consumer
+// walks their sizes and only the chunks holding the range are fetched.
+func (s *service) objectRange(info *nats.ObjectInfo, start uint64, length uint64) ([]byte, error) {
+	if length == 0 {
+		return nil, nil
+	}
+	streamName := "OBJ_" + info.Bucket
+	sub, err := s.js.SubscribeSync("$O."+info.Bucket+".C."+info.NUID, nats.OrderedConsumer(), nats.HeadersOnly(), nats.BindStream(streamName))
+	if err != nil {
+		return nil, err
+	}
+	defer sub.Unsubscribe()
+
+	data := make([]byte, 0, length)
+	end := start + length
+	for offset, index := uint64(0), uint32(0); offset < end && index < info.Chunks; index++ {
+		chunk, err := sub.NextMsg(5 * time.Second)
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.ParseUint(chunk.Header.Get(nats.MsgSize), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		chunkStart := offset
+		offset += size
+		if offset <= start {
+			continue
+		}
+
+		meta, err := chunk.Metadata()
+		if err != nil {
+			return nil, err
+		}
+		raw, err := s.js.GetMsg(streamName, meta.Sequence.Stream)
+		if err != nil {
+			return nil, err
+		}
+		from, to := uint64(0), uint64(len(raw.Data))
+		if start > chunkStart {
+			from = start - chunkStart
+		}
+		if end < offset {
+			to -= offset - end
+		}
+		if from < to {
+			data = append(data, raw.Data[from:to]...)
+		}
+	}
+	if uint64(len(data)) != length {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return data, nil
+}
+
+// inlineObjectTypes are the image types browsers show as pictures. Other
+// images, such as SVG, can carry scripts and are downloaded.
+var inlineObjectTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+	"image/avif": true,
+	"image/bmp":  true,
+	pdfMimeType:  true,
+}
+
+// inlineObject reports whether an object of the content type is shown in
+// the browser rather than downloaded.
+func inlineObject(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return inlineObjectTypes[mediaType] || isMediaMime(mediaType)
+}
+
+// startObjectSweep deletes, every objectSweepInterval until shutdown, the
+// stored objects no sling refers to any more.
+func (s *service) startObjectSweep() {
+	go func() {
+		ticker := time.NewTicker(objectSweepInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.done:
+				return
+			case <-ticker.C:
+				boards, err := s.listBoards()
+				if err != nil {
+					log.Printf("Failed to list boards for the object sweep: %v", err)
+					continue
+				}
+				for _, board := range boards {
+					if err := s.sweepObjects(board, time.Now().Add(-objectSweepInterval)); err != nil {
+						log.Printf("Failed to sweep objects of board %s: %v", board, err)
+					}
+				}
+			}
+		}
+	}()
+}
+
+// sweepObjects deletes the objects of a board stored before the given time
+// whose sling has aged out of the stream, or was edited to text, and is not
+// pinned. Newer objects are kept, since their sling may still be on its way.
+func (s *service) sweepObjects(board string, before time.Time) error {
+	obs, err := s.objectBucket(board, false)
+	if err != nil {
+		if errors.Is(err, nats.ErrStreamNotFound) {
+			return nil
+		}
+		return err
+	}
+	objects, err := obs.List()
+	if err != nil {
+		if errors.Is(err, nats.ErrNoObjectsFound) {
+			return nil
+		}
+		return err
+	}
+	pinned, err := s.pinnedSlings(board)
+	if err != nil {
+		return err
+	}
+	referenced := make(map[string]bool, len(pinned))
+	for _, sling := range pinned {
+		referenced[sling.ObjectID] = true
+	}
+
+	for _, object := range objects {
+		if referenced[object.Name] || !object.ModTime.Before(before) {
+			continue
+		}
+		sling, err := s.findStoredSling(board, object.Name)
+		if err != nil {
+			return err
+		}
+		if sling != nil && sling.ObjectID == object.Name {
+			continue
+		}
+		if err := obs.Delete(object.Name); err != nil && !errors.Is(err, nats.ErrObjectNotFound) {
+			return err
+		}
+		log.Printf("Deleted object %s of board %s, no sling refers to it", object.Name, board)
+	}
+	return nil
+}
+
+// maxRangeLength is the largest partial response that fits in a NATS
+// message next to its headers.
+func (s *service) maxRangeLength() uint64 {
+	return uint64(max(s.nc.MaxPayload()-rangeHeaderAllowance, rangeHeaderAllowance))
+}
+
+func (s *service) respondObject(msg *nats.Msg, header nats.Header, data []byte) {
+	header.Set("Content-Length", strconv.Itoa(len(data)))
+	if err := msg.RespondMsg(&nats.Msg{Header: header, Data: data}); err != nil {
+		log.Printf("Failed to respond to %s: %v", msg.Subject, err)
+	}
+}
+
+// byteRange parses a single HTTP byte range against an object of the given
+// size and returns its start and length. Multiple ranges are not supported.
+func byteRange(value string, size uint64) (uint64, uint64, bool) {
+	spec, ok := strings.CutPrefix(strings.TrimSpace(value), "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return 0, 0, false
+	}
+	from, to, ok := strings.Cut(spec, "-")
+	if !ok {
+		return 0, 0, false
+	}
+
+	if from == "" {
+		suffix, err := strconv.ParseUint(to, 10, 64)
+		if err != nil || suffix == 0 || size == 0 {
+			return 0, 0, false
+		}
+		if suffix > size {
+			suffix = size
+		}
+		return size - suffix, suffix, true
+	}
+
+	start, err := strconv.ParseUint(from, 10, 64)
+	if err != nil || start >= size {
+		return 0, 0, false
+	}
+	end := size - 1
+	if to != "" {
+		end, err = strconv.ParseUint(to, 10, 64)
+		if err != nil || end < start {
+			return 0, 0, false
+		}
+		if end >= size {
+			end = size - 1
+		}
+	}
+	return start, end - start + 1, true
+}
@@ -0,0 +1,274 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/laetho/slingboard/internal/commands"
+	"github.com/laetho/slingboard/internal/slingmessage"
+	"github.com/laetho/slingboard/templates"
+	"github.com/nats-io/nats.go"
+)
+
+const pinBucketPrefix = "sb_pins_"
+
+// pinBucket returns the KV bucket holding the pinned slings of a board. Pinned
+// slings are stored in full so they outlive the board stream's retention.
+func (s *service) pinBucket(board string, create bool) (nats.KeyValue, error) {
+	bucket := pinBucketPrefix + board
+	kv, err := s.js.KeyValue(bucket)
+	if err == nil || !create || !errors.Is(err, nats.ErrBucketNotFound) {
+		return kv, err
+	}
+
+	return s.js.CreateKeyValue(&nats.KeyValueConfig{
+		Bucket:  bucket,
+		Storage: nats.FileStorage,
+	})
+}
+
+// pinnedSlings returns the pinned slings of a board, newest first.
+func (s *service) pinnedSlings(board string) ([]slingmessage.SlingMessage, error) {
+	kv, err := s.pinBucket(board, false)
+	if err != nil {
+		if errors.Is(err, nats.ErrBucketNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	keys, err := kv.Keys()
+	if err != nil {
+		if errors.Is(err, nats.ErrNoKeysFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	slings := make([]slingmessage.SlingMessage, 0, len(keys))
+	for _, key := range keys {
+		entry, err := kv.Get(key)
+		if err != nil {
+			if errors.Is(err, nats.ErrKeyNotFound) {
+				continue
+			}
+			return nil, err
+		}
+
+		var sling slingmessage.SlingMessage
+		if err := json.Unmarshal(entry.Value(), &sling); err != nil {
+			continue
+		}
+		if !sling.ExpiresAt.IsZero() && !sling.ExpiresAt.After(time.Now()) {
+			continue
+		}
+		slings = append(slings, sling)
+	}
+
+	sort.Slice(slings, func(i, j int) bool {
+		return slings[i].Timestamp.After(slings[j].Timestamp)
+	})
+	return slings, nil
+}
+
+// renderPinnedSlings renders the pinned slings of a board with their
+// threads.
+func (s *service) renderPinnedSlings(board string, slings []slingmessage.SlingMessage) (string, error) {
+	if len(slings) == 0 {
+		return "", nil
+	}
+	reactions, err := s.boardReactions(board)
+	if err != nil {
+		return "", err
+	}
+
+	var buf strings.Builder
+	for i := range slings {
+		thread, err := s.slingThread(board, slings[i].ID, 0)
+		if err != nil {
+			return "", err
+		}
+		replyCards, err := s.renderReplies(board, thread, reactions)
+		if err != nil {
+			return "", err
+		}
+		s.loadObjectContent(board, &slings[i])
+		card, err := renderSlingCard(&slings[i], templates.SlingMeta{
+			Board:     board,
+			Reactions: reactionView(reactions[slings[i].ID]),
+			Replies:   replyCards,
+		})
+		if err != nil {
+			return "", err
+		}
+		buf.WriteString(card)
+	}
+	return buf.String(), nil
+}
+
+// slingIDs returns the IDs of slings, such as the pinned ones the board
+// history leaves out.
+func slingIDs(slings []slingmessage.SlingMessage) map[string]struct{} {
+	ids := make(map[string]struct{}, len(slings))
+	for _, sling := range slings {
+		ids[sling.ID] = struct{}{}
+	}
+	return ids
+}
+
+func (s *service) handleSlingPin(msg *nats.Msg, request commands.CommandRequest, pinned bool) {
+	id := strings.TrimSpace(request.ID)
+	if id == "" {
+		s.respondCommandError(msg, http.StatusBadRequest, "sling id is required")
+		return
+	}
+	board := normalizeBoardName(request.Board)
+	if board == "" {
+		board = defaultBoard
+	}
+
+	if _, err := s.ensureBoardStream(board); err != nil {
+		s.respondCommandError(msg, http.StatusInternalServerError, "failed to ensure board stream")
+		return
+	}
+
+	kv, err := s.pinBucket(board, true)
+	if err != nil {
+		s.respondCommandError(msg, http.StatusInternalServerError, "failed to open pin bucket")
+		return
+	}
+
+	var sling *slingmessage.SlingMessage
+	if pinned {
+		sling, err = s.findStoredSling(board, id)
+		if err != nil {
+			s.respondCommandError(msg, http.StatusInternalServerError, "failed to look up sling")
+			return
+		}
+	} else {
+		sling, err = pinnedSling(kv, id)
+		if err != nil {
+			s.respondCommandError(msg, http.StatusInternalServerError, "failed to look up pinned sling")
+			return
+		}
+	}
+	if sling == nil {
+		s.respondCommandError(msg, http.StatusNotFound, "sling not found")
+		return
+	}
+
+	if pinned {
+		data, err := json.Marshal(sling)
+		if err != nil {
+			s.respondCommandError(msg, http.StatusInternalServerError, "failed to encode sling")
+			return
+		}
+		if _, err := kv.Put(id, data); err != nil {
+			s.respondCommandError(msg, http.StatusInternalServerError, "failed to pin sling")
+			return
+		}
+	} else if err := kv.Delete(id); err != nil {
+		s.respondCommandError(msg, http.StatusInternalServerError, "failed to unpin sling")
+		return
+	}
+
+	reactions, err := s.boardReactions(board)
+	if err != nil {
+		s.respondCommandError(msg, http.StatusInternalServerError, "failed to load reactions")
+		return
+	}
+	thread, err := s.slingThread(board, id, 0)
+	if err != nil {
+		s.respondCommandError(msg, http.StatusInternalServerError, "failed to load replies")
+		return
+	}
+	replyCards, err := s.renderReplies(board, thread, reactions)
+	if err != nil {
+		s.respondCommandError(msg, http.StatusInternalServerError, "failed to render replies")
+		return
+	}
+	s.loadObjectContent(board, sling)
+	card, err := renderSlingCard(sling, templates.SlingMeta{
+		Board:     board,
+		Reactions: reactionView(reactions[id]),
+		Replies:   replyCards,
+	})
+	if err != nil {
+		s.respondCommandError(msg, http.StatusInternalServerError, "failed to render sling")
+		return
+	}
+	var buf bytes.Buffer
+	component := templates.SlingMoved(id, "#slings", card)
+	if pinned {
+		component = templates.SlingMoved(id, "#pinned", card)
+	}
+	if err := component.Render(context.Background(), &buf); err != nil {
+		s.respondCommandError(msg, http.StatusInternalServerError, "failed to render sling")
+		return
+	}
+	if err := s.broadcast(board, buf.Bytes()); err != nil {
+		s.respondCommandError(msg, http.StatusBadGateway, "failed to broadcast pin")
+		return
+	}
+
+	message := "pinned"
+	if !pinned {
+		message = "unpinned"
+	}
+	s.respondJSON(msg, http.StatusOK, commands.CommandResponse{
+		ID:        id,
+		Status:    "ok",
+		Message:   message,
+		Board:     board,
+		Timestamp: time.Now().UTC(),
+	})
+}
+
+func pinnedSling(kv nats.KeyValue, id string) (*slingmessage.SlingMessage, error) {
+	entry, err := kv.Get(id)
+	if err != nil {
+		if errors.Is(err, nats.ErrKeyNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var sling slingmessage.SlingMessage
+	if err := json.Unmarshal(entry.Value(), &sling); err != nil {
+		return nil, err
+	}
+	return &sling, nil
+}
+
+// syncPinnedSling keeps the stored copy of a pinned sling in step with edits
+// and deletes. A nil sling removes the pin.
+func (s *service) syncPinnedSling(board string, id string, sling *slingmessage.SlingMessage) error {
+	kv, err := s.pinBucket(board, false)
+	if err != nil {
+		if errors.Is(err, nats.ErrBucketNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	current, err := pinnedSling(kv, id)
+	if err != nil || current == nil {
+		return err
+	}
+	if sling == nil {
+		return kv.Delete(id)
+	}
+
+	data, err := json.Marshal(sling)
+	if err != nil {
+		return err
+	}
+	_, err = kv.Put(id, data)
+	return err
+}
@@ -0,0 +1,365 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/laetho/slingboard/internal/commands"
+	"github.com/laetho/slingboard/internal/slingmessage"
+	"github.com/laetho/slingboard/templates"
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	reactionBucketPrefix = "sb_reactions_"
+	maxReactionBytes     = 32
+	maxReactionsPerSling = 20
+	reactionUpdateTries  = 5
+)
+
+var (
+	errTooManyReactions = errors.New("too many different reactions on this sling")
+	errReactionConflict = errors.New("sling is receiving too many reactions at once, try again")
+	errReactionNotFound = errors.New("no reaction of yours to remove")
+)
+
+// reactionBucket returns the KV bucket holding the reactions of a board,
+// keyed by sling ID.
+func (s *service) reactionBucket(board string, create bool) (nats.KeyValue, error) {
+	bucket := reactionBucketPrefix + board
+	kv, err := s.js.KeyValue(bucket)
+	if err == nil || !create || !errors.Is(err, nats.ErrBucketNotFound) {
+		return kv, err
+	}
+
+	return s.js.CreateKeyValue(&nats.KeyValueConfig{
+		Bucket:  bucket,
+		Storage: nats.FileStorage,
+	})
+}
+
+func storedReactions(kv nats.KeyValue, id string) ([]slingmessage.Reaction, uint64, error) {
+	entry, err := kv.Get(id)
+	if err != nil {
+		if errors.Is(err, nats.ErrKeyNotFound) {
+			return nil, 0, nil
+		}
+		return nil, 0, err
+	}
+
+	var reactions []slingmessage.Reaction
+	if err := json.Unmarshal(entry.Value(), &reactions); err != nil {
+		return nil, 0, err
+	}
+	return reactions, entry.Revision(), nil
+}
+
+// slingReactions returns the reactions of one sling.
+func (s *service) slingReactions(board string, id string) ([]slingmessage.Reaction, error) {
+	kv, err := s.reactionBucket(board, false)
+	if err != nil {
+		if errors.Is(err, nats.ErrBucketNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	reactions, _, err := storedReactions(kv, id)
+	return reactions, err
+}
+
+// boardReactions returns the reactions of every sling on a board that has
+// any, keyed by sling ID.
+func (s *service) boardReactions(board string) (map[string][]slingmessage.Reaction, error) {
+	kv, err := s.reactionBucket(board, false)
+	if err != nil {
+		if errors.Is(err, nats.ErrBucketNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	keys, err := kv.Keys()
+	if err != nil {
+		if errors.Is(err, nats.ErrNoKeysFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	all := make(map[string][]slingmessage.Reaction, len(keys))
+	for _, key := range keys {
+		reactions, _, err := storedReactions(kv, key)
+		if err != nil {
+			continue
+		}
+		all[key] = reactions
+	}
+	return all, nil
+}
+
+// addReaction counts one more reaction on a sling. A named author counts
+// once per emoji.
+func (s *service) addReaction(board string, id string, emoji string, author string) ([]slingmessage.Reaction, error) {
+	return s.updateReactions(board, id, true, func(reactions []slingmessage.Reaction) ([]slingmessage.Reaction, error) {
+		for i := range reactions {
+			if reactions[i].Emoji != emoji {
+				continue
+			}
+			if author != "" {
+				if slices.Contains(reactions[i].Authors, author) {
+					return reactions, nil
+				}
+				reactions[i].Authors = append(reactions[i].Authors, author)
+			}
+			reactions[i].Count++
+			return reactions, nil
+		}
+		if len(reactions) >= maxReactionsPerSling {
+			return nil, errTooManyReactions
+		}
+		reaction := slingmessage.Reaction{Emoji: emoji, Count: 1}
+		if author != "" {
+			reaction.Authors = []string{author}
+		}
+		return append(reactions, reaction), nil
+	})
+}
+
+// removeReaction takes back the reaction an author added to a sling.
+func (s *service) removeReaction(board string, id string, emoji string, author string) ([]slingmessage.Reaction, error) {
+	return s.updateReactions(board, id, false, func(reactions []slingmessage.Reaction) ([]slingmessage.Reaction, error) {
+		for i := range reactions {
+			index := slices.Index(reactions[i].Authors, author)
+			if reactions[i].Emoji != emoji || index < 0 {
+				continue
+			}
+			reactions[i].Authors = slices.Delete(reactions[i].Authors, index, index+1)
+			reactions[i].Count--
+			if reactions[i].Count <= 0 {
+				reactions = slices.Delete(reactions, i, i+1)
+			}
+			return reactions, nil
+		}
+		return nil, errReactionNotFound
+	})
+}
+
+// updateReactions applies change to the reactions of a sling. Concurrent
+// reactions are resolved by retrying on the KV revision.
+func (s *service) updateReactions(board string, id string, create bool, change func([]slingmessage.Reaction) ([]slingmessage.Reaction, error)) ([]slingmessage.Reaction, error) {
+	kv, err := s.reactionBucket(board, create)
+	if err != nil {
+		if errors.Is(err, nats.ErrBucketNotFound) {
+			return nil, errReactionNotFound
+		}
+		return nil, err
+	}
+
+	for range reactionUpdateTries {
+		reactions, revision, err := storedReactions(kv, id)
+		if err != nil {
+			return nil, err
+		}
+		reactions, err = change(reactions)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case len(reactions) == 0:
+			err = kv.Delete(id, nats.LastRevision(revision))
+		case revision == 0:
+			var data []byte
+			if data, err = json.Marshal(reactions); err == nil {
+				_, err = kv.Create(id, data)
+			}
+		default:
+			var data []byte
+			if data, err = json.Marshal(reactions); err == nil {
+				_, err = kv.Update(id, data, revision)
+			}
+		}
+		if err == nil {
+			return reactions, nil
+		}
+		var apiErr *nats.APIError
+		if !errors.Is(err, nats.ErrKeyExists) && !(errors.As(err, &apiErr) && apiErr.ErrorCode == nats.JSErrCodeStreamWrongLastSequence) {
+			return nil, err
+		}
+	}
+	return nil, errReactionConflict
+}
+
+// deleteReactions drops the reactions of a deleted sling.
+func (s *service) deleteReactions(board string, id string) error {
+	kv, err := s.reactionBucket(board, false)
+	if err != nil {
+		if errors.Is(err, nats.ErrBucketNotFound) {
+			return nil
+		}
+		return err
+	}
+	if err := kv.Delete(id); err != nil && !errors.Is(err, nats.ErrKeyNotFound) {
+		return err
+	}
+	return nil
+}
+
+// validReaction accepts a single emoji: a pictograph with optional
+// variation selectors, skin tones and tags, several of them joined with
+// zero-width joiners, a keycap or a flag.
+func validReaction(reaction string) bool {
+	if reaction == "" || len(reaction) > maxReactionBytes {
+		return false
+	}
+	runes := []rune(reaction)
+	if len(runes) == 2 && isRegionalIndicator(runes[0]) && isRegionalIndicator(runes[1]) {
+		return true
+	}
+	if strings.ContainsRune("0123456789#*", runes[0]) {
+		rest := string(runes[1:])
+		return rest == "\u20e3" || rest == "\ufe0f\u20e3"
+	}
+
+	for i := 0; ; i++ {
+		if i == len(runes) || !isPictograph(runes[i]) {
+			return false
+		}
+		for i+1 < len(runes) && isEmojiModifier(runes[i+1]) {
+			i++
+		}
+		if i+1 == len(runes) {
+			return true
+		}
+		if runes[i+1] != '\u200d' {
+			return false
+		}
+		i++
+	}
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1f1e6 && r <= 0x1f1ff
+}
+
+// isPictograph reports whether r is in one of the blocks emoji are drawn from.
+func isPictograph(r rune) bool {
+	switch {
+	case isRegionalIndicator(r), r >= 0x1f3fb && r <= 0x1f3ff:
+		return false
+	case r >= 0x1f000 && r <= 0x1faff,
+		r >= 0x2190 && r <= 0x21ff,
+		r >= 0x2300 && r <= 0x23ff,
+		r >= 0x25a0 && r <= 0x27bf,
+		r >= 0x2900 && r <= 0x297f,
+		r >= 0x2b00 && r <= 0x2bff:
+		return true
+	}
+	return strings.ContainsRune("\u00a9\u00ae\u203c\u2049\u2122\u2139\u3030\u303d\u3297\u3299", r)
+}
+
+// isEmojiModifier reports whether r changes the emoji before it: variation
+// selectors, skin tones and the tags of subdivision flags.
+func isEmojiModifier(r rune) bool {
+	return r == '\ufe0e' || r == '\ufe0f' || (r >= 0x1f3fb && r <= 0x1f3ff) || (r >= 0xe0020 && r <= 0xe007f)
+}
+
+// handleSlingReact adds a reaction to a sling, or takes one back when add is
+// false, and updates the reactions on every screen showing the board.
+func (s *service) handleSlingReact(msg *nats.Msg, request commands.CommandRequest, add bool) {
+	id := strings.TrimSpace(request.ID)
+	if id == "" {
+		s.respondCommandError(msg, http.StatusBadRequest, "sling id is required")
+		return
+	}
+	reaction := strings.TrimSpace(request.Reaction)
+	if !validReaction(reaction) {
+		s.respondCommandError(msg, http.StatusBadRequest, "reaction must be a single emoji")
+		return
+	}
+	author := strings.TrimSpace(request.Author)
+	if !add && author == "" {
+		s.respondCommandError(msg, http.StatusBadRequest, "author is required to remove a reaction")
+		return
+	}
+	board := normalizeBoardName(request.Board)
+	if board == "" {
+		board = defaultBoard
+	}
+
+	if _, err := s.ensureBoardStream(board); err != nil {
+		s.respondCommandError(msg, http.StatusInternalServerError, "failed to ensure board stream")
+		return
+	}
+
+	sling, err := s.findStoredSling(board, id)
+	if err != nil {
+		s.respondCommandError(msg, http.StatusInternalServerError, "failed to look up sling")
+		return
+	}
+	if sling == nil {
+		if kv, err := s.pinBucket(board, false); err == nil {
+			sling, _ = pinnedSling(kv, id)
+		}
+	}
+	if sling == nil {
+		s.respondCommandError(msg, http.StatusNotFound, "sling not found")
+		return
+	}
+
+	var reactions []slingmessage.Reaction
+	message := "reacted"
+	if add {
+		reactions, err = s.addReaction(board, id, reaction, author)
+	} else {
+		reactions, err = s.removeReaction(board, id, reaction, author)
+		message = "reaction removed"
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, errReactionNotFound):
+			s.respondCommandError(msg, http.StatusNotFound, err.Error())
+		case errors.Is(err, errTooManyReactions):
+			s.respondCommandError(msg, http.StatusBadRequest, err.Error())
+		case errors.Is(err, errReactionConflict):
+			s.respondCommandError(msg, http.StatusConflict, err.Error())
+		default:
+			s.respondCommandError(msg, http.StatusInternalServerError, "failed to store reaction")
+		}
+		return
+	}
+
+	var buf bytes.Buffer
+	component := templates.SlingReactionsChanged(id, reactionView(reactions))
+	if err := component.Render(context.Background(), &buf); err != nil {
+		s.respondCommandError(msg, http.StatusInternalServerError, "failed to render reactions")
+		return
+	}
+	if err := s.broadcast(board, buf.Bytes()); err != nil {
+		s.respondCommandError(msg, http.StatusBadGateway, "failed to broadcast reactions")
+		return
+	}
+
+	s.respondJSON(msg, http.StatusOK, commands.CommandResponse{
+		ID:        id,
+		Status:    "ok",
+		Message:   message,
+		Board:     board,
+		Timestamp: time.Now().UTC(),
+	})
+}
+
+func reactionView(reactions []slingmessage.Reaction) []templates.Reaction {
+	view := make([]templates.Reaction, 0, len(reactions))
+	for _, reaction := range reactions {
+		view = append(view, templates.Reaction{Emoji: reaction.Emoji, Count: reaction.Count, Authors: reaction.Authors})
+	}
+	return view
+}
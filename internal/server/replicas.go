@@ -0,0 +1,450 @@
+package server
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	instanceBucket  = "sb_instances"
+	websocketBucket = "sb_websockets"
+
+	// Every instance renews its lease each instanceHeartbeat. Websockets of
+	// an instance whose lease has lapsed are taken over by its peers.
+	instanceHeartbeat = 5 * time.Second
+	instanceLeaseTTL  = 3 * instanceHeartbeat
+
+	// Owner records are renewed together with the lease and outlive a
+	// lapsed lease by one more, which is how long peers have to take the
+	// websockets over.
+	websocketOwnerTTL = 2 * instanceLeaseTTL
+
+	websocketConsumerPrefix = "ws-"
+)
+
+// websocketOwner records which instance serves a websocket, stored in the
+// websocket bucket under the encoded reply subject. Seq is the last stream
+// sequence the board feed had sent when the record was written, which a
+// peer taking the websocket over replays from.
+type websocketOwner struct {
+	Board    string `json:"board"`
+	Reply    string `json:"reply"`
+	Instance string `json:"instance"`
+	Seq      uint64 `json:"seq,omitempty"`
+}
+
+// ownerRecord is an owner record as last seen by the websocket bucket
+// watcher, with the revision a takeover updates.
+type ownerRecord struct {
+	owner    websocketOwner
+	revision uint64
+}
+
+// websocketKey encodes a reply subject as a KV key.
+func websocketKey(reply string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(reply))
+}
+
+// instanceBucketKV returns the KV bucket holding the leases of running
+// instances, keyed by instance ID. Leases expire unless renewed.
+func (s *service) instanceBucketKV() (nats.KeyValue, error) {
+	kv, err := s.js.KeyValue(instanceBucket)
+	if err == nil || !errors.Is(err, nats.ErrBucketNotFound) {
+		return kv, err
+	}
+	return s.js.CreateKeyValue(&nats.KeyValueConfig{
+		Bucket:  instanceBucket,
+		Storage: nats.FileStorage,
+		TTL:     instanceLeaseTTL,
+	})
+}
+
+// websocketBucketKV returns the KV bucket recording the owner of every open
+// websocket across instances. Records expire unless their owner renews them,
+// and a bucket made by an earlier version without an expiry gets one.
+func (s *service) websocketBucketKV() (nats.KeyValue, error) {
+	kv, err := s.js.KeyValue(websocketBucket)
+	if errors.Is(err, nats.ErrBucketNotFound) {
+		return s.js.CreateKeyValue(&nats.KeyValueConfig{
+			Bucket:  websocketBucket,
+			Storage: nats.FileStorage,
+			TTL:     websocketOwnerTTL,
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.ownersMu.Lock()
+	defer s.ownersMu.Unlock()
+	if !s.ownerTTLChecked {
+		info, err := s.js.StreamInfo("KV_" + websocketBucket)
+		if err != nil {
+			return nil, err
+		}
+		if info.Config.MaxAge != websocketOwnerTTL {
+			config := info.Config
+			config.MaxAge = websocketOwnerTTL
+			if _, err := s.js.UpdateStream(&config); err != nil {
+				return nil, err
+			}
+		}
+		s.ownerTTLChecked = true
+	}
+	return kv, nil
+}
+
+// startReplica takes the lease of this instance and keeps renewing it, and
+// the owner records of its websockets, until shutdown. Owner records are
+// followed with a watch, so peers that stopped are noticed without reading
+// the whole bucket.
+func (s *service) startReplica() error {
+	if err := s.renewLease(); err != nil {
+		return err
+	}
+	if err := s.watchWebsocketOwners(); err != nil {
+		return err
+	}
+	go func() {
+		ticker := time.NewTicker(instanceHeartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.done:
+				return
+			case <-ticker.C:
+				if err := s.renewLease(); err != nil {
+					log.Printf("Failed to renew instance lease: %v", err)
+					continue
+				}
+				s.renewWebsockets()
+				s.balanceWebsockets()
+			}
+		}
+	}()
+	return nil
+}
+
+func (s *service) renewLease() error {
+	kv, err := s.instanceBucketKV()
+	if err != nil {
+		return err
+	}
+	_, err = kv.Put(s.id, []byte(time.Now().UTC().Format(time.RFC3339)))
+	return err
+}
+
+// releaseLease drops the lease of this instance on shutdown, so peers take
+// over its websockets at their next heartbeat instead of after the lease
+// expires.
+func (s *service) releaseLease() {
+	kv, err := s.instanceBucketKV()
+	if err != nil {
+		return
+	}
+	if err := kv.Delete(s.id); err != nil {
+		log.Printf("Failed to release instance lease: %v", err)
+	}
+}
+
+// liveInstances returns the IDs of the instances holding a lease.
+func (s *service) liveInstances() (map[string]bool, error) {
+	kv, err := s.instanceBucketKV()
+	if err != nil {
+		return nil, err
+	}
+	keys, err := kv.Keys()
+	if err != nil && !errors.Is(err, nats.ErrNoKeysFound) {
+		return nil, err
+	}
+	live := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		live[key] = true
+	}
+	return live, nil
+}
+
+// instanceAlive reports whether an instance holds a lease. Instances that
+// cannot be checked are taken to be alive.
+func (s *service) instanceAlive(id string) bool {
+	kv, err := s.instanceBucketKV()
+	if err != nil {
+		return true
+	}
+	_, err = kv.Get(id)
+	return !errors.Is(err, nats.ErrKeyNotFound)
+}
+
+// claimWebsocket records this instance as the owner of a newly opened
+// websocket.
+func (s *service) claimWebsocket(board string, reply string) {
+	kv, err := s.websocketBucketKV()
+	if err != nil {
+		log.Printf("Failed to open websocket bucket: %v", err)
+		return
+	}
+	owner := websocketOwner{Board: board, Reply: reply, Instance: s.id, Seq: s.feedSeq(board)}
+	value, _ := json.Marshal(owner)
+	revision, err := kv.Put(websocketKey(reply), value)
+	if err != nil {
+		log.Printf("Failed to record websocket owner: %v", err)
+		return
+	}
+	s.recordOwner(websocketKey(reply), owner, revision)
+}
+
+// renewWebsocket renews the owner record of a websocket this instance
+// serves, against the revision it last wrote or saw. A record that changed
+// meanwhile was taken over by a peer while this instance's lease had lapsed,
+// so the websocket is dropped here rather than claimed back. A record that
+// expired has no owner to lose it to and is claimed again.
+func (s *service) renewWebsocket(kv nats.KeyValue, board string, reply string) {
+	key := websocketKey(reply)
+	owner := websocketOwner{Board: board, Reply: reply, Instance: s.id, Seq: s.feedSeq(board)}
+	value, _ := json.Marshal(owner)
+
+	s.ownersMu.Lock()
+	record, ok := s.owners[key]
+	s.ownersMu.Unlock()
+	if ok && record.owner.Instance != s.id {
+		s.yieldWebsocket(key, reply)
+		return
+	}
+
+	var (
+		revision uint64
+		err      error
+	)
+	if ok {
+		revision, err = kv.Update(key, value, record.revision)
+	} else {
+		revision, err = kv.Create(key, value)
+	}
+	if errors.Is(err, nats.ErrKeyExists) {
+		entry, getErr := kv.Get(key)
+		if errors.Is(getErr, nats.ErrKeyNotFound) {
+			revision, err = kv.Create(key, value)
+		} else if getErr == nil {
+			var current websocketOwner
+			if json.Unmarshal(entry.Value(), &current) == nil && current.Instance != s.id {
+				log.Printf("Websocket on board %s was taken over by instance %s", board, current.Instance)
+				s.recordOwner(key, current, entry.Revision())
+				s.yieldWebsocket(key, reply)
+				return
+			}
+			revision, err = entry.Revision(), nil
+		}
+	}
+	if err != nil {
+		log.Printf("Failed to renew websocket owner: %v", err)
+		return
+	}
+	s.recordOwner(key, owner, revision)
+}
+
+// yieldWebsocket stops serving a websocket a peer took over. The peer hands
+// it back if it finds this instance alive after all, and the watcher then
+// resumes it.
+func (s *service) yieldWebsocket(key string, reply string) {
+	s.ownersMu.Lock()
+	s.yielded[key] = true
+	s.ownersMu.Unlock()
+	s.stopWebsocket(reply)
+}
+
+// recordOwner remembers an owner record unless a later revision of it is
+// known already, as writes of this instance and the watcher race.
+func (s *service) recordOwner(key string, owner websocketOwner, revision uint64) {
+	s.ownersMu.Lock()
+	defer s.ownersMu.Unlock()
+	if current, ok := s.owners[key]; ok && current.revision >= revision {
+		return
+	}
+	s.owners[key] = ownerRecord{owner: owner, revision: revision}
+}
+
+// releaseWebsocket forgets a closed websocket. Any instance may release it:
+// the owner when the screen goes away, and its peers when the owner has
+// stopped, so no one takes over a closed websocket.
+func (s *service) releaseWebsocket(reply string, local bool) {
+	kv, err := s.websocketBucketKV()
+	if err != nil {
+		return
+	}
+	if !local {
+		entry, err := kv.Get(websocketKey(reply))
+		if err != nil {
+			return
+		}
+		var owner websocketOwner
+		if json.Unmarshal(entry.Value(), &owner) == nil {
+			if live, err := s.liveInstances(); err != nil || live[owner.Instance] {
+				return
+			}
+		}
+	}
+	if err := kv.Delete(websocketKey(reply)); err != nil && !errors.Is(err, nats.ErrKeyNotFound) {
+		log.Printf("Failed to release websocket: %v", err)
+	}
+}
+
+// watchWebsocketOwners keeps the owner records of the websocket bucket in
+// memory until shutdown, stops serving websockets a peer took over while
+// this instance's lease had lapsed, and resumes those the peer handed back.
+func (s *service) watchWebsocketOwners() error {
+	kv, err := s.websocketBucketKV()
+	if err != nil {
+		return err
+	}
+	watcher, err := kv.WatchAll()
+	if err != nil {
+		return err
+	}
+	go func() {
+		<-s.done
+		_ = watcher.Stop()
+	}()
+	go func() {
+		for entry := range watcher.Updates() {
+			if entry == nil {
+				continue
+			}
+			if op := entry.Operation(); op == nats.KeyValueDelete || op == nats.KeyValuePurge {
+				s.ownersMu.Lock()
+				delete(s.owners, entry.Key())
+				delete(s.yielded, entry.Key())
+				s.ownersMu.Unlock()
+				continue
+			}
+			var owner websocketOwner
+			if err := json.Unmarshal(entry.Value(), &owner); err != nil {
+				continue
+			}
+			s.recordOwner(entry.Key(), owner, entry.Revision())
+			if owner.Instance != s.id {
+				if s.hasWebsocketReply(owner.Board, owner.Reply) {
+					s.yieldWebsocket(entry.Key(), owner.Reply)
+				}
+				continue
+			}
+			s.ownersMu.Lock()
+			resume := s.yielded[entry.Key()]
+			delete(s.yielded, entry.Key())
+			s.ownersMu.Unlock()
+			if resume {
+				s.startWebsocket(owner.Board, owner.Reply, owner.Seq)
+			}
+		}
+	}()
+	return nil
+}
+
+// renewWebsockets renews the owner records of the websockets this instance
+// serves, before they expire.
+func (s *service) renewWebsockets() {
+	s.wsMu.RLock()
+	conns := make([]wsConnection, 0, len(s.wsConns))
+	for _, conn := range s.wsConns {
+		conns = append(conns, *conn)
+	}
+	s.wsMu.RUnlock()
+
+	if len(conns) == 0 {
+		return
+	}
+	kv, err := s.websocketBucketKV()
+	if err != nil {
+		log.Printf("Failed to open websocket bucket: %v", err)
+		return
+	}
+	for _, conn := range conns {
+		s.renewWebsocket(kv, conn.board, conn.reply)
+	}
+}
+
+// balanceWebsockets takes over the websockets of instances without a lease.
+// Claims are compare-and-set on the owner record, so a websocket gets
+// exactly one new owner. Records not renewed by their owner expire, and the
+// watcher does not hear of that, so records that fail to update are
+// forgotten. An owner that was only slow, e.g. paused, may renew its lease
+// while the claim is made; its websockets are handed back then.
+//
+// The new owner replays from the sequence in the record, so the screen may
+// get up to a heartbeat of slings again; it drops those it already shows.
+func (s *service) balanceWebsockets() {
+	s.ownersMu.Lock()
+	orphans := make(map[string]ownerRecord)
+	for key, record := range s.owners {
+		if record.owner.Instance != s.id {
+			orphans[key] = record
+		}
+	}
+	s.ownersMu.Unlock()
+	if len(orphans) == 0 {
+		return
+	}
+
+	kv, err := s.websocketBucketKV()
+	if err != nil {
+		log.Printf("Failed to open websocket bucket: %v", err)
+		return
+	}
+	live, err := s.liveInstances()
+	if err != nil {
+		log.Printf("Failed to list instances: %v", err)
+		return
+	}
+
+	for key, record := range orphans {
+		owner := record.owner
+		if live[owner.Instance] {
+			continue
+		}
+		previous := owner
+		owner.Instance = s.id
+		claimed, _ := json.Marshal(owner)
+		revision, err := kv.Update(key, claimed, record.revision)
+		if err != nil {
+			s.ownersMu.Lock()
+			if current, ok := s.owners[key]; ok && current.revision == record.revision {
+				delete(s.owners, key)
+			}
+			delete(s.yielded, key)
+			s.ownersMu.Unlock()
+			continue
+		}
+		if s.instanceAlive(previous.Instance) {
+			original, _ := json.Marshal(previous)
+			if revision, err := kv.Update(key, original, revision); err == nil {
+				s.recordOwner(key, previous, revision)
+				continue
+			}
+		}
+		s.recordOwner(key, owner, revision)
+		log.Printf("Took over websocket on board %s from instance %s", owner.Board, previous.Instance)
+		s.startWebsocket(owner.Board, owner.Reply, owner.Seq)
+	}
+}
+
+// websocketConsumerName names a board feed consumer after the instance that
+// runs it, so instances can tell their peers' consumers apart.
+func (s *service) websocketConsumerName(id string) string {
+	return websocketConsumerPrefix + s.id + "-" + id
+}
+
+// consumerInstance returns the instance that runs a board feed consumer.
+// Consumers named before instances had IDs have none.
+func consumerInstance(consumerName string) (string, bool) {
+	rest, ok := strings.CutPrefix(consumerName, websocketConsumerPrefix)
+	if !ok {
+		return "", false
+	}
+	instance, _, ok := strings.Cut(rest, "-")
+	return instance, ok
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/laetho/slingboard/internal/slingmessage"
+	"github.com/laetho/slingboard/templates"
+)
+
+var errParentNotFound = errors.New("parent sling not found")
+
+// storedSling is a sling read back from a board stream with the sequence of
+// its original message.
+type storedSling struct {
+	seq   uint64
+	sling *slingmessage.SlingMessage
+}
+
+// replyParent returns the top-level sling a reply to parentID belongs in.
+// Replies to a reply join the thread of its parent, so threads stay one level
+// deep.
+func (s *service) replyParent(board string, parentID string) (string, error) {
+	parent, err := s.findStoredSling(board, parentID)
+	if err != nil {
+		return "", err
+	}
+	if parent == nil {
+		if kv, err := s.pinBucket(board, false); err == nil {
+			parent, _ = pinnedSling(kv, parentID)
+		}
+	}
+	if parent == nil {
+		return "", errParentNotFound
+	}
+	if parent.ParentID != "" {
+		return parent.ParentID, nil
+	}
+	return parent.ID, nil
+}
+
+// slingThread returns the replies to a sling, oldest first and at their
+// latest revision. Replies always follow their parent, so callers that know
+// the parent's stream sequence pass it as from; zero reads the whole thread.
+func (s *service) slingThread(board string, parentID string, from uint64) ([]storedSling, error) {
+	var replies []storedSling
+	positions := make(map[string]int)
+	err := s.eachSubjectSling(streamPrefix+board, threadSubject(board, parentID), from, func(seq uint64, sling *slingmessage.SlingMessage) bool {
+		if index, ok := positions[sling.ID]; ok {
+			replies[index].sling = sling
+			return true
+		}
+		positions[sling.ID] = len(replies)
+		replies = append(replies, storedSling{seq: seq, sling: sling})
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return replies, nil
+}
+
+// renderReplies renders reply cards in the order given, leaving out expired
+// replies.
+func (s *service) renderReplies(board string, replies []storedSling, reactions map[string][]slingmessage.Reaction) (string, error) {
+	var buf strings.Builder
+	now := time.Now()
+	for _, reply := range replies {
+		if !reply.sling.ExpiresAt.IsZero() && !reply.sling.ExpiresAt.After(now) {
+			continue
+		}
+		s.loadObjectContent(board, reply.sling)
+		card, err := renderSlingCard(reply.sling, templates.SlingMeta{
+			Board:     board,
+			Seq:       strconv.FormatUint(reply.seq, 10),
+			Reactions: reactionView(reactions[reply.sling.ID]),
+		})
+		if err != nil {
+			return "", err
+		}
+		buf.WriteString(card)
+	}
+	return buf.String(), nil
+}
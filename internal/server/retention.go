@@ -0,0 +1,205 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/laetho/slingboard/internal/commands"
+	"github.com/nats-io/nats.go"
+	"github.com/spf13/viper"
+)
+
+const (
+	retentionLimits   = "limits"
+	retentionInterest = "interest"
+	storageFile       = "file"
+	storageMemory     = "memory"
+)
+
+var (
+	errBoardNotFound    = errors.New("board not found")
+	errInvalidRetention = errors.New("invalid retention")
+)
+
+// defaultRetention returns the server-wide retention for new boards. Boards
+// keep slings for a day unless the serve config says otherwise.
+func defaultRetention() *commands.BoardRetention {
+	retention := &commands.BoardRetention{
+		Retention:   viper.GetString("board_retention"),
+		MaxAge:      viper.GetString("board_max_age"),
+		MaxMessages: viper.GetInt64("board_max_messages"),
+		MaxBytes:    viper.GetInt64("board_max_bytes"),
+		Storage:     viper.GetString("board_storage"),
+	}
+	if retention.Retention == "" {
+		retention.Retention = retentionLimits
+	}
+	if retention.MaxAge == "" {
+		retention.MaxAge = (24 * time.Hour).String()
+	}
+	if retention.Storage == "" {
+		retention.Storage = storageFile
+	}
+	return retention
+}
+
+// boardStreamConfig builds the stream configuration for a new board from the
+// server-wide defaults and the board's own overrides.
+func boardStreamConfig(board string, retention *commands.BoardRetention) (*nats.StreamConfig, error) {
+	config := &nats.StreamConfig{
+		Name:        streamPrefix + board,
+		Subjects:    []string{boardSlingsSubject(board)},
+		AllowMsgTTL: true,
+		AllowDirect: true,
+	}
+	if err := applyRetention(config, defaultRetention()); err != nil {
+		return nil, fmt.Errorf("invalid server retention defaults: %w", err)
+	}
+	if err := applyRetention(config, retention); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// applyRetention copies the set fields of retention onto config. Zero values
+// leave the current setting alone; -1 removes a message or byte limit.
+func applyRetention(config *nats.StreamConfig, retention *commands.BoardRetention) error {
+	if retention == nil {
+		return nil
+	}
+
+	switch strings.ToLower(strings.TrimSpace(retention.Retention)) {
+	case "":
+	case retentionLimits:
+		config.Retention = nats.LimitsPolicy
+	case retentionInterest:
+		config.Retention = nats.InterestPolicy
+	default:
+		return fmt.Errorf("%w: retention must be %q or %q", errInvalidRetention, retentionLimits, retentionInterest)
+	}
+
+	if maxAge := strings.TrimSpace(retention.MaxAge); maxAge != "" {
+		age, err := time.ParseDuration(maxAge)
+		if err != nil || age < 0 {
+			return fmt.Errorf("%w: max age must be a duration, 0 keeps slings forever", errInvalidRetention)
+		}
+		config.MaxAge = age
+	}
+
+	if retention.MaxMessages < -1 || retention.MaxBytes < -1 {
+		return fmt.Errorf("%w: max messages and max bytes must be positive, or -1 for no limit", errInvalidRetention)
+	}
+	if retention.MaxMessages != 0 {
+		config.MaxMsgs = retention.MaxMessages
+	}
+	if retention.MaxBytes != 0 {
+		config.MaxBytes = retention.MaxBytes
+	}
+
+	switch strings.ToLower(strings.TrimSpace(retention.Storage)) {
+	case "":
+	case storageFile:
+		config.Storage = nats.FileStorage
+	case storageMemory:
+		config.Storage = nats.MemoryStorage
+	default:
+		return fmt.Errorf("%w: storage must be %q or %q", errInvalidRetention, storageFile, storageMemory)
+	}
+
+	return nil
+}
+
+func retentionFromConfig(config nats.StreamConfig) *commands.BoardRetention {
+	retention := &commands.BoardRetention{
+		Retention:   retentionLimits,
+		MaxAge:      config.MaxAge.String(),
+		MaxMessages: config.MaxMsgs,
+		MaxBytes:    config.MaxBytes,
+		Storage:     storageFile,
+	}
+	if config.Retention == nats.InterestPolicy {
+		retention.Retention = retentionInterest
+	}
+	if config.Storage == nats.MemoryStorage {
+		retention.Storage = storageMemory
+	}
+	return retention
+}
+
+// configureBoardStream creates the board stream with the given retention, or
+// applies it to the stream when the board already exists. Boards are only
+// created when create is set.
+func (s *service) configureBoardStream(board string, retention *commands.BoardRetention, create bool) (*nats.StreamConfig, error) {
+	info, err := s.js.StreamInfo(streamPrefix + board)
+	if errors.Is(err, nats.ErrStreamNotFound) {
+		if !create {
+			return nil, errBoardNotFound
+		}
+		config, err := boardStreamConfig(board, retention)
+		if err != nil {
+			return nil, err
+		}
+		created, err := s.js.AddStream(config)
+		if err != nil {
+			return nil, err
+		}
+		return &created.Config, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	config := info.Config
+	if retention == nil {
+		return &config, nil
+	}
+	if err := applyRetention(&config, retention); err != nil {
+		return nil, err
+	}
+	if config.Storage != info.Config.Storage {
+		return nil, fmt.Errorf("%w: storage cannot be changed on an existing board", errInvalidRetention)
+	}
+
+	updated, err := s.js.UpdateStream(&config)
+	if err != nil {
+		return nil, err
+	}
+	return &updated.Config, nil
+}
+
+func (s *service) handleBoardConfig(msg *nats.Msg, request commands.CommandRequest) {
+	board := normalizeBoardName(request.Board)
+	if board == "" {
+		s.respondCommandError(msg, http.StatusBadRequest, "board is required")
+		return
+	}
+
+	config, err := s.configureBoardStream(board, request.Retention, false)
+	if err != nil {
+		s.respondRetentionError(msg, err)
+		return
+	}
+
+	s.respondJSON(msg, http.StatusOK, commands.CommandResponse{
+		Status:    "ok",
+		Board:     board,
+		Retention: retentionFromConfig(*config),
+	})
+}
+
+func (s *service) respondRetentionError(msg *nats.Msg, err error) {
+	var apiErr *nats.APIError
+	switch {
+	case errors.Is(err, errBoardNotFound):
+		s.respondCommandError(msg, http.StatusNotFound, err.Error())
+	case errors.Is(err, errInvalidRetention):
+		s.respondCommandError(msg, http.StatusBadRequest, err.Error())
+	case errors.As(err, &apiErr) && apiErr.ErrorCode == nats.JSStreamInvalidConfig:
+		s.respondCommandError(msg, http.StatusBadRequest, apiErr.Description)
+	default:
+		s.respondCommandError(msg, http.StatusInternalServerError, "failed to configure board stream")
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"mime"
 	"net/http"
@@ -14,14 +15,17 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"syscall"
 	"time"
+	"unicode/utf8"
 
-	"github.com/alecthomas/chroma/quick"
+	"github.com/alecthomas/chroma"
+	"github.com/alecthomas/chroma/lexers"
 	"github.com/laetho/slingboard/internal/commands"
 	"github.com/laetho/slingboard/internal/slingmessage"
 	"github.com/laetho/slingboard/internal/slingnats"
@@ -30,21 +34,24 @@ import (
 	"github.com/nats-io/nats.go"
 	"github.com/nats-io/nuid"
 	"github.com/spf13/viper"
-	"github.com/yuin/goldmark"
 )
 
 const (
 	defaultBoard           = "global"
 	commandSubjectPrefix   = "slingboard."
+	eventSubjectPrefix     = "slingboard_events."
 	streamPrefix           = "sb_"
 	defaultFQDN            = "localhost"
 	websocketEstablished   = "h8s.control.ws.conn.established"
 	websocketClosed        = "h8s.control.ws.conn.closed"
 	websocketPublishHeader = "X-H8s-PublishSubject"
+	historySeqCookie       = "slingboard_history_seq"
+	boardDeletedHeader     = "Slingboard-Board-Deleted"
 	noCacheHeader          = "no-cache"
 	contentTypeHTML        = "text/html; charset=utf-8"
 	contentTypeJSON        = "application/json"
 	contentTypeCSS         = "text/css; charset=utf-8"
+	contentTypeJavaScript  = "text/javascript; charset=utf-8"
 	markdownMimeType       = "text/markdown"
 )
 
@@ -52,28 +59,41 @@ var (
 	indexSubject           = ""
 	boardSubjectPrefix     = ""
 	boardSubjectWildcard   = ""
+	boardHistoryWildcard   = ""
+	boardObjectWildcard    = ""
 	commandsSubject        = ""
+	uploadSubject          = ""
 	styleSubject           = ""
+	mermaidSubject         = ""
 	websocketSubjectPrefix = ""
 	configuredFQDN         = ""
 )
 
-var markdownRenderer = goldmark.New()
-
 type wsConnection struct {
-	board        string
-	reply        string
-	consumerName string
-	streamName   string
-	stop         chan struct{}
+	board string
+	reply string
+	// replaying is set while the slings the screen missed are sent to it;
+	// live slings reach it once the replay is done.
+	replaying bool
 }
 
 type service struct {
+	id      string // instance ID, unique to each running replica
 	nc      *nats.Conn
 	js      nats.JetStreamContext
+	done    chan struct{}
 	wsMu    sync.RWMutex
 	wsConns map[string]*wsConnection
+	feedMu  sync.Mutex
+	feeds   map[string]*boardFeed
 	subs    []*nats.Subscription
+
+	// ownersMu guards the websocket owner records seen by the watcher, and
+	// the websockets this instance stopped serving to a peer's takeover.
+	ownersMu        sync.Mutex
+	owners          map[string]ownerRecord
+	yielded         map[string]bool
+	ownerTTLChecked bool
 }
 
 func (s *service) hasWebsocketReply(board string, reply string) bool {
@@ -85,9 +105,14 @@ func (s *service) hasWebsocketReply(board string, reply string) bool {
 
 func newService(nc *nats.Conn, js nats.JetStreamContext) *service {
 	return &service{
+		id:      nuid.Next(),
 		nc:      nc,
 		js:      js,
+		done:    make(chan struct{}),
 		wsConns: make(map[string]*wsConnection),
+		feeds:   make(map[string]*boardFeed),
+		owners:  make(map[string]ownerRecord),
+		yielded: make(map[string]bool),
 	}
 }
 
@@ -98,19 +123,37 @@ func (s *service) register() error {
 	if err := s.queueSubscribe(boardSubjectWildcard, s.handleBoard); err != nil {
 		return err
 	}
+	if err := s.queueSubscribe(boardHistoryWildcard, s.handleBoardHistory); err != nil {
+		return err
+	}
+	if err := s.queueSubscribe(boardObjectWildcard, s.handleBoardObject); err != nil {
+		return err
+	}
 	if err := s.queueSubscribe(commandsSubject, s.handleCommands); err != nil {
 		return err
 	}
+	if err := s.queueSubscribe(uploadSubject, s.handleUpload); err != nil {
+		return err
+	}
 	if err := s.queueSubscribe(styleSubject, s.handleStyle); err != nil {
 		return err
 	}
-	if err := s.subscribe(websocketEstablished, s.handleWebsocketControl); err != nil {
+	if err := s.queueSubscribe(mermaidSubject, s.handleMermaid); err != nil {
+		return err
+	}
+	// Exactly one instance takes each new websocket; every instance hears
+	// when one closes, since any of them may own it.
+	if err := s.queueSubscribe(websocketEstablished, s.handleWebsocketControl); err != nil {
 		return err
 	}
 	if err := s.subscribe(websocketClosed, s.handleWebsocketControl); err != nil {
 		return err
 	}
-	return nil
+	if err := s.subscribe(eventSubjectPrefix+"*", s.handleBoardEvent); err != nil {
+		return err
+	}
+	s.startObjectSweep()
+	return s.startReplica()
 }
 
 func reversedFQDN(fqdn string) string {
@@ -135,8 +178,12 @@ func configureSubjects() {
 	indexSubject = fmt.Sprintf("h8s.http.get.%s", reversed)
 	boardSubjectPrefix = fmt.Sprintf("h8s.http.get.%s.board.", reversed)
 	boardSubjectWildcard = fmt.Sprintf("h8s.http.get.%s.board.*", reversed)
+	boardHistoryWildcard = fmt.Sprintf("h8s.http.get.%s.board.*.history", reversed)
+	boardObjectWildcard = fmt.Sprintf("h8s.http.get.%s.board.*.objects.*", reversed)
 	commandsSubject = fmt.Sprintf("h8s.http.post.%s.api.commands", reversed)
+	uploadSubject = fmt.Sprintf("h8s.http.post.%s.api.upload", reversed)
 	styleSubject = fmt.Sprintf("h8s.http.get.%s.static.style%%2Ecss", reversed)
+	mermaidSubject = fmt.Sprintf("h8s.http.get.%s.static.mermaid%%2Emin%%2Ejs", reversed)
 	websocketSubjectPrefix = fmt.Sprintf("h8s.ws.ws.%s.board.", reversed)
 }
 
@@ -158,13 +205,25 @@ func (s *service) subscribe(subject string, handler nats.MsgHandler) error {
 	return nil
 }
 
+// cleanupWebsocketConsumers deletes the board feed consumers left behind by
+// instances that stopped without cleaning up. Consumers of instances that
+// hold a lease are still in use.
 func (s *service) cleanupWebsocketConsumers() {
+	live, err := s.liveInstances()
+	if err != nil {
+		log.Printf("Failed to list instances, keeping websocket consumers: %v", err)
+		return
+	}
+
 	for streamName := range s.js.StreamNames() {
 		if !strings.HasPrefix(streamName, streamPrefix) {
 			continue
 		}
 		for consumerName := range s.js.ConsumerNames(streamName) {
-			if !strings.HasPrefix(consumerName, "ws-") {
+			if !strings.HasPrefix(consumerName, websocketConsumerPrefix) {
+				continue
+			}
+			if instance, ok := consumerInstance(consumerName); ok && live[instance] {
 				continue
 			}
 			if err := s.js.DeleteConsumer(streamName, consumerName); err != nil {
@@ -177,6 +236,7 @@ func (s *service) cleanupWebsocketConsumers() {
 }
 
 func (s *service) shutdown() {
+	close(s.done)
 	for _, sub := range s.subs {
 		_ = sub.Unsubscribe()
 	}
@@ -189,8 +249,9 @@ func (s *service) shutdown() {
 	s.wsMu.Unlock()
 
 	for _, reply := range replies {
-		s.stopWebsocketConsumer(reply)
+		s.stopWebsocket(reply)
 	}
+	s.releaseLease()
 }
 
 func Start() {
@@ -206,10 +267,10 @@ func Start() {
 
 	configureSubjects()
 	svc := newService(nc, js)
-	svc.cleanupWebsocketConsumers()
 	if err := svc.register(); err != nil {
 		log.Fatalf("Error registering subscriptions: %v", err)
 	}
+	svc.cleanupWebsocketConsumers()
 
 	log.Printf("Sling Board NATS service started for host %s", configuredFQDN)
 
@@ -231,7 +292,13 @@ func (s *service) handleIndex(msg *nats.Msg) {
 		return
 	}
 
-	boardCards, err := renderBoardCards(boards)
+	metadata, err := s.boardMetadataList(boards)
+	if err != nil {
+		s.respondError(msg, http.StatusInternalServerError, "failed to load board metadata")
+		return
+	}
+
+	boardCards, err := renderBoardCards(metadata)
 	if err != nil {
 		s.respondError(msg, http.StatusInternalServerError, "failed to render board list")
 		return
@@ -259,8 +326,42 @@ func (s *service) handleBoard(msg *nats.Msg) {
 		return
 	}
 
+	kv, err := s.metadataBucket(false)
+	if err != nil && !errors.Is(err, nats.ErrBucketNotFound) {
+		s.respondError(msg, http.StatusInternalServerError, "failed to load board metadata")
+		return
+	}
+	metadata, err := s.boardMetadata(kv, board)
+	if err != nil {
+		s.respondError(msg, http.StatusInternalServerError, "failed to load board metadata")
+		return
+	}
+
+	pinnedSlings, err := s.pinnedSlings(board)
+	if err != nil {
+		s.respondError(msg, http.StatusInternalServerError, "failed to load pinned slings")
+		return
+	}
+	pinned, err := s.renderPinnedSlings(board, pinnedSlings)
+	if err != nil {
+		s.respondError(msg, http.StatusInternalServerError, "failed to load pinned slings")
+		return
+	}
+
+	history, err := s.renderSlingHistory(board, 0, defaultHistoryLimit, slingIDs(pinnedSlings))
+	if err != nil {
+		s.respondError(msg, http.StatusInternalServerError, "failed to load board history")
+		return
+	}
+
+	codeCSS, err := codeStyleCSS(metadata.CodeStyle)
+	if err != nil {
+		s.respondError(msg, http.StatusInternalServerError, "failed to render code style")
+		return
+	}
+
 	var buf bytes.Buffer
-	component := templates.BoardView(board)
+	component := templates.BoardView(boardMeta(metadata), codeCSS, pinned, history.html, history.more, strconv.FormatUint(history.lastSeq, 10))
 	if err := component.Render(context.Background(), &buf); err != nil {
 		s.respondError(msg, http.StatusInternalServerError, "failed to render board")
 		return
@@ -290,10 +391,10 @@ func (s *service) listBoards() ([]string, error) {
 	return boards, nil
 }
 
-func renderBoardCards(boards []string) (string, error) {
+func renderBoardCards(boards []commands.BoardMetadata) (string, error) {
 	var buf bytes.Buffer
 	for _, board := range boards {
-		component := templates.BoardCard(board)
+		component := templates.BoardCard(boardMeta(board))
 		if err := component.Render(context.Background(), &buf); err != nil {
 			return "", err
 		}
@@ -303,26 +404,71 @@ func renderBoardCards(boards []string) (string, error) {
 
 func (s *service) ensureBoardStream(board string) (string, error) {
 	streamName := streamPrefix + board
-	if _, err := s.js.StreamInfo(streamName); err == nil {
+	if info, err := s.js.StreamInfo(streamName); err == nil {
+		if !info.Config.AllowMsgTTL || !info.Config.AllowDirect || !slices.Equal(info.Config.Subjects, []string{boardSlingsSubject(board)}) {
+			if err := s.upgradeBoardStream(board, info.Config); err != nil {
+				return "", err
+			}
+		}
 		return streamName, nil
 	} else if !errors.Is(err, nats.ErrStreamNotFound) {
 		return "", err
 	}
 
-	_, err := s.js.AddStream(&nats.StreamConfig{
-		Name:      streamName,
-		Subjects:  []string{commandSubjectPrefix + board},
-		Retention: nats.InterestPolicy,
-		MaxAge:    24 * time.Hour,
-		Storage:   nats.FileStorage,
-	})
+	config, err := boardStreamConfig(board, nil)
 	if err != nil {
 		return "", err
 	}
+	if _, err := s.js.AddStream(config); err != nil {
+		return "", err
+	}
 
 	return streamName, nil
 }
 
+// upgradeBoardStream brings a board stream created by an earlier version up
+// to date in place: per-sling TTLs, direct gets to read slings by subject,
+// and a subject per sling. Slings published on the old board-wide subject
+// are moved to their own subject in stream order. The moves carry a message
+// ID, so replicas upgrading the same board at once do not duplicate them.
+func (s *service) upgradeBoardStream(board string, config nats.StreamConfig) error {
+	legacySubject := commandSubjectPrefix + board
+	config.AllowMsgTTL = true
+	config.AllowDirect = true
+	config.Subjects = []string{legacySubject, boardSlingsSubject(board)}
+	if _, err := s.js.UpdateStream(&config); err != nil {
+		return err
+	}
+
+	err := s.eachSubjectSling(config.Name, legacySubject, 0, func(seq uint64, sling *slingmessage.SlingMessage) bool {
+		if sling.ID == "" {
+			sling.ID = strconv.FormatInt(sling.Timestamp.UnixNano(), 10)
+		}
+		if sling.ExpiresAt.IsZero() || sling.ExpiresAt.After(time.Now()) {
+			message, err := slingMsg(board, sling)
+			if err != nil {
+				log.Printf("Failed to move sling %d of board %s: %v", seq, board, err)
+				return true
+			}
+			if _, err := s.js.PublishMsg(message, nats.MsgId(fmt.Sprintf("%s-%d", config.Name, seq))); err != nil {
+				log.Printf("Failed to move sling %d of board %s: %v", seq, board, err)
+				return true
+			}
+		}
+		if err := s.js.DeleteMsg(config.Name, seq); err != nil && !errors.Is(err, nats.ErrMsgNotFound) {
+			log.Printf("Failed to remove moved sling %d of board %s: %v", seq, board, err)
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	config.Subjects = []string{boardSlingsSubject(board)}
+	_, err = s.js.UpdateStream(&config)
+	return err
+}
+
 func (s *service) handleStyle(msg *nats.Msg) {
 	css, err := staticfiles.FS.ReadFile("style.css")
 	if err != nil {
@@ -333,6 +479,28 @@ func (s *service) handleStyle(msg *nats.Msg) {
 	s.respond(msg, http.StatusOK, contentTypeCSS, css)
 }
 
+// handleMermaid serves the vendored mermaid script. Builds without it answer
+// 404 and mermaid fences keep showing their source. The script is larger
+// than the default NATS max payload, so it is streamed in parts.
+func (s *service) handleMermaid(msg *nats.Msg) {
+	script, err := staticfiles.FS.ReadFile("vendor/mermaid.min.js")
+	if err != nil {
+		s.respondError(msg, http.StatusNotFound, "mermaid is not bundled, run go generate ./static")
+		return
+	}
+	if limit := s.maxRangeLength(); uint64(len(script)) > limit {
+		header := nats.Header{
+			"Status-Code":   []string{strconv.Itoa(http.StatusOK)},
+			"Content-Type":  []string{contentTypeJavaScript},
+			"Cache-Control": []string{noCacheHeader},
+		}
+		s.streamResponse(msg, header, bytes.NewReader(script), limit)
+		return
+	}
+
+	s.respond(msg, http.StatusOK, contentTypeJavaScript, script)
+}
+
 func (s *service) handleCommands(msg *nats.Msg) {
 	var request commands.CommandRequest
 	if err := json.Unmarshal(msg.Data, &request); err != nil {
@@ -347,6 +515,42 @@ func (s *service) handleCommands(msg *nats.Msg) {
 	case commands.CommandBoardCreate:
 		s.handleBoardCreate(msg, request)
 		return
+	case commands.CommandBoardConfig:
+		s.handleBoardConfig(msg, request)
+		return
+	case commands.CommandBoardDelete:
+		s.handleBoardDelete(msg, request)
+		return
+	case commands.CommandBoardUpdate:
+		s.handleBoardUpdate(msg, request)
+		return
+	case commands.CommandSlingDelete:
+		s.handleSlingDelete(msg, request)
+		return
+	case commands.CommandSlingUpdate:
+		s.handleSlingUpdate(msg, request)
+		return
+	case commands.CommandSlingPin:
+		s.handleSlingPin(msg, request, true)
+		return
+	case commands.CommandSlingUnpin:
+		s.handleSlingPin(msg, request, false)
+		return
+	case commands.CommandSlingReact:
+		s.handleSlingReact(msg, request, true)
+		return
+	case commands.CommandSlingUnreact:
+		s.handleSlingReact(msg, request, false)
+		return
+	case commands.CommandUploadStart:
+		s.handleUploadStart(msg, request)
+		return
+	case commands.CommandUploadAppend:
+		s.handleUploadAppend(msg, request)
+		return
+	case commands.CommandUploadCommit:
+		s.handleUploadCommit(msg, request)
+		return
 	}
 
 	payload, mimeType, err := commandPayload(request)
@@ -355,6 +559,19 @@ func (s *service) handleCommands(msg *nats.Msg) {
 		return
 	}
 
+	s.createSling(msg, request, mimeType, payload, nil)
+}
+
+// createSling publishes a new sling and answers the command with its ID. A
+// file body streamed from a chunked upload is passed as body with a nil
+// payload. It reports whether the sling was published.
+func (s *service) createSling(msg *nats.Msg, request commands.CommandRequest, mimeType string, payload []byte, body *uploadBody) bool {
+	ttl, err := parseTTL(request.TTL)
+	if err != nil {
+		s.respondCommandError(msg, http.StatusBadRequest, err.Error())
+		return false
+	}
+
 	id := strconv.FormatInt(time.Now().UnixNano(), 10)
 	timestamp := time.Now().UTC()
 	board := normalizeBoardName(request.Board)
@@ -368,7 +585,36 @@ func (s *service) handleCommands(msg *nats.Msg) {
 
 	if _, err := s.ensureBoardStream(board); err != nil {
 		s.respondCommandError(msg, http.StatusInternalServerError, "failed to ensure board stream")
-		return
+		return false
+	}
+
+	excerpt, payload, err := codeExcerpt(request, payload)
+	if err != nil {
+		s.respondCommandError(msg, http.StatusBadRequest, err.Error())
+		return false
+	}
+
+	var language string
+	if requested := strings.TrimSpace(request.Language); requested != "" {
+		lexer, err := languageLexer(requested)
+		if err != nil {
+			s.respondCommandError(msg, http.StatusBadRequest, err.Error())
+			return false
+		}
+		language = lexer.Config().Name
+	}
+
+	var parentID string
+	if requested := strings.TrimSpace(request.ParentID); requested != "" {
+		parentID, err = s.replyParent(board, requested)
+		if err != nil {
+			if errors.Is(err, errParentNotFound) {
+				s.respondCommandError(msg, http.StatusNotFound, err.Error())
+				return false
+			}
+			s.respondCommandError(msg, http.StatusInternalServerError, "failed to look up parent sling")
+			return false
+		}
 	}
 
 	sling := slingmessage.SlingMessage{
@@ -377,23 +623,54 @@ func (s *service) handleCommands(msg *nats.Msg) {
 		Timestamp: timestamp,
 		MimeType:  mimeType,
 		Content:   payload,
+		ParentID:  parentID,
+		Language:  language,
+		Excerpt:   excerpt,
+	}
+	if sortBy := strings.TrimSpace(request.SortBy); sortBy != "" {
+		if err := validateTableSort(&sling, request.Filename, sortBy); err != nil {
+			s.respondCommandError(msg, http.StatusBadRequest, err.Error())
+			return false
+		}
+		sling.Table = &slingmessage.TableOptions{SortBy: sortBy}
 	}
-
-	jsonData, err := json.Marshal(&sling)
-	if err != nil {
-		s.respondCommandError(msg, http.StatusInternalServerError, "failed to encode message")
-		return
+	if isMediaMime(mimeType) && (request.Autoplay || request.Muted || request.Loop) {
+		sling.Playback = &slingmessage.Playback{
+			Autoplay: request.Autoplay,
+			Muted:    request.Muted,
+			Loop:     request.Loop,
+		}
 	}
-
-	publishSubject := commandSubjectPrefix + board
-	if err := s.nc.Publish(publishSubject, jsonData); err != nil {
-		s.respondCommandError(msg, http.StatusBadGateway, "failed to publish message")
-		return
+	if ttl > 0 {
+		sling.ExpiresAt = timestamp.Add(ttl)
+	}
+	if request.Type == commands.CommandFile {
+		if request.Filename != "" {
+			sling.Filename = filepath.Base(request.Filename)
+		}
+		// File bodies live in the board object store; the stream message
+		// only carries a reference and metadata.
+		object, size := io.Reader(bytes.NewReader(payload)), int64(len(payload))
+		if body != nil {
+			object, size = body.reader, body.size
+		}
+		if err := s.storeObject(board, id, sling.Filename, mimeType, object); err != nil {
+			s.respondCommandError(msg, http.StatusInternalServerError, "failed to store file")
+			return false
+		}
+		sling.ObjectID = id
+		sling.Size = size
+		sling.Content = nil
 	}
 
-	if err := s.nc.Flush(); err != nil {
-		s.respondCommandError(msg, http.StatusBadGateway, "failed to flush message")
-		return
+	if err := s.publishSling(board, &sling); err != nil {
+		if sling.ObjectID != "" {
+			if err := s.deleteObject(board, sling.ObjectID); err != nil {
+				log.Printf("Failed to delete object %s of unpublished sling: %v", sling.ObjectID, err)
+			}
+		}
+		s.respondCommandError(msg, http.StatusBadGateway, "failed to publish message")
+		return false
 	}
 
 	s.respondJSON(msg, http.StatusOK, commands.CommandResponse{
@@ -403,6 +680,83 @@ func (s *service) handleCommands(msg *nats.Msg) {
 		Board:     board,
 		Timestamp: timestamp,
 	})
+	return true
+}
+
+// slingSubject is the board stream subject a sling and its revisions are
+// published on. Replies sit below their parent, so a thread can be read by
+// its subject without walking the board.
+func slingSubject(board string, parentID string, id string) string {
+	if parentID != "" {
+		return commandSubjectPrefix + board + "." + parentID + "." + id
+	}
+	return commandSubjectPrefix + board + "." + id
+}
+
+// threadSubject matches the replies to a sling.
+func threadSubject(board string, parentID string) string {
+	return commandSubjectPrefix + board + "." + parentID + ".*"
+}
+
+// topLevelSlingsSubject matches the slings of a board that are not replies.
+func topLevelSlingsSubject(board string) string {
+	return commandSubjectPrefix + board + ".*"
+}
+
+// boardSlingsSubject matches every sling of a board.
+func boardSlingsSubject(board string) string {
+	return commandSubjectPrefix + board + ".>"
+}
+
+// validSlingID reports whether id can be a token of a sling subject. IDs in
+// commands come from users, so wildcards and separators are refused.
+func validSlingID(id string) bool {
+	return id != "" && !strings.ContainsAny(id, ".*> \t\r\n")
+}
+
+// publishSling stores a sling on the board stream and waits for the stream
+// to acknowledge it. Slings with an expiry are published with a matching
+// JetStream per-message TTL.
+func (s *service) publishSling(board string, sling *slingmessage.SlingMessage) error {
+	message, err := slingMsg(board, sling)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.js.PublishMsg(message)
+	return err
+}
+
+// slingMsg builds the board stream message of a sling.
+func slingMsg(board string, sling *slingmessage.SlingMessage) (*nats.Msg, error) {
+	jsonData, err := json.Marshal(sling)
+	if err != nil {
+		return nil, err
+	}
+
+	message := nats.NewMsg(slingSubject(board, sling.ParentID, sling.ID))
+	message.Data = jsonData
+	if !sling.ExpiresAt.IsZero() {
+		remaining := time.Until(sling.ExpiresAt)
+		if remaining <= 0 {
+			return nil, fmt.Errorf("sling has expired")
+		}
+		message.Header.Set(nats.MsgTTLHdr, remaining.Round(time.Second).String())
+	}
+	return message, nil
+}
+
+func parseTTL(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+
+	ttl, err := time.ParseDuration(value)
+	if err != nil || ttl < time.Second {
+		return 0, fmt.Errorf("ttl must be a duration of at least 1s")
+	}
+	return ttl, nil
 }
 
 func (s *service) handleWebsocketControl(msg *nats.Msg) {
@@ -414,10 +768,31 @@ func (s *service) handleWebsocketControl(msg *nats.Msg) {
 
 	switch msg.Subject {
 	case websocketEstablished:
-		s.startWebsocketConsumer(board, msg.Reply)
+		s.startWebsocket(board, msg.Reply, websocketHistorySeq(msg.Header))
+		if s.hasWebsocketReply(board, msg.Reply) {
+			s.claimWebsocket(board, msg.Reply)
+		}
 	case websocketClosed:
-		s.stopWebsocketConsumer(msg.Reply)
+		local := s.hasWebsocketReply(board, msg.Reply)
+		s.stopWebsocket(msg.Reply)
+		s.releaseWebsocket(msg.Reply, local)
+	}
+}
+
+// websocketHistorySeq returns the last stream sequence the page opening a
+// websocket was rendered with. Browsers cannot add headers or, through
+// h8sd, a query to a websocket, so the page sends it as a cookie.
+func websocketHistorySeq(header nats.Header) uint64 {
+	request := http.Request{Header: http.Header(header)}
+	cookie, err := request.Cookie(historySeqCookie)
+	if err != nil {
+		return 0
 	}
+	seq, err := strconv.ParseUint(cookie.Value, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return seq
 }
 
 func (s *service) handleBoardList(msg *nats.Msg) {
@@ -428,9 +803,15 @@ func (s *service) handleBoardList(msg *nats.Msg) {
 	}
 
 	if wantsJSON(msg) {
+		metadata, err := s.boardMetadataList(boards)
+		if err != nil {
+			s.respondCommandError(msg, http.StatusInternalServerError, "failed to load board metadata")
+			return
+		}
 		s.respondJSON(msg, http.StatusOK, commands.CommandResponse{
-			Status: "ok",
-			Boards: boards,
+			Status:        "ok",
+			Boards:        boards,
+			BoardMetadata: metadata,
 		})
 		return
 	}
@@ -453,15 +834,35 @@ func (s *service) handleBoardCreate(msg *nats.Msg, request commands.CommandReque
 		return
 	}
 
-	if _, err := s.ensureBoardStream(board); err != nil {
-		s.respondCommandError(msg, http.StatusInternalServerError, "failed to ensure board stream")
+	config, err := s.configureBoardStream(board, request.Retention, true)
+	if err != nil {
+		s.respondRetentionError(msg, err)
+		return
+	}
+
+	update := request.Metadata
+	if update == nil {
+		update = &commands.BoardMetadata{}
+	}
+	if update.Owner == "" {
+		update.Owner = request.Author
+	}
+	metadata, err := s.saveBoardMetadata(board, update)
+	if err != nil {
+		if errors.Is(err, errUnknownCodeStyle) {
+			s.respondCommandError(msg, http.StatusBadRequest, err.Error())
+			return
+		}
+		s.respondCommandError(msg, http.StatusInternalServerError, "failed to store board metadata")
 		return
 	}
 
 	if wantsJSON(msg) {
 		s.respondJSON(msg, http.StatusOK, commands.CommandResponse{
-			Status: "ok",
-			Board:  board,
+			Status:    "ok",
+			Board:     board,
+			Retention: retentionFromConfig(*config),
+			Metadata:  &metadata,
 		})
 		return
 	}
@@ -470,119 +871,373 @@ func (s *service) handleBoardCreate(msg *nats.Msg, request commands.CommandReque
 	s.respond(msg, http.StatusOK, contentTypeHTML, []byte(html))
 }
 
-func wantsJSON(msg *nats.Msg) bool {
-	accept := msg.Header.Get("Accept")
-	return strings.Contains(accept, "application/json")
+// handleBoardDelete removes a board's stream, pinned slings, reactions,
+// stored files and metadata. Every service instance tells its open screens
+// the board is gone and stops the board's feed once they are dropped.
+func (s *service) handleBoardDelete(msg *nats.Msg, request commands.CommandRequest) {
+	board := normalizeBoardName(request.Board)
+	if board == "" {
+		s.respondCommandError(msg, http.StatusBadRequest, "board is required")
+		return
+	}
+
+	if err := s.js.DeleteStream(streamPrefix + board); err != nil {
+		if errors.Is(err, nats.ErrStreamNotFound) {
+			s.respondCommandError(msg, http.StatusNotFound, "board not found")
+			return
+		}
+		s.respondCommandError(msg, http.StatusInternalServerError, "failed to delete board stream")
+		return
+	}
+	if err := s.js.DeleteKeyValue(pinBucketPrefix + board); err != nil && !errors.Is(err, nats.ErrStreamNotFound) {
+		s.respondCommandError(msg, http.StatusInternalServerError, "failed to delete pinned slings")
+		return
+	}
+	if err := s.js.DeleteKeyValue(reactionBucketPrefix + board); err != nil && !errors.Is(err, nats.ErrStreamNotFound) {
+		s.respondCommandError(msg, http.StatusInternalServerError, "failed to delete reactions")
+		return
+	}
+	if err := s.js.DeleteObjectStore(objectBucketPrefix + board); err != nil && !errors.Is(err, nats.ErrStreamNotFound) {
+		s.respondCommandError(msg, http.StatusInternalServerError, "failed to delete stored files")
+		return
+	}
+	if err := s.deleteBoardMetadata(board); err != nil {
+		s.respondCommandError(msg, http.StatusInternalServerError, "failed to delete board metadata")
+		return
+	}
+
+	var buf bytes.Buffer
+	component := templates.BoardDeleted(board)
+	if err := component.Render(context.Background(), &buf); err != nil {
+		s.respondCommandError(msg, http.StatusInternalServerError, "failed to render board removal")
+		return
+	}
+	event := nats.NewMsg(eventSubjectPrefix + board)
+	event.Data = buf.Bytes()
+	event.Header.Set(boardDeletedHeader, "true")
+	if err := s.nc.PublishMsg(event); err != nil {
+		s.respondCommandError(msg, http.StatusBadGateway, "failed to broadcast board removal")
+		return
+	}
+	if err := s.nc.Flush(); err != nil {
+		s.respondCommandError(msg, http.StatusBadGateway, "failed to broadcast board removal")
+		return
+	}
+
+	s.respondJSON(msg, http.StatusOK, commands.CommandResponse{
+		Status:    "ok",
+		Message:   "deleted",
+		Board:     board,
+		Timestamp: time.Now().UTC(),
+	})
 }
 
-func (s *service) startWebsocketConsumer(board string, reply string) {
-	if reply == "" {
+func (s *service) handleSlingDelete(msg *nats.Msg, request commands.CommandRequest) {
+	id := strings.TrimSpace(request.ID)
+	if id == "" {
+		s.respondCommandError(msg, http.StatusBadRequest, "sling id is required")
+		return
+	}
+	board := normalizeBoardName(request.Board)
+	if board == "" {
+		board = defaultBoard
+	}
+
+	if _, err := s.ensureBoardStream(board); err != nil {
+		s.respondCommandError(msg, http.StatusInternalServerError, "failed to ensure board stream")
 		return
 	}
 
-	streamName, err := s.ensureBoardStream(board)
+	replies, err := s.deleteStoredSling(board, id)
 	if err != nil {
-		log.Printf("Failed to ensure board stream: %v", err)
+		s.respondCommandError(msg, http.StatusInternalServerError, "failed to delete sling")
+		return
+	}
+	if err := s.syncPinnedSling(board, id, nil); err != nil {
+		s.respondCommandError(msg, http.StatusInternalServerError, "failed to unpin sling")
 		return
 	}
+	for _, deleted := range append(replies, id) {
+		if err := s.deleteReactions(board, deleted); err != nil {
+			s.respondCommandError(msg, http.StatusInternalServerError, "failed to delete reactions")
+			return
+		}
+		if err := s.deleteObject(board, deleted); err != nil {
+			s.respondCommandError(msg, http.StatusInternalServerError, "failed to delete stored file")
+			return
+		}
+	}
 
-	s.wsMu.Lock()
-	if _, exists := s.wsConns[reply]; exists {
-		s.wsMu.Unlock()
+	// Screens may still show the sling even when the stream no longer holds
+	// it, so the removal is always broadcast.
+	var buf bytes.Buffer
+	component := templates.SlingRemoved(id)
+	if err := component.Render(context.Background(), &buf); err != nil {
+		s.respondCommandError(msg, http.StatusInternalServerError, "failed to render removal")
 		return
 	}
-	consumerName := "ws-" + nuid.Next()
-	conn := &wsConnection{
-		board:        board,
-		reply:        reply,
-		consumerName: consumerName,
-		streamName:   streamName,
-		stop:         make(chan struct{}),
+	if err := s.broadcast(board, buf.Bytes()); err != nil {
+		s.respondCommandError(msg, http.StatusBadGateway, "failed to broadcast removal")
+		return
 	}
-	s.wsConns[reply] = conn
-	s.wsMu.Unlock()
 
-	_, err = s.js.AddConsumer(streamName, &nats.ConsumerConfig{
-		Durable:       consumerName,
-		AckPolicy:     nats.AckExplicitPolicy,
-		AckWait:       30 * time.Second,
-		FilterSubject: commandSubjectPrefix + board,
-		DeliverPolicy: nats.DeliverAllPolicy,
-		ReplayPolicy:  nats.ReplayInstantPolicy,
+	s.respondJSON(msg, http.StatusOK, commands.CommandResponse{
+		ID:        id,
+		Status:    "ok",
+		Message:   "deleted",
+		Board:     board,
+		Timestamp: time.Now().UTC(),
 	})
-	if err != nil {
-		log.Printf("Failed to create consumer: %v", err)
-		s.stopWebsocketConsumer(reply)
+}
+
+func (s *service) handleSlingUpdate(msg *nats.Msg, request commands.CommandRequest) {
+	id := strings.TrimSpace(request.ID)
+	if id == "" {
+		s.respondCommandError(msg, http.StatusBadRequest, "sling id is required")
+		return
+	}
+	if request.Content == "" {
+		s.respondCommandError(msg, http.StatusBadRequest, "message content is required")
+		return
+	}
+	board := normalizeBoardName(request.Board)
+	if board == "" {
+		board = defaultBoard
+	}
+
+	if _, err := s.ensureBoardStream(board); err != nil {
+		s.respondCommandError(msg, http.StatusInternalServerError, "failed to ensure board stream")
 		return
 	}
 
-	sub, err := s.js.PullSubscribe(commandSubjectPrefix+board, consumerName, nats.Bind(streamName, consumerName), nats.ManualAck())
+	current, err := s.findStoredSling(board, id)
 	if err != nil {
-		log.Printf("Failed to subscribe to consumer: %v", err)
-		s.stopWebsocketConsumer(reply)
+		s.respondCommandError(msg, http.StatusInternalServerError, "failed to look up sling")
+		return
+	}
+	if current == nil {
+		s.respondCommandError(msg, http.StatusNotFound, "sling not found")
+		return
+	}
+	if !isEditableMime(current.MimeType) {
+		s.respondCommandError(msg, http.StatusBadRequest, "only text and markdown slings can be edited")
+		return
+	}
+
+	// The revision keeps the original ID, author and timestamp so screens
+	// patch the existing card in place.
+	revision := *current
+	revision.Content = []byte(request.Content)
+	// Edited files carry their text inline; the original object is deleted
+	// with the sling.
+	revision.ObjectID = ""
+	revision.Size = 0
+	revision.EditedAt = time.Now().UTC()
+
+	if err := s.publishSling(board, &revision); err != nil {
+		s.respondCommandError(msg, http.StatusBadGateway, "failed to publish message")
+		return
+	}
+	if err := s.syncPinnedSling(board, id, &revision); err != nil {
+		s.respondCommandError(msg, http.StatusInternalServerError, "failed to update pinned sling")
 		return
 	}
 
-	go s.consumeWebsocket(conn, sub)
+	s.respondJSON(msg, http.StatusOK, commands.CommandResponse{
+		ID:        id,
+		Status:    "ok",
+		Message:   "updated",
+		Board:     board,
+		Timestamp: revision.EditedAt,
+	})
 }
 
-func (s *service) consumeWebsocket(conn *wsConnection, sub *nats.Subscription) {
-	defer sub.Unsubscribe()
-	for {
-		select {
-		case <-conn.stop:
-			return
-		default:
-			msgs, err := sub.Fetch(1, nats.MaxWait(2*time.Second))
-			if err != nil {
-				if errors.Is(err, nats.ErrTimeout) {
-					continue
-				}
-				if errors.Is(err, nats.ErrConnectionClosed) || errors.Is(err, nats.ErrBadSubscription) {
-					return
-				}
-				log.Printf("Failed to fetch messages: %v", err)
+// findStoredSling returns the latest stored revision of a sling or reply, or
+// nil when the board stream no longer holds it. Every sling has a subject of
+// its own, so this is a lookup of the last message on it.
+func (s *service) findStoredSling(board string, id string) (*slingmessage.SlingMessage, error) {
+	if !validSlingID(id) {
+		return nil, nil
+	}
+
+	streamName := streamPrefix + board
+	for _, subject := range []string{slingSubject(board, "", id), slingSubject(board, "*", id)} {
+		raw, err := s.js.GetLastMsg(streamName, subject)
+		if err != nil {
+			if errors.Is(err, nats.ErrMsgNotFound) {
 				continue
 			}
-			for _, msg := range msgs {
-				var sling slingmessage.SlingMessage
-				if err := json.Unmarshal(msg.Data, &sling); err != nil {
-					log.Printf("Error unmarshalling message: %v", err)
-					_ = msg.Ack()
-					continue
-				}
-
-				payload, err := renderSling(&sling)
-				if err != nil {
-					log.Printf("Error rendering sling message: %v", err)
-					_ = msg.Ack()
-					continue
-				}
-
-				if err := s.nc.Publish(conn.reply, []byte(payload)); err != nil {
-					log.Printf("Error sending websocket reply: %v", err)
-				}
-				_ = msg.Ack()
+			return nil, err
+		}
+
+		var sling slingmessage.SlingMessage
+		if err := json.Unmarshal(raw.Data, &sling); err != nil {
+			return nil, err
+		}
+		return &sling, nil
+	}
+	return nil, nil
+}
+
+func isEditableMime(mimeType string) bool {
+	return strings.HasPrefix(mimeType, "text/plain") || isMarkdownMime(mimeType)
+}
+
+func isMarkdownMime(mimeType string) bool {
+	return mimeType == markdownMimeType || strings.HasPrefix(mimeType, "text/x-markdown")
+}
+
+// deleteStoredSling removes every stored message carrying the sling ID, along
+// with the replies to it, and returns the IDs of the replies it removed. The
+// payload is overwritten so deleted content cannot be recovered from disk.
+func (s *service) deleteStoredSling(board string, id string) ([]string, error) {
+	if !validSlingID(id) {
+		return nil, nil
+	}
+	current, err := s.findStoredSling(board, id)
+	if err != nil {
+		return nil, err
+	}
+
+	streamName := streamPrefix + board
+	var sequences []uint64
+	var replies []string
+	collect := func(seq uint64, sling *slingmessage.SlingMessage) bool {
+		sequences = append(sequences, seq)
+		if sling.ParentID == id && !slices.Contains(replies, sling.ID) {
+			replies = append(replies, sling.ID)
+		}
+		return true
+	}
+	if current != nil && current.ParentID != "" {
+		err = s.eachSubjectSling(streamName, slingSubject(board, current.ParentID, id), 0, collect)
+	} else {
+		err = s.eachSubjectSling(streamName, slingSubject(board, "", id), 0, collect)
+		if err == nil {
+			err = s.eachSubjectSling(streamName, threadSubject(board, id), 0, collect)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, seq := range sequences {
+		if err := s.js.SecureDeleteMsg(streamName, seq); err != nil && !errors.Is(err, nats.ErrMsgNotFound) {
+			return nil, err
+		}
+	}
+	return replies, nil
+}
+
+// eachSubjectSling walks the messages of a board stream on subject, which may
+// be a wildcard, from the stream sequence from in sequence order until fn
+// returns false. Only the matching messages are read.
+func (s *service) eachSubjectSling(streamName string, subject string, from uint64, fn func(seq uint64, sling *slingmessage.SlingMessage) bool) error {
+	for seq := max(from, 1); ; seq++ {
+		raw, err := s.js.GetMsg(streamName, seq, nats.DirectGetNext(subject))
+		if err != nil {
+			if errors.Is(err, nats.ErrMsgNotFound) {
+				return nil
 			}
+			return err
+		}
+		seq = raw.Sequence
+
+		var sling slingmessage.SlingMessage
+		if err := json.Unmarshal(raw.Data, &sling); err != nil {
+			continue
+		}
+		if !fn(seq, &sling) {
+			return nil
 		}
 	}
 }
 
-func (s *service) stopWebsocketConsumer(reply string) {
-	s.wsMu.Lock()
-	conn, ok := s.wsConns[reply]
-	if ok {
-		delete(s.wsConns, reply)
-		close(conn.stop)
+// broadcast sends an HTML fragment to every screen connected to the board,
+// across all service instances.
+func (s *service) broadcast(board string, fragment []byte) error {
+	if err := s.nc.Publish(eventSubjectPrefix+board, fragment); err != nil {
+		return err
+	}
+	return s.nc.Flush()
+}
+
+func (s *service) handleBoardEvent(msg *nats.Msg) {
+	board, ok := boardFromSubject(msg.Subject, eventSubjectPrefix)
+	if !ok {
+		return
+	}
+
+	deleted := msg.Header.Get(boardDeletedHeader) != ""
+	for _, reply := range s.websocketReplies(board) {
+		if err := s.nc.Publish(reply, msg.Data); err != nil {
+			log.Printf("Error sending websocket event: %v", err)
+		}
+		if deleted {
+			s.stopWebsocket(reply)
+			s.releaseWebsocket(reply, true)
+		}
+	}
+}
+
+func (s *service) websocketReplies(board string) []string {
+	s.wsMu.RLock()
+	defer s.wsMu.RUnlock()
+	replies := make([]string, 0, len(s.wsConns))
+	for reply, conn := range s.wsConns {
+		if conn.board == board {
+			replies = append(replies, reply)
+		}
+	}
+	return replies
+}
+
+// feedReplies returns the websockets on a board that take live slings from
+// its feed, leaving out those still being sent a replay.
+func (s *service) feedReplies(board string) []string {
+	s.wsMu.RLock()
+	defer s.wsMu.RUnlock()
+	replies := make([]string, 0, len(s.wsConns))
+	for reply, conn := range s.wsConns {
+		if conn.board == board && !conn.replaying {
+			replies = append(replies, reply)
+		}
+	}
+	return replies
+}
+
+func wantsJSON(msg *nats.Msg) bool {
+	accept := msg.Header.Get("Accept")
+	return strings.Contains(accept, "application/json")
+}
+
+// renderStreamSling renders a sling read from a board stream. Edited
+// revisions become in-place patches of the card screens already show, and
+// replies are appended to the thread of their parent.
+func renderStreamSling(sling *slingmessage.SlingMessage, meta templates.SlingMeta) (string, error) {
+	payload, err := renderSlingCard(sling, meta)
+	if err != nil || (sling.EditedAt.IsZero() && sling.ParentID == "") {
+		return payload, err
 	}
-	s.wsMu.Unlock()
 
-	if ok {
-		_ = s.js.DeleteConsumer(conn.streamName, conn.consumerName)
+	var buf bytes.Buffer
+	component := templates.SlingReplaced(payload)
+	if sling.EditedAt.IsZero() {
+		component = templates.SlingReplyAdded(sling.ParentID, payload)
+	}
+	if err := component.Render(context.Background(), &buf); err != nil {
+		return "", err
 	}
+	return buf.String(), nil
 }
 
 func renderSling(sling *slingmessage.SlingMessage) (string, error) {
+	return renderSlingCard(sling, templates.SlingMeta{})
+}
+
+// renderSlingCard renders a sling with the card details the caller already
+// knows, such as the stream sequence it was read from and its reactions.
+func renderSlingCard(sling *slingmessage.SlingMessage, meta templates.SlingMeta) (string, error) {
 	var buf bytes.Buffer
 	id := sling.ID
 	timestamp := sling.Timestamp
@@ -597,50 +1252,126 @@ func renderSling(sling *slingmessage.SlingMessage) (string, error) {
 	if timestamp.IsZero() {
 		timestamp = time.Now().UTC()
 	}
-	timestampLabel := timestamp.UTC().Format(time.RFC3339)
+	meta.ID = id
+	meta.ParentID = sling.ParentID
+	meta.Author = sling.Sender
+	meta.Timestamp = timestamp.UTC().Format(time.RFC3339)
+	if !sling.EditedAt.IsZero() {
+		meta.EditedAt = sling.EditedAt.UTC().Format(time.RFC3339)
+	}
+	if !sling.ExpiresAt.IsZero() {
+		meta.ExpiresAt = sling.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+
+	// Source files are often sent with a generic MIME type, so any textual
+	// file can turn out to be code.
+	var lexer chroma.Lexer
+	isText := isTextMime(sling.MimeType) || (sling.Filename != "" && sling.Content != nil && utf8.Valid(sling.Content))
+	if isText && !isMarkdownMime(sling.MimeType) {
+		lexer = codeLexer(sling)
+		// Excerpts keep their line numbers even when the text is not code.
+		if lexer == nil && sling.Excerpt != nil {
+			lexer = lexers.Get(plaintextLexer)
+		}
+	}
+
+	format := structuredFormat(sling)
+	separator, isTable := tableSeparator(sling)
+	// Stored files whose content was not loaded, because they are too large
+	// to render, are offered for download.
+	contentMissing := sling.ObjectID != "" && sling.Content == nil && rendersContent(sling)
 
 	switch {
-	case strings.HasPrefix(sling.MimeType, "image/"):
-		component := templates.SlingImage(
-			id,
-			sling.Sender,
-			timestampLabel,
-			fmt.Sprintf(
-				"data:%s;base64,%s", sling.MimeType, base64.RawStdEncoding.EncodeToString(sling.Content)),
-		)
+	case contentMissing:
+		component := templates.SlingAttachment(meta, attachmentView(meta.Board, sling))
+		if err := component.Render(context.Background(), &buf); err != nil {
+			return "", err
+		}
 
+	case strings.HasPrefix(sling.MimeType, "image/"):
+		component := templates.SlingImage(meta, objectSource(meta.Board, sling))
 		if err := component.Render(context.Background(), &buf); err != nil {
 			return "", err
 		}
 
-	case strings.HasPrefix(sling.MimeType, "text/x-go"):
-		var buffer bytes.Buffer
-		if err := quick.Highlight(&buffer, string(sling.Content), "go", "html", "monokai"); err != nil {
+	case sling.MimeType == pdfMimeType:
+		filename := sling.Filename
+		if filename == "" {
+			filename = "document.pdf"
+		}
+		component := templates.SlingPDF(meta, objectSource(meta.Board, sling), filename)
+		if err := component.Render(context.Background(), &buf); err != nil {
 			return "", err
 		}
-		component := templates.SlingCode(id, sling.Sender, timestampLabel, buffer.String())
+
+	case isMediaMime(sling.MimeType):
+		var playback templates.Playback
+		if sling.Playback != nil {
+			playback = templates.Playback(*sling.Playback)
+		}
+		component := templates.SlingVideo(meta, objectSource(meta.Board, sling), sling.MimeType, playback)
+		if strings.HasPrefix(sling.MimeType, "audio/") {
+			component = templates.SlingAudio(meta, objectSource(meta.Board, sling), sling.MimeType, sling.Filename, playback)
+		}
 		if err := component.Render(context.Background(), &buf); err != nil {
 			return "", err
 		}
 
 	case strings.HasPrefix(sling.MimeType, "text/x-uri"):
-		component := templates.SlingURL(id, sling.Sender, timestampLabel, string(sling.Content))
+		component := templates.SlingURL(meta, string(sling.Content))
 		if err := component.Render(context.Background(), &buf); err != nil {
 			return "", err
 		}
 
-	case sling.MimeType == markdownMimeType || strings.HasPrefix(sling.MimeType, "text/x-markdown"):
+	case isMarkdownMime(sling.MimeType):
 		var mdBuffer bytes.Buffer
-		if err := markdownRenderer.Convert(sling.Content, &mdBuffer); err != nil {
+		if err := markdownRenderer.Convert(sling.Content, &mdBuffer, markdownParseOptions(sling.ID)); err != nil {
+			return "", err
+		}
+		component := templates.SlingMarkdown(meta, mdBuffer.String())
+		if err := component.Render(context.Background(), &buf); err != nil {
+			return "", err
+		}
+
+	case format != "":
+		data, err := structuredView(sling, format)
+		if err != nil {
+			return "", err
+		}
+		component := templates.SlingData(meta, data)
+		if err := component.Render(context.Background(), &buf); err != nil {
+			return "", err
+		}
+
+	case isTable:
+		// Files that do not parse as a table still show their text.
+		component := templates.Sling(meta, string(sling.Content))
+		if table, err := tableView(sling, separator); err == nil {
+			component = templates.SlingTable(meta, table)
+		}
+		if err := component.Render(context.Background(), &buf); err != nil {
+			return "", err
+		}
+
+	case lexer != nil:
+		code, err := highlightCode(lexer, string(sling.Content), sling.Excerpt)
+		if err != nil {
 			return "", err
 		}
-		component := templates.SlingMarkdown(id, sling.Sender, timestampLabel, mdBuffer.String())
+		title, lines := codeHeader(sling)
+		component := templates.SlingCode(meta, title, lines, code)
 		if err := component.Render(context.Background(), &buf); err != nil {
 			return "", err
 		}
 
 	case strings.HasPrefix(sling.MimeType, "text/"):
-		component := templates.Sling(id, sling.Sender, timestampLabel, string(sling.Content))
+		component := templates.Sling(meta, string(sling.Content))
+		if err := component.Render(context.Background(), &buf); err != nil {
+			return "", err
+		}
+
+	default:
+		component := templates.SlingAttachment(meta, attachmentView(meta.Board, sling))
 		if err := component.Render(context.Background(), &buf); err != nil {
 			return "", err
 		}
@@ -684,6 +1415,9 @@ func detectMimeType(filename string, data []byte) string {
 	if ext == ".md" || ext == ".markdown" {
 		return markdownMimeType
 	}
+	if ext == ".yaml" || ext == ".yml" {
+		return yamlMimeType
+	}
 
 	peekSize := min(len(data), 512)
 	mimeType := http.DetectContentType(data[:peekSize])
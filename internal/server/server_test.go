@@ -1,13 +1,21 @@
 package server
 
 import (
+	"bytes"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"mime/multipart"
+	"os/exec"
+	"slices"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/laetho/slingboard/internal/commands"
 	"github.com/laetho/slingboard/internal/slingmessage"
+	"github.com/laetho/slingboard/templates"
 	"github.com/nats-io/nats-server/v2/server"
 	"github.com/nats-io/nats.go"
 )
@@ -15,7 +23,7 @@ import (
 func startTestNATS(t *testing.T) (*server.Server, *nats.Conn) {
 	t.Helper()
 
-	opts := &server.Options{Port: -1, JetStream: true}
+	opts := &server.Options{Port: -1, JetStream: true, StoreDir: t.TempDir()}
 	srv, err := server.NewServer(opts)
 	if err != nil {
 		t.Fatalf("failed to create nats server: %v", err)
@@ -47,8 +55,12 @@ func startService(t *testing.T, nc *nats.Conn) *service {
 	indexSubject = strings.ToUpper(indexSubject)
 	boardSubjectPrefix = strings.ToUpper(boardSubjectPrefix)
 	boardSubjectWildcard = strings.ToUpper(boardSubjectWildcard)
+	boardHistoryWildcard = strings.ToUpper(boardHistoryWildcard)
+	boardObjectWildcard = strings.ToUpper(boardObjectWildcard)
 	commandsSubject = strings.ToUpper(commandsSubject)
+	uploadSubject = strings.ToUpper(uploadSubject)
 	styleSubject = strings.ToUpper(styleSubject)
+	mermaidSubject = strings.ToUpper(mermaidSubject)
 	websocketSubjectPrefix = strings.ToUpper(websocketSubjectPrefix)
 
 	svc := newService(nc, js)
@@ -265,7 +277,7 @@ func TestWebsocketBroadcast(t *testing.T) {
 		MimeType: "text/plain",
 		Content:  []byte("hello"),
 	})
-	if err := nc.Publish(commandSubjectPrefix+"testboard", slingPayload); err != nil {
+	if err := nc.Publish(slingSubject("testboard", "", "1"), slingPayload); err != nil {
 		t.Fatalf("failed to publish sling: %v", err)
 	}
 
@@ -279,6 +291,360 @@ func TestWebsocketBroadcast(t *testing.T) {
 	}
 }
 
+func TestWebsocketReplaysFromPageSequence(t *testing.T) {
+	srv, nc := startTestNATS(t)
+	defer srv.Shutdown()
+	defer nc.Close()
+
+	svc := startService(t, nc)
+	defer svc.shutdown()
+
+	addRetainedBoardStream(t, svc, "replayboard")
+	for i := range 30 {
+		resp := sendCommand(t, nc, commands.CommandRequest{
+			Type:    commands.CommandText,
+			Board:   "replayboard",
+			Content: "sling " + strconv.Itoa(i) + ".",
+		})
+		if resp.Status != "ok" {
+			t.Fatalf("expected ok status, got %+v", resp)
+		}
+	}
+
+	replySubject := "_INBOX.replay"
+	wsCh := make(chan *nats.Msg, 64)
+	wsSub, err := nc.Subscribe(replySubject, func(msg *nats.Msg) {
+		wsCh <- msg
+	})
+	if err != nil {
+		t.Fatalf("failed to subscribe to websocket inbox: %v", err)
+	}
+	defer wsSub.Unsubscribe()
+
+	// The page was rendered with the first five slings.
+	if err := nc.PublishMsg(&nats.Msg{
+		Subject: websocketEstablished,
+		Reply:   replySubject,
+		Header: nats.Header{
+			websocketPublishHeader: []string{websocketSubjectPrefix + "replayboard"},
+			"Cookie":               []string{"theme=dark; " + historySeqCookie + "=5"},
+		},
+	}); err != nil {
+		t.Fatalf("failed to publish control: %v", err)
+	}
+
+	var replayed []string
+	for len(replayed) < 25 {
+		select {
+		case msg := <-wsCh:
+			replayed = append(replayed, string(msg.Data))
+		case <-time.After(2 * time.Second):
+			t.Fatalf("expected 25 replayed slings, got %d", len(replayed))
+		}
+	}
+	if !strings.Contains(replayed[0], "sling 5.") || !strings.Contains(replayed[24], "sling 29.") {
+		t.Fatal("expected the slings after the page sequence in order")
+	}
+	select {
+	case msg := <-wsCh:
+		t.Fatalf("expected nothing beyond the replay, got %s", msg.Data)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestWebsocketsShareOneBoardFeed(t *testing.T) {
+	srv, nc := startTestNATS(t)
+	defer srv.Shutdown()
+	defer nc.Close()
+
+	svc := startService(t, nc)
+	defer svc.shutdown()
+
+	streamName := addRetainedBoardStream(t, svc, "wallboard")
+	earlier, _ := json.Marshal(slingmessage.SlingMessage{ID: "earlier", MimeType: "text/plain", Content: []byte("before the screens")})
+	if _, err := svc.js.Publish(slingSubject("wallboard", "", "earlier"), earlier); err != nil {
+		t.Fatalf("failed to publish sling: %v", err)
+	}
+
+	screens := make([]chan *nats.Msg, 3)
+	for i := range screens {
+		reply := "_INBOX.wall." + strconv.Itoa(i)
+		screens[i] = make(chan *nats.Msg, 8)
+		ch := screens[i]
+		sub, err := nc.Subscribe(reply, func(msg *nats.Msg) {
+			ch <- msg
+		})
+		if err != nil {
+			t.Fatalf("failed to subscribe to websocket inbox: %v", err)
+		}
+		defer sub.Unsubscribe()
+		if err := nc.PublishMsg(&nats.Msg{
+			Subject: websocketEstablished,
+			Reply:   reply,
+			Header:  nats.Header{websocketPublishHeader: []string{websocketSubjectPrefix + "wallboard"}},
+		}); err != nil {
+			t.Fatalf("failed to publish control: %v", err)
+		}
+		waitForWebsocketReply(t, svc, "wallboard", reply)
+	}
+
+	receive := func(ch chan *nats.Msg, want string) {
+		t.Helper()
+		select {
+		case msg := <-ch:
+			if !strings.Contains(string(msg.Data), want) {
+				t.Fatalf("expected %q, got %s", want, msg.Data)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("expected %q on the websocket", want)
+		}
+	}
+	for _, ch := range screens {
+		receive(ch, `data-seq="1"`)
+	}
+
+	consumers := 0
+	for range svc.js.ConsumerNames(streamName) {
+		consumers++
+	}
+	if consumers != 1 {
+		t.Fatalf("expected one consumer for three screens, got %d", consumers)
+	}
+
+	live, _ := json.Marshal(slingmessage.SlingMessage{ID: "live", MimeType: "text/plain", Content: []byte("for every screen")})
+	if _, err := svc.js.Publish(slingSubject("wallboard", "", "live"), live); err != nil {
+		t.Fatalf("failed to publish sling: %v", err)
+	}
+	for _, ch := range screens {
+		receive(ch, "for every screen")
+	}
+
+	for i := range screens {
+		if err := nc.PublishMsg(&nats.Msg{
+			Subject: websocketClosed,
+			Reply:   "_INBOX.wall." + strconv.Itoa(i),
+			Header:  nats.Header{websocketPublishHeader: []string{websocketSubjectPrefix + "wallboard"}},
+		}); err != nil {
+			t.Fatalf("failed to publish control: %v", err)
+		}
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		consumers = 0
+		for range svc.js.ConsumerNames(streamName) {
+			consumers++
+		}
+		if consumers == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the board feed to stop with its last screen, %d consumers left", consumers)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestReplicasShareWebsocketsAndTakeOver(t *testing.T) {
+	srv, nc := startTestNATS(t)
+	defer srv.Shutdown()
+	defer nc.Close()
+
+	first := startService(t, nc)
+	second := startService(t, nc)
+
+	streamName := addRetainedBoardStream(t, first, "replicaboard")
+
+	replySubject := "_INBOX.replica"
+	wsCh := make(chan *nats.Msg, 8)
+	wsSub, err := nc.Subscribe(replySubject, func(msg *nats.Msg) {
+		wsCh <- msg
+	})
+	if err != nil {
+		t.Fatalf("failed to subscribe to websocket inbox: %v", err)
+	}
+	defer wsSub.Unsubscribe()
+	if err := nc.PublishMsg(&nats.Msg{
+		Subject: websocketEstablished,
+		Reply:   replySubject,
+		Header:  nats.Header{websocketPublishHeader: []string{websocketSubjectPrefix + "replicaboard"}},
+	}); err != nil {
+		t.Fatalf("failed to publish control: %v", err)
+	}
+
+	var owner, peer *service
+	deadline := time.Now().Add(2 * time.Second)
+	for owner == nil {
+		switch {
+		case first.hasWebsocketReply("replicaboard", replySubject):
+			owner, peer = first, second
+		case second.hasWebsocketReply("replicaboard", replySubject):
+			owner, peer = second, first
+		case time.Now().After(deadline):
+			t.Fatal("websocket reply not registered")
+		default:
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	defer peer.shutdown()
+	if peer.hasWebsocketReply("replicaboard", replySubject) {
+		owner.shutdown()
+		t.Fatal("expected exactly one replica to own the websocket")
+	}
+
+	publish := func(text string) {
+		t.Helper()
+		id := strings.ReplaceAll(text, " ", "-")
+		payload, _ := json.Marshal(slingmessage.SlingMessage{ID: id, MimeType: "text/plain", Content: []byte(text)})
+		if _, err := first.js.Publish(slingSubject("replicaboard", "", id), payload); err != nil {
+			t.Fatalf("failed to publish sling: %v", err)
+		}
+	}
+	receive := func(want string) {
+		t.Helper()
+		for {
+			select {
+			case msg := <-wsCh:
+				if strings.Contains(string(msg.Data), want) {
+					return
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatalf("expected %q on the websocket", want)
+			}
+		}
+	}
+
+	publish("once")
+	receive("once")
+	select {
+	case msg := <-wsCh:
+		t.Fatalf("expected the sling once, got another message: %s", msg.Data)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	// A replica starting up keeps the consumers of live peers.
+	peer.cleanupWebsocketConsumers()
+	consumers := 0
+	for name := range first.js.ConsumerNames(streamName) {
+		if instance, ok := consumerInstance(name); !ok || instance != owner.id {
+			t.Fatalf("expected only the owner's consumer, got %s", name)
+		}
+		consumers++
+	}
+	if consumers != 1 {
+		t.Fatalf("expected the owner's consumer to survive cleanup, got %d", consumers)
+	}
+
+	owner.shutdown()
+	peer.balanceWebsockets()
+	if !peer.hasWebsocketReply("replicaboard", replySubject) {
+		t.Fatal("expected the peer to take over the websocket")
+	}
+	publish("after takeover")
+	receive("after takeover")
+
+	if err := nc.PublishMsg(&nats.Msg{
+		Subject: websocketClosed,
+		Reply:   replySubject,
+		Header:  nats.Header{websocketPublishHeader: []string{websocketSubjectPrefix + "replicaboard"}},
+	}); err != nil {
+		t.Fatalf("failed to publish control: %v", err)
+	}
+	kv, err := peer.websocketBucketKV()
+	if err != nil {
+		t.Fatalf("failed to open websocket bucket: %v", err)
+	}
+	if status, err := kv.Status(); err != nil || status.TTL() != websocketOwnerTTL {
+		t.Fatalf("expected owner records to expire unless renewed, got %v", err)
+	}
+	deadline = time.Now().Add(2 * time.Second)
+	for {
+		if _, err := kv.Get(websocketKey(replySubject)); errors.Is(err, nats.ErrKeyNotFound) && !peer.hasWebsocketReply("replicaboard", replySubject) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the closed websocket to be released")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestWebsocketRenewalYieldsToTakeover(t *testing.T) {
+	srv, nc := startTestNATS(t)
+	defer srv.Shutdown()
+	defer nc.Close()
+
+	svc := startService(t, nc)
+	defer svc.shutdown()
+	addRetainedBoardStream(t, svc, "renewboard")
+
+	replySubject := "_INBOX.renew"
+	if err := nc.PublishMsg(&nats.Msg{
+		Subject: websocketEstablished,
+		Reply:   replySubject,
+		Header:  nats.Header{websocketPublishHeader: []string{websocketSubjectPrefix + "renewboard"}},
+	}); err != nil {
+		t.Fatalf("failed to publish control: %v", err)
+	}
+	kv, err := svc.websocketBucketKV()
+	if err != nil {
+		t.Fatalf("failed to open websocket bucket: %v", err)
+	}
+	key := websocketKey(replySubject)
+	var claimed nats.KeyValueEntry
+	deadline := time.Now().Add(2 * time.Second)
+	for claimed == nil {
+		if entry, err := kv.Get(key); err == nil && svc.hasWebsocketReply("renewboard", replySubject) {
+			claimed = entry
+		} else if time.Now().After(deadline) {
+			t.Fatal("websocket owner not recorded")
+		} else {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	svc.renewWebsockets()
+	renewed, err := kv.Get(key)
+	if err != nil || renewed.Revision() <= claimed.Revision() {
+		t.Fatalf("expected the owner record to be renewed: %v", err)
+	}
+
+	// A peer takes the websocket over while this instance's lease had
+	// lapsed; the next renewal must not write the old owner back.
+	takeover, _ := json.Marshal(websocketOwner{Board: "renewboard", Reply: replySubject, Instance: "peer"})
+	if _, err := kv.Update(key, takeover, renewed.Revision()); err != nil {
+		t.Fatalf("failed to take the websocket over: %v", err)
+	}
+	svc.renewWebsockets()
+	entry, err := kv.Get(key)
+	if err != nil {
+		t.Fatalf("failed to read owner record: %v", err)
+	}
+	var owner websocketOwner
+	if err := json.Unmarshal(entry.Value(), &owner); err != nil || owner.Instance != "peer" {
+		t.Fatalf("expected the takeover to stand, got %s", entry.Value())
+	}
+	deadline = time.Now().Add(2 * time.Second)
+	for svc.hasWebsocketReply("renewboard", replySubject) {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the old owner to stop serving the websocket")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// The peer finds the old owner alive after all and hands it back.
+	handback, _ := json.Marshal(websocketOwner{Board: "renewboard", Reply: replySubject, Instance: svc.id})
+	if _, err := kv.Update(key, handback, entry.Revision()); err != nil {
+		t.Fatalf("failed to hand the websocket back: %v", err)
+	}
+	deadline = time.Now().Add(2 * time.Second)
+	for !svc.hasWebsocketReply("renewboard", replySubject) {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the websocket handed back to be served again")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
 func TestMarkdownRender(t *testing.T) {
 	sling := slingmessage.SlingMessage{
 		MimeType: markdownMimeType,
@@ -294,3 +660,1714 @@ func TestMarkdownRender(t *testing.T) {
 		t.Fatalf("expected markdown header in output: %s", payload)
 	}
 }
+
+func TestMarkdownExtensions(t *testing.T) {
+	source := strings.Join([]string{
+		"## Rollback steps",
+		"",
+		"| step | owner |",
+		"| --- | --- |",
+		"| drain | sre |",
+		"",
+		"- [x] page on-call",
+		"- [ ] ~~restart~~ redeploy[^1]",
+		"",
+		"Canary",
+		": A small share of traffic.",
+		"",
+		"```go",
+		"func main() {}",
+		"```",
+		"",
+		"<script>alert(1)</script>",
+		"",
+		"[^1]: Only after the drain.",
+	}, "\n")
+
+	payload, err := renderSling(&slingmessage.SlingMessage{
+		ID:       "abc",
+		MimeType: markdownMimeType,
+		Sender:   "tester",
+		Content:  []byte(source + "\n\n## Rollback steps\n"),
+	})
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	for _, want := range []string{
+		`<h2 id="sling-abc-rollback-steps">`,
+		`href="#sling-abc-rollback-steps"`,
+		`<h2 id="sling-abc-rollback-steps-1">`,
+		"<table>",
+		`type="checkbox"`,
+		"<del>restart</del>",
+		`class="footnote-ref"`,
+		"<dt>Canary</dt>",
+		`<span class="kd">func</span>`,
+	} {
+		if !strings.Contains(payload, want) {
+			t.Fatalf("expected %q in output: %s", want, payload)
+		}
+	}
+	if strings.Contains(payload, "<script>alert") {
+		t.Fatal("expected raw HTML to be left out")
+	}
+}
+
+func TestMarkdownMath(t *testing.T) {
+	source := "Energy $E = mc^2$ costs $5 and $10.\n\n$$\n\\sum_{i=1}^{n} i = \\frac{n(n+1)}{2}\n$$\n"
+	payload, err := renderSling(&slingmessage.SlingMessage{
+		MimeType: markdownMimeType,
+		Sender:   "tester",
+		Content:  []byte(source),
+	})
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	for _, want := range []string{
+		"<msup><mi>c</mi><mn>2</mn></msup>",
+		"costs $5 and $10.",
+		`<math xmlns="http://www.w3.org/1998/Math/MathML" display="block">`,
+		"<munderover><mo>∑</mo>",
+		"<mfrac>",
+		`<annotation encoding="application/x-tex">E = mc^2</annotation>`,
+	} {
+		if !strings.Contains(payload, want) {
+			t.Fatalf("expected %q in output: %s", want, payload)
+		}
+	}
+}
+
+func TestMarkdownDiagrams(t *testing.T) {
+	source := "```mermaid\ngraph TD; api-->db\n```\n\n```dot\ndigraph { api -> db }\n```\n"
+	payload, err := renderSling(&slingmessage.SlingMessage{
+		MimeType: markdownMimeType,
+		Sender:   "tester",
+		Content:  []byte(source),
+	})
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if !strings.Contains(payload, `<pre class="mermaid">graph TD; api--&gt;db`) {
+		t.Fatalf("expected a mermaid diagram, got %s", payload)
+	}
+	if strings.Count(payload, `class="md-diagram__source"`) != 2 {
+		t.Fatal("expected the source of both diagrams")
+	}
+	if _, err := exec.LookPath(dotCommand); err == nil {
+		if !strings.Contains(payload, `src="data:image/svg+xml;base64,`) {
+			t.Fatalf("expected a rendered graph, got %s", payload)
+		}
+	} else if !strings.Contains(payload, `class="md-diagram__error"`) {
+		t.Fatalf("expected the dot error without graphviz installed, got %s", payload)
+	}
+}
+
+func TestCommandsSlingDeleteRemovesMessage(t *testing.T) {
+	srv, nc := startTestNATS(t)
+	defer srv.Shutdown()
+	defer nc.Close()
+
+	svc := startService(t, nc)
+	defer svc.shutdown()
+
+	if _, err := svc.ensureBoardStream("testboard"); err != nil {
+		t.Fatalf("failed to create board stream: %v", err)
+	}
+
+	replySubject := "_INBOX.delete"
+	wsCh := make(chan *nats.Msg, 4)
+	wsSub, err := nc.Subscribe(replySubject, func(msg *nats.Msg) {
+		wsCh <- msg
+	})
+	if err != nil {
+		t.Fatalf("failed to subscribe to websocket inbox: %v", err)
+	}
+	defer wsSub.Unsubscribe()
+
+	ctrl := &nats.Msg{
+		Subject: websocketEstablished,
+		Reply:   replySubject,
+		Header:  nats.Header{websocketPublishHeader: []string{websocketSubjectPrefix + "testboard"}},
+	}
+	if err := nc.PublishMsg(ctrl); err != nil {
+		t.Fatalf("failed to publish control: %v", err)
+	}
+	waitForWebsocketReply(t, svc, "testboard", replySubject)
+
+	slingPayload, _ := json.Marshal(slingmessage.SlingMessage{
+		ID:       "42",
+		MimeType: "text/plain",
+		Content:  []byte("secret"),
+	})
+	if _, err := svc.js.Publish(slingSubject("testboard", "", "42"), slingPayload); err != nil {
+		t.Fatalf("failed to publish sling: %v", err)
+	}
+	select {
+	case <-wsCh:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected websocket broadcast")
+	}
+
+	payload, _ := json.Marshal(commands.CommandRequest{
+		Type:  commands.CommandSlingDelete,
+		Board: "testboard",
+		ID:    "42",
+	})
+	resp, err := nc.Request(commandsSubject, payload, 2*time.Second)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+
+	var commandResp commands.CommandResponse
+	if err := json.Unmarshal(resp.Data, &commandResp); err != nil {
+		t.Fatalf("invalid response json: %v", err)
+	}
+	if commandResp.Status != "ok" || commandResp.ID != "42" {
+		t.Fatalf("expected ok for sling 42, got %+v", commandResp)
+	}
+
+	select {
+	case msg := <-wsCh:
+		if !strings.Contains(string(msg.Data), `data-patch-mode="remove"`) || !strings.Contains(string(msg.Data), "#sling-42") {
+			t.Fatalf("expected removal fragment, got %s", msg.Data)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected removal broadcast")
+	}
+
+	deleted, err := svc.findStoredSling("testboard", "42")
+	if err != nil {
+		t.Fatalf("failed to look up sling: %v", err)
+	}
+	if deleted != nil {
+		t.Fatal("expected sling 42 to be deleted from the stream")
+	}
+}
+
+// addRetainedBoardStream creates a board stream that keeps messages after
+// delivery, so tests can look slings up after publishing them.
+func addRetainedBoardStream(t *testing.T, svc *service, board string) string {
+	t.Helper()
+
+	streamName := streamPrefix + board
+	if _, err := svc.js.AddStream(&nats.StreamConfig{
+		Name:        streamName,
+		Subjects:    []string{boardSlingsSubject(board)},
+		Storage:     nats.MemoryStorage,
+		AllowMsgTTL: true,
+		AllowDirect: true,
+	}); err != nil {
+		t.Fatalf("failed to create board stream: %v", err)
+	}
+	return streamName
+}
+
+func sendCommand(t *testing.T, nc *nats.Conn, request commands.CommandRequest) commands.CommandResponse {
+	t.Helper()
+
+	payload, _ := json.Marshal(request)
+	req := &nats.Msg{Subject: commandsSubject, Data: payload, Header: nats.Header{"Accept": []string{"application/json"}}}
+	resp, err := nc.RequestMsg(req, 2*time.Second)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+
+	var commandResp commands.CommandResponse
+	if err := json.Unmarshal(resp.Data, &commandResp); err != nil {
+		t.Fatalf("invalid response json: %v", err)
+	}
+	return commandResp
+}
+
+func TestCommandsSlingUpdateKeepsAuthor(t *testing.T) {
+	srv, nc := startTestNATS(t)
+	defer srv.Shutdown()
+	defer nc.Close()
+
+	svc := startService(t, nc)
+	defer svc.shutdown()
+
+	addRetainedBoardStream(t, svc, "editboard")
+
+	payload, _ := json.Marshal(commands.CommandRequest{
+		Type:    commands.CommandText,
+		Board:   "editboard",
+		Author:  "alice",
+		Content: "helo",
+	})
+	resp, err := nc.Request(commandsSubject, payload, 2*time.Second)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	var created commands.CommandResponse
+	if err := json.Unmarshal(resp.Data, &created); err != nil {
+		t.Fatalf("invalid response json: %v", err)
+	}
+
+	payload, _ = json.Marshal(commands.CommandRequest{
+		Type:    commands.CommandSlingUpdate,
+		Board:   "editboard",
+		Author:  "bob",
+		ID:      created.ID,
+		Content: "hello",
+	})
+	resp, err = nc.Request(commandsSubject, payload, 2*time.Second)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	var updated commands.CommandResponse
+	if err := json.Unmarshal(resp.Data, &updated); err != nil {
+		t.Fatalf("invalid response json: %v", err)
+	}
+	if updated.Status != "ok" {
+		t.Fatalf("expected ok status, got %+v", updated)
+	}
+
+	revision, err := svc.findStoredSling("editboard", created.ID)
+	if err != nil || revision == nil {
+		t.Fatalf("expected stored revision, got %v", err)
+	}
+	if revision.Sender != "alice" || !revision.Timestamp.Equal(created.Timestamp) {
+		t.Fatalf("expected original author and timestamp, got %q %v", revision.Sender, revision.Timestamp)
+	}
+	if string(revision.Content) != "hello" || revision.EditedAt.IsZero() {
+		t.Fatalf("expected edited content, got %q edited %v", revision.Content, revision.EditedAt)
+	}
+
+	rendered, err := renderStreamSling(revision, templates.SlingMeta{})
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if !strings.Contains(rendered, `data-patch-mode="outer"`) || !strings.Contains(rendered, `id="sling-`+created.ID+`"`) {
+		t.Fatalf("expected in-place patch of the sling card: %s", rendered)
+	}
+}
+
+func TestCommandsSlingPinRendersOnBoard(t *testing.T) {
+	srv, nc := startTestNATS(t)
+	defer srv.Shutdown()
+	defer nc.Close()
+
+	svc := startService(t, nc)
+	defer svc.shutdown()
+
+	addRetainedBoardStream(t, svc, "pinboard")
+	created := sendCommand(t, nc, commands.CommandRequest{
+		Type:    commands.CommandText,
+		Board:   "pinboard",
+		Content: "standup at ten",
+	})
+
+	pinned := sendCommand(t, nc, commands.CommandRequest{
+		Type:  commands.CommandSlingPin,
+		Board: "pinboard",
+		ID:    created.ID,
+	})
+	if pinned.Status != "ok" {
+		t.Fatalf("expected ok status, got %+v", pinned)
+	}
+
+	resp, err := nc.Request(boardSubjectPrefix+"pinboard", nil, 2*time.Second)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if !strings.Contains(string(resp.Data), `id="sling-`+created.ID+`"`) {
+		t.Fatal("expected pinned sling in initial board render")
+	}
+
+	unpinned := sendCommand(t, nc, commands.CommandRequest{
+		Type:  commands.CommandSlingUnpin,
+		Board: "pinboard",
+		ID:    created.ID,
+	})
+	if unpinned.Status != "ok" {
+		t.Fatalf("expected ok status, got %+v", unpinned)
+	}
+
+	resp, err = nc.Request(boardSubjectPrefix+"pinboard", nil, 2*time.Second)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	body := string(resp.Data)
+	if strings.Index(body, `id="sling-`+created.ID+`"`) < strings.Index(body, `id="slings"`) {
+		t.Fatal("expected unpinned sling to leave the pinned region")
+	}
+}
+
+func TestCommandsTextWithTTL(t *testing.T) {
+	srv, nc := startTestNATS(t)
+	defer srv.Shutdown()
+	defer nc.Close()
+
+	svc := startService(t, nc)
+	defer svc.shutdown()
+
+	streamName := addRetainedBoardStream(t, svc, "ttlboard")
+	created := sendCommand(t, nc, commands.CommandRequest{
+		Type:    commands.CommandText,
+		Board:   "ttlboard",
+		Content: "standup in 5 minutes",
+		TTL:     "5m",
+	})
+	if created.Status != "ok" {
+		t.Fatalf("expected ok status, got %+v", created)
+	}
+
+	info, err := svc.js.StreamInfo(streamName)
+	if err != nil {
+		t.Fatalf("failed to read stream info: %v", err)
+	}
+	if !info.Config.AllowMsgTTL {
+		t.Fatal("expected board stream to allow per-message TTLs")
+	}
+
+	raw, err := svc.js.GetLastMsg(streamName, slingSubject("ttlboard", "", created.ID))
+	if err != nil {
+		t.Fatalf("failed to read stored sling: %v", err)
+	}
+	if got := raw.Header.Get(nats.MsgTTLHdr); got == "" {
+		t.Fatal("expected stored sling to carry a TTL header")
+	}
+
+	var sling slingmessage.SlingMessage
+	if err := json.Unmarshal(raw.Data, &sling); err != nil {
+		t.Fatalf("invalid stored sling: %v", err)
+	}
+	if want := created.Timestamp.Add(5 * time.Minute); !sling.ExpiresAt.Equal(want) {
+		t.Fatalf("expected expiry %v, got %v", want, sling.ExpiresAt)
+	}
+
+	rejected := sendCommand(t, nc, commands.CommandRequest{
+		Type:    commands.CommandText,
+		Board:   "ttlboard",
+		Content: "too short",
+		TTL:     "10ms",
+	})
+	if rejected.Status != "error" {
+		t.Fatalf("expected sub-second ttl to be rejected, got %+v", rejected)
+	}
+}
+
+func TestCommandsBoardRetention(t *testing.T) {
+	srv, nc := startTestNATS(t)
+	defer srv.Shutdown()
+	defer nc.Close()
+
+	svc := startService(t, nc)
+	defer svc.shutdown()
+
+	created := sendCommand(t, nc, commands.CommandRequest{
+		Type:  commands.CommandBoardCreate,
+		Board: "retained",
+		Retention: &commands.BoardRetention{
+			Retention: "limits",
+			MaxAge:    "72h",
+			Storage:   "memory",
+		},
+	})
+	if created.Status != "ok" {
+		t.Fatalf("expected ok status, got %+v", created)
+	}
+
+	info, err := svc.js.StreamInfo(streamPrefix + "retained")
+	if err != nil {
+		t.Fatalf("expected stream to exist: %v", err)
+	}
+	if info.Config.Retention != nats.LimitsPolicy || info.Config.MaxAge != 72*time.Hour || info.Config.Storage != nats.MemoryStorage {
+		t.Fatalf("unexpected stream config: %+v", info.Config)
+	}
+
+	configured := sendCommand(t, nc, commands.CommandRequest{
+		Type:  commands.CommandBoardConfig,
+		Board: "retained",
+		Retention: &commands.BoardRetention{
+			Retention:   "interest",
+			MaxMessages: 100,
+		},
+	})
+	if configured.Status != "ok" || configured.Retention == nil {
+		t.Fatalf("expected ok status with retention, got %+v", configured)
+	}
+	if configured.Retention.Retention != "interest" || configured.Retention.MaxMessages != 100 || configured.Retention.MaxAge != "72h0m0s" {
+		t.Fatalf("unexpected retention after config: %+v", configured.Retention)
+	}
+
+	rejected := sendCommand(t, nc, commands.CommandRequest{
+		Type:      commands.CommandBoardConfig,
+		Board:     "retained",
+		Retention: &commands.BoardRetention{Storage: "file"},
+	})
+	if rejected.Status != "error" {
+		t.Fatalf("expected storage change to be rejected, got %+v", rejected)
+	}
+
+	missing := sendCommand(t, nc, commands.CommandRequest{
+		Type:  commands.CommandBoardConfig,
+		Board: "no-such-board",
+	})
+	if missing.Status != "error" {
+		t.Fatalf("expected missing board to be rejected, got %+v", missing)
+	}
+}
+
+func TestCommandsBoardDeleteStopsWebsockets(t *testing.T) {
+	srv, nc := startTestNATS(t)
+	defer srv.Shutdown()
+	defer nc.Close()
+
+	svc := startService(t, nc)
+	defer svc.shutdown()
+
+	streamName := addRetainedBoardStream(t, svc, "doomedboard")
+
+	replySubject := "_INBOX.boarddelete"
+	wsCh := make(chan *nats.Msg, 4)
+	wsSub, err := nc.Subscribe(replySubject, func(msg *nats.Msg) {
+		wsCh <- msg
+	})
+	if err != nil {
+		t.Fatalf("failed to subscribe to websocket inbox: %v", err)
+	}
+	defer wsSub.Unsubscribe()
+
+	ctrl := &nats.Msg{
+		Subject: websocketEstablished,
+		Reply:   replySubject,
+		Header:  nats.Header{websocketPublishHeader: []string{websocketSubjectPrefix + "doomedboard"}},
+	}
+	if err := nc.PublishMsg(ctrl); err != nil {
+		t.Fatalf("failed to publish control: %v", err)
+	}
+	waitForWebsocketReply(t, svc, "doomedboard", replySubject)
+
+	deleted := sendCommand(t, nc, commands.CommandRequest{
+		Type:  commands.CommandBoardDelete,
+		Board: "doomedboard",
+	})
+	if deleted.Status != "ok" || deleted.Board != "doomedboard" {
+		t.Fatalf("expected ok for doomedboard, got %+v", deleted)
+	}
+
+	select {
+	case msg := <-wsCh:
+		if !strings.Contains(string(msg.Data), "has been deleted") {
+			t.Fatalf("expected board removal fragment, got %s", msg.Data)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected board removal broadcast")
+	}
+
+	if _, err := svc.js.StreamInfo(streamName); !errors.Is(err, nats.ErrStreamNotFound) {
+		t.Fatalf("expected board stream to be deleted, got %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for svc.hasWebsocketReply("doomedboard", replySubject) {
+		if time.Now().After(deadline) {
+			t.Fatal("expected websocket connection to be stopped")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	missing := sendCommand(t, nc, commands.CommandRequest{
+		Type:  commands.CommandBoardDelete,
+		Board: "doomedboard",
+	})
+	if missing.Status != "error" {
+		t.Fatalf("expected deleting a missing board to fail, got %+v", missing)
+	}
+}
+
+func TestCommandsBoardMetadata(t *testing.T) {
+	srv, nc := startTestNATS(t)
+	defer srv.Shutdown()
+	defer nc.Close()
+
+	svc := startService(t, nc)
+	defer svc.shutdown()
+
+	created := sendCommand(t, nc, commands.CommandRequest{
+		Type:      commands.CommandBoardCreate,
+		Board:     "metaboard",
+		Retention: &commands.BoardRetention{Storage: "memory"},
+		Metadata: &commands.BoardMetadata{
+			Title:       "Incident room",
+			Description: "Live notes during incidents",
+			Owner:       "sre",
+			Tags:        []string{"Ops", "ops", " oncall "},
+		},
+	})
+	if created.Status != "ok" || created.Metadata == nil {
+		t.Fatalf("expected ok status with metadata, got %+v", created)
+	}
+	if created.Metadata.CreatedAt.IsZero() {
+		t.Fatal("expected board creation time to be recorded")
+	}
+	if strings.Join(created.Metadata.Tags, ",") != "ops,oncall" {
+		t.Fatalf("expected normalized tags, got %v", created.Metadata.Tags)
+	}
+
+	updated := sendCommand(t, nc, commands.CommandRequest{
+		Type:     commands.CommandBoardUpdate,
+		Board:    "metaboard",
+		Metadata: &commands.BoardMetadata{Description: "Incident war room"},
+	})
+	if updated.Status != "ok" || updated.Metadata == nil {
+		t.Fatalf("expected ok status with metadata, got %+v", updated)
+	}
+	if updated.Metadata.Title != "Incident room" || updated.Metadata.Description != "Incident war room" {
+		t.Fatalf("expected description change to keep the title, got %+v", updated.Metadata)
+	}
+
+	listed := sendCommand(t, nc, commands.CommandRequest{Type: commands.CommandBoardList})
+	var found *commands.BoardMetadata
+	for i := range listed.BoardMetadata {
+		if listed.BoardMetadata[i].Name == "metaboard" {
+			found = &listed.BoardMetadata[i]
+		}
+	}
+	if found == nil || found.Owner != "sre" || !found.CreatedAt.Equal(created.Metadata.CreatedAt) {
+		t.Fatalf("expected board list to carry metadata, got %+v", listed.BoardMetadata)
+	}
+
+	resp, err := nc.Request(boardSubjectPrefix+"metaboard", nil, 2*time.Second)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if !strings.Contains(string(resp.Data), "Incident room") || !strings.Contains(string(resp.Data), "oncall") {
+		t.Fatalf("expected board header to show metadata, got %s", resp.Data)
+	}
+
+	missing := sendCommand(t, nc, commands.CommandRequest{
+		Type:     commands.CommandBoardUpdate,
+		Board:    "no-such-board",
+		Metadata: &commands.BoardMetadata{Title: "Nope"},
+	})
+	if missing.Status != "error" {
+		t.Fatalf("expected updating a missing board to fail, got %+v", missing)
+	}
+}
+
+func TestBoardHistoryPages(t *testing.T) {
+	srv, nc := startTestNATS(t)
+	defer srv.Shutdown()
+	defer nc.Close()
+
+	svc := startService(t, nc)
+	defer svc.shutdown()
+
+	addRetainedBoardStream(t, svc, "histboard")
+	var first commands.CommandResponse
+	for i := range 25 {
+		resp := sendCommand(t, nc, commands.CommandRequest{
+			Type:    commands.CommandText,
+			Board:   "histboard",
+			Content: "sling " + strconv.Itoa(i),
+		})
+		if resp.Status != "ok" {
+			t.Fatalf("expected ok status, got %+v", resp)
+		}
+		if i == 0 {
+			first = resp
+		}
+	}
+	edited := sendCommand(t, nc, commands.CommandRequest{
+		Type:    commands.CommandSlingUpdate,
+		Board:   "histboard",
+		ID:      first.ID,
+		Content: "first sling, edited",
+	})
+	if edited.Status != "ok" {
+		t.Fatalf("expected ok status, got %+v", edited)
+	}
+
+	page, err := nc.Request(boardSubjectPrefix+"histboard", nil, 2*time.Second)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	body := string(page.Data)
+	if got := strings.Count(body, "data-seq="); got != defaultHistoryLimit {
+		t.Fatalf("expected %d slings on the board page, got %d", defaultHistoryLimit, got)
+	}
+	if !strings.Contains(body, "sling 24") || strings.Contains(body, "sling 4<") {
+		t.Fatal("expected the newest slings on the board page")
+	}
+	if !strings.Contains(body, `data-history-seq="26"`) || strings.Contains(body, `id="load-older" class="btn-secondary" hidden`) {
+		t.Fatalf("expected history sequence and a visible load older control")
+	}
+
+	req := &nats.Msg{
+		Subject: boardSubjectPrefix + "histboard" + strings.TrimPrefix(boardHistoryWildcard, boardSubjectPrefix+"*"),
+		Header:  nats.Header{originalQueryHeader: []string{"before=6&limit=10"}},
+	}
+	older, err := nc.RequestMsg(req, 2*time.Second)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if got := older.Header.Get("Status-Code"); got != "200" {
+		t.Fatalf("expected status 200, got %q: %s", got, older.Data)
+	}
+	body = string(older.Data)
+	if got := strings.Count(body, "data-seq="); got != 5 {
+		t.Fatalf("expected 5 older slings, got %d", got)
+	}
+	if !strings.Contains(body, "first sling, edited") {
+		t.Fatal("expected the latest revision of an edited sling")
+	}
+	if !strings.Contains(body, "hidden") {
+		t.Fatal("expected load older control to be hidden at the start of the history")
+	}
+
+	// Once the original has aged out, the revision holds the card's place.
+	if err := svc.js.DeleteMsg(streamPrefix+"histboard", 1); err != nil {
+		t.Fatalf("failed to delete original: %v", err)
+	}
+	page, err = nc.Request(boardSubjectPrefix+"histboard", nil, 2*time.Second)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	body = string(page.Data)
+	if !strings.Contains(body, "first sling, edited") || !strings.Contains(body, `data-seq="26"`) {
+		t.Fatal("expected the revision of a sling whose original is gone")
+	}
+}
+
+func TestCommandsSlingReactAggregates(t *testing.T) {
+	srv, nc := startTestNATS(t)
+	defer srv.Shutdown()
+	defer nc.Close()
+
+	svc := startService(t, nc)
+	defer svc.shutdown()
+
+	addRetainedBoardStream(t, svc, "reactboard")
+	created := sendCommand(t, nc, commands.CommandRequest{
+		Type:    commands.CommandText,
+		Board:   "reactboard",
+		Content: "ship it?",
+	})
+
+	replySubject := "_INBOX.react"
+	wsCh := make(chan *nats.Msg, 8)
+	wsSub, err := nc.Subscribe(replySubject, func(msg *nats.Msg) {
+		wsCh <- msg
+	})
+	if err != nil {
+		t.Fatalf("failed to subscribe to websocket inbox: %v", err)
+	}
+	defer wsSub.Unsubscribe()
+	svc.wsMu.Lock()
+	svc.wsConns[replySubject] = &wsConnection{board: "reactboard", reply: replySubject}
+	svc.wsMu.Unlock()
+
+	for _, reaction := range []string{"👍", "🎉", "👍"} {
+		resp := sendCommand(t, nc, commands.CommandRequest{
+			Type:     commands.CommandSlingReact,
+			Board:    "reactboard",
+			ID:       created.ID,
+			Reaction: reaction,
+		})
+		if resp.Status != "ok" {
+			t.Fatalf("expected ok status, got %+v", resp)
+		}
+	}
+
+	var last string
+	for range 3 {
+		select {
+		case msg := <-wsCh:
+			last = string(msg.Data)
+		case <-time.After(2 * time.Second):
+			t.Fatal("expected reaction broadcast")
+		}
+	}
+	if !strings.Contains(last, `id="reactions-`+created.ID+`"`) || !strings.Contains(last, `data-patch-mode="outer"`) {
+		t.Fatalf("expected in-place reactions patch, got %s", last)
+	}
+
+	reactions, err := svc.slingReactions("reactboard", created.ID)
+	if err != nil {
+		t.Fatalf("failed to read reactions: %v", err)
+	}
+	if len(reactions) != 2 || reactions[0].Emoji != "👍" || reactions[0].Count != 2 || reactions[1].Count != 1 {
+		t.Fatalf("expected aggregated reactions, got %+v", reactions)
+	}
+
+	page, err := nc.Request(boardSubjectPrefix+"reactboard", nil, 2*time.Second)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if !strings.Contains(string(page.Data), `<span class="sling-reaction__count">2</span>`) {
+		t.Fatal("expected reaction counts in the board render")
+	}
+
+	for _, reaction := range []string{"not an emoji", "lol", "<b>", "👍👍"} {
+		invalid := sendCommand(t, nc, commands.CommandRequest{
+			Type:     commands.CommandSlingReact,
+			Board:    "reactboard",
+			ID:       created.ID,
+			Reaction: reaction,
+		})
+		if invalid.Status != "error" {
+			t.Fatalf("expected reaction %q to be rejected, got %+v", reaction, invalid)
+		}
+	}
+	for _, reaction := range []string{"❤️", "👍🏽", "👩‍💻", "🇳🇴", "#️⃣"} {
+		if !validReaction(reaction) {
+			t.Fatalf("expected %q to be a valid reaction", reaction)
+		}
+	}
+
+	for _, command := range []commands.CommandType{commands.CommandSlingReact, commands.CommandSlingReact, commands.CommandSlingUnreact} {
+		resp := sendCommand(t, nc, commands.CommandRequest{
+			Type:     command,
+			Board:    "reactboard",
+			Author:   "ada",
+			ID:       created.ID,
+			Reaction: "🚀",
+		})
+		if resp.Status != "ok" {
+			t.Fatalf("expected ok status for %s, got %+v", command, resp)
+		}
+	}
+	reactions, err = svc.slingReactions("reactboard", created.ID)
+	if err != nil {
+		t.Fatalf("failed to read reactions: %v", err)
+	}
+	if len(reactions) != 2 {
+		t.Fatalf("expected the taken back reaction to be gone, got %+v", reactions)
+	}
+	notMine := sendCommand(t, nc, commands.CommandRequest{
+		Type:     commands.CommandSlingUnreact,
+		Board:    "reactboard",
+		Author:   "ada",
+		ID:       created.ID,
+		Reaction: "👍",
+	})
+	if notMine.Status != "error" {
+		t.Fatalf("expected removing someone else's reaction to fail, got %+v", notMine)
+	}
+
+	missing := sendCommand(t, nc, commands.CommandRequest{
+		Type:     commands.CommandSlingReact,
+		Board:    "reactboard",
+		ID:       "404",
+		Reaction: "👍",
+	})
+	if missing.Status != "error" {
+		t.Fatalf("expected reaction to a missing sling to be rejected, got %+v", missing)
+	}
+}
+
+func TestCommandsRepliesNestUnderParent(t *testing.T) {
+	srv, nc := startTestNATS(t)
+	defer srv.Shutdown()
+	defer nc.Close()
+
+	svc := startService(t, nc)
+	defer svc.shutdown()
+
+	addRetainedBoardStream(t, svc, "threadboard")
+	parent := sendCommand(t, nc, commands.CommandRequest{
+		Type:    commands.CommandText,
+		Board:   "threadboard",
+		Content: "lunch?",
+	})
+	reply := sendCommand(t, nc, commands.CommandRequest{
+		Type:     commands.CommandText,
+		Board:    "threadboard",
+		Content:  "tacos",
+		ParentID: parent.ID,
+	})
+	nested := sendCommand(t, nc, commands.CommandRequest{
+		Type:     commands.CommandText,
+		Board:    "threadboard",
+		Content:  "seconded",
+		ParentID: reply.ID,
+	})
+	if reply.Status != "ok" || nested.Status != "ok" {
+		t.Fatalf("expected replies to be accepted, got %+v and %+v", reply, nested)
+	}
+
+	stored, err := svc.findStoredSling("threadboard", nested.ID)
+	if err != nil || stored == nil {
+		t.Fatalf("failed to read nested reply: %v", err)
+	}
+	if stored.ParentID != parent.ID {
+		t.Fatalf("expected reply to a reply to join the parent thread, got parent %q", stored.ParentID)
+	}
+
+	page, err := nc.Request(boardSubjectPrefix+"threadboard", nil, 2*time.Second)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	body := string(page.Data)
+	list := strings.Index(body, `id="replies-list-`+parent.ID+`"`)
+	if list < 0 {
+		t.Fatal("expected a reply list under the parent sling")
+	}
+	tacos := strings.Index(body, "tacos")
+	seconded := strings.Index(body, "seconded")
+	if tacos < list || seconded < tacos {
+		t.Fatal("expected replies inside the parent thread, oldest first")
+	}
+
+	patch, err := renderStreamSling(stored, templates.SlingMeta{})
+	if err != nil {
+		t.Fatalf("failed to render reply: %v", err)
+	}
+	if !strings.Contains(string(patch), `data-patch-mode="append"`) || !strings.Contains(string(patch), "#replies-list-"+parent.ID) {
+		t.Fatalf("expected reply to append to the parent thread, got %s", patch)
+	}
+
+	missing := sendCommand(t, nc, commands.CommandRequest{
+		Type:     commands.CommandText,
+		Board:    "threadboard",
+		Content:  "anyone?",
+		ParentID: "404",
+	})
+	if missing.Status != "error" {
+		t.Fatalf("expected reply to a missing sling to be rejected, got %+v", missing)
+	}
+
+	deleted := sendCommand(t, nc, commands.CommandRequest{
+		Type:  commands.CommandSlingDelete,
+		Board: "threadboard",
+		ID:    parent.ID,
+	})
+	if deleted.Status != "ok" {
+		t.Fatalf("expected parent delete to succeed, got %+v", deleted)
+	}
+	for _, id := range []string{reply.ID, nested.ID} {
+		sling, err := svc.findStoredSling("threadboard", id)
+		if err != nil {
+			t.Fatalf("failed to look up reply: %v", err)
+		}
+		if sling != nil {
+			t.Fatalf("expected reply %s to be deleted with its parent", id)
+		}
+	}
+}
+
+func TestBoardStreamUpgradeMovesSlingsToTheirSubject(t *testing.T) {
+	srv, nc := startTestNATS(t)
+	defer srv.Shutdown()
+	defer nc.Close()
+
+	svc := startService(t, nc)
+	defer svc.shutdown()
+
+	streamName := streamPrefix + "oldboard"
+	if _, err := svc.js.AddStream(&nats.StreamConfig{
+		Name:     streamName,
+		Subjects: []string{commandSubjectPrefix + "oldboard"},
+		Storage:  nats.MemoryStorage,
+	}); err != nil {
+		t.Fatalf("failed to create board stream: %v", err)
+	}
+	for _, sling := range []slingmessage.SlingMessage{
+		{ID: "1", MimeType: "text/plain", Content: []byte("helo")},
+		{ID: "2", ParentID: "1", MimeType: "text/plain", Content: []byte("tacos")},
+		{ID: "1", MimeType: "text/plain", Content: []byte("hello"), EditedAt: time.Now().UTC()},
+	} {
+		payload, _ := json.Marshal(sling)
+		if _, err := svc.js.Publish(commandSubjectPrefix+"oldboard", payload); err != nil {
+			t.Fatalf("failed to publish sling: %v", err)
+		}
+	}
+
+	if _, err := svc.ensureBoardStream("oldboard"); err != nil {
+		t.Fatalf("failed to upgrade board stream: %v", err)
+	}
+	info, err := svc.js.StreamInfo(streamName)
+	if err != nil {
+		t.Fatalf("failed to read stream info: %v", err)
+	}
+	if !slices.Equal(info.Config.Subjects, []string{boardSlingsSubject("oldboard")}) || !info.Config.AllowDirect || info.State.Msgs != 3 {
+		t.Fatalf("expected the stream to hold the moved slings on their own subjects, got %+v %+v", info.Config.Subjects, info.State)
+	}
+
+	sling, err := svc.findStoredSling("oldboard", "1")
+	if err != nil || sling == nil || string(sling.Content) != "hello" {
+		t.Fatalf("expected the latest revision of the moved sling, got %+v %v", sling, err)
+	}
+	thread, err := svc.slingThread("oldboard", "1", 0)
+	if err != nil || len(thread) != 1 || string(thread[0].sling.Content) != "tacos" {
+		t.Fatalf("expected the moved reply in the thread, got %+v %v", thread, err)
+	}
+}
+
+func TestCommandsFileStoresPDFObject(t *testing.T) {
+	srv, nc := startTestNATS(t)
+	defer srv.Shutdown()
+	defer nc.Close()
+
+	svc := startService(t, nc)
+	defer svc.shutdown()
+
+	addRetainedBoardStream(t, svc, "pdfboard")
+	document := []byte("%PDF-1.4\n1 0 obj << /Type /Catalog >> endobj\n%%EOF\n")
+	created := sendCommand(t, nc, commands.CommandRequest{
+		Type:     commands.CommandFile,
+		Board:    "pdfboard",
+		Content:  base64.StdEncoding.EncodeToString(document),
+		Filename: "runbook.pdf",
+	})
+	if created.Status != "ok" {
+		t.Fatalf("expected ok status, got %+v", created)
+	}
+
+	stored, err := svc.findStoredSling("pdfboard", created.ID)
+	if err != nil || stored == nil {
+		t.Fatalf("failed to read stored sling: %v", err)
+	}
+	if stored.MimeType != pdfMimeType || stored.ObjectID != created.ID || len(stored.Content) != 0 || stored.Filename != "runbook.pdf" {
+		t.Fatalf("expected a PDF object reference in the stream, got %+v", stored)
+	}
+
+	page, err := nc.Request(boardSubjectPrefix+"pdfboard", nil, 2*time.Second)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	url := objectURL("pdfboard", created.ID)
+	if !strings.Contains(string(page.Data), `data-pdf-src="`+url+`"`) {
+		t.Fatalf("expected the PDF card to point at %s", url)
+	}
+
+	objectSubject := boardSubjectPrefix + "pdfboard.OBJECTS." + created.ID
+	object, err := nc.Request(objectSubject, nil, 2*time.Second)
+	if err != nil {
+		t.Fatalf("object request failed: %v", err)
+	}
+	if got := object.Header.Get("Status-Code"); got != "200" {
+		t.Fatalf("expected status 200, got %q: %s", got, object.Data)
+	}
+	if got := object.Header.Get("Content-Type"); got != pdfMimeType {
+		t.Fatalf("expected PDF content type, got %q", got)
+	}
+	if !strings.Contains(object.Header.Get("Cache-Control"), "immutable") {
+		t.Fatalf("expected immutable caching, got %q", object.Header.Get("Cache-Control"))
+	}
+	if string(object.Data) != string(document) {
+		t.Fatal("expected the stored PDF bytes")
+	}
+
+	deleted := sendCommand(t, nc, commands.CommandRequest{
+		Type:  commands.CommandSlingDelete,
+		Board: "pdfboard",
+		ID:    created.ID,
+	})
+	if deleted.Status != "ok" {
+		t.Fatalf("expected delete to succeed, got %+v", deleted)
+	}
+	missing, err := nc.Request(objectSubject, nil, 2*time.Second)
+	if err != nil {
+		t.Fatalf("object request failed: %v", err)
+	}
+	if got := missing.Header.Get("Status-Code"); got != "404" {
+		t.Fatalf("expected deleted object to be gone, got status %q", got)
+	}
+}
+
+func TestCommandsChunkedUploadResumesAndStoresObject(t *testing.T) {
+	srv, nc := startTestNATS(t)
+	defer srv.Shutdown()
+	defer nc.Close()
+
+	svc := startService(t, nc)
+	defer svc.shutdown()
+
+	data := make([]byte, maxRenderedObject+300<<10)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	chunkSize := 400 << 10
+
+	addRetainedBoardStream(t, svc, "uploadboard")
+	started := sendCommand(t, nc, commands.CommandRequest{
+		Type:     commands.CommandUploadStart,
+		Board:    "uploadboard",
+		Filename: "dump.bin",
+		Size:     int64(len(data)),
+	})
+	if started.Status != "ok" || started.ID == "" {
+		t.Fatalf("expected the upload to start, got %+v", started)
+	}
+
+	appendChunk := func(offset int) commands.CommandResponse {
+		end := min(offset+chunkSize, len(data))
+		return sendCommand(t, nc, commands.CommandRequest{
+			Type:    commands.CommandUploadAppend,
+			ID:      started.ID,
+			Offset:  int64(offset),
+			Content: base64.StdEncoding.EncodeToString(data[offset:end]),
+		})
+	}
+	if first := appendChunk(0); first.Status != "ok" || first.Offset != int64(chunkSize) {
+		t.Fatalf("expected the first chunk to be stored, got %+v", first)
+	}
+	if early := sendCommand(t, nc, commands.CommandRequest{Type: commands.CommandUploadCommit, ID: started.ID}); early.Status != "error" {
+		t.Fatalf("expected an incomplete upload to be refused, got %+v", early)
+	}
+	if repeated := appendChunk(0); repeated.Status != "error" || repeated.Offset != int64(chunkSize) {
+		t.Fatalf("expected a conflict with the offset to resume from, got %+v", repeated)
+	}
+	for offset := chunkSize; offset < len(data); offset += chunkSize {
+		if next := appendChunk(offset); next.Status != "ok" {
+			t.Fatalf("expected chunk at %d to be stored, got %+v", offset, next)
+		}
+	}
+
+	// A commit already under way keeps others from slinging the file again.
+	kv, err := svc.uploadState(false)
+	if err != nil {
+		t.Fatalf("failed to open upload store: %v", err)
+	}
+	state, revision, err := loadUpload(kv, started.ID)
+	if err != nil {
+		t.Fatalf("failed to load upload: %v", err)
+	}
+	state.CommitInstance = svc.id
+	state.CommitStarted = time.Now().UTC()
+	marked, _ := json.Marshal(state)
+	if revision, err = kv.Update(started.ID, marked, revision); err != nil {
+		t.Fatalf("failed to mark upload: %v", err)
+	}
+	if busy := sendCommand(t, nc, commands.CommandRequest{Type: commands.CommandUploadCommit, ID: started.ID}); busy.Status != "error" || !strings.Contains(busy.Message, "already being committed") {
+		t.Fatalf("expected a commit under way to be refused, got %+v", busy)
+	}
+	if svc.committing(upload{CommitInstance: svc.id, CommitStarted: time.Now().Add(-2 * uploadCommitTimeout)}) {
+		t.Fatal("expected a commit past its timeout to be taken over")
+	}
+
+	// The mark of a commit on an instance that stopped is taken over.
+	state.CommitInstance = "stopped"
+	abandoned, _ := json.Marshal(state)
+	if _, err := kv.Update(started.ID, abandoned, revision); err != nil {
+		t.Fatalf("failed to mark upload: %v", err)
+	}
+
+	created := sendCommand(t, nc, commands.CommandRequest{Type: commands.CommandUploadCommit, ID: started.ID})
+	if created.Status != "ok" || created.Board != "uploadboard" {
+		t.Fatalf("expected the upload to be slung, got %+v", created)
+	}
+
+	stored, err := svc.findStoredSling("uploadboard", created.ID)
+	if err != nil || stored == nil {
+		t.Fatalf("failed to read stored sling: %v", err)
+	}
+	if stored.ObjectID != created.ID || stored.Size != int64(len(data)) || stored.Filename != "dump.bin" || stored.MimeType != defaultObjectMime {
+		t.Fatalf("expected an object reference for the upload, got %+v", stored)
+	}
+	obs, err := svc.objectBucket("uploadboard", false)
+	if err != nil {
+		t.Fatalf("failed to open object store: %v", err)
+	}
+	object, err := obs.GetBytes(created.ID)
+	if err != nil || string(object) != string(data) {
+		t.Fatalf("expected the stored object to match the uploaded file: %v", err)
+	}
+
+	// The object is larger than a NATS message, so a plain request gets it
+	// whole over several messages ending with an empty one, and ranges are
+	// read from the chunks holding them.
+	objectSubject := boardSubjectPrefix + "uploadboard.OBJECTS." + created.ID
+	inbox := nats.NewInbox()
+	parts, err := nc.SubscribeSync(inbox)
+	if err != nil {
+		t.Fatalf("failed to subscribe to the reply inbox: %v", err)
+	}
+	defer parts.Unsubscribe()
+	if err := nc.PublishRequest(objectSubject, inbox, nil); err != nil {
+		t.Fatalf("object request failed: %v", err)
+	}
+	first, err := parts.NextMsg(2 * time.Second)
+	if err != nil {
+		t.Fatalf("object request failed: %v", err)
+	}
+	if got := first.Header.Get("Status-Code"); got != "200" || first.Header.Get("Content-Length") != "" || first.Header.Get("Content-Range") != "" {
+		t.Fatalf("expected a streamed whole object, got %s %v", got, first.Header)
+	}
+	if len(first.Data) != int(svc.maxRangeLength()) {
+		t.Fatalf("expected the first part to fill a message, got %d bytes", len(first.Data))
+	}
+	body := append([]byte(nil), first.Data...)
+	for {
+		next, err := parts.NextMsg(2 * time.Second)
+		if err != nil {
+			t.Fatalf("object stream ended early: %v", err)
+		}
+		if len(next.Data) == 0 {
+			break
+		}
+		body = append(body, next.Data...)
+	}
+	if string(body) != string(data) {
+		t.Fatalf("expected the streamed object to match the upload, got %d of %d bytes", len(body), len(data))
+	}
+	part, err := nc.RequestMsg(&nats.Msg{
+		Subject: objectSubject,
+		Header:  nats.Header{"Range": []string{"bytes=131000-400000"}},
+	}, 2*time.Second)
+	if err != nil {
+		t.Fatalf("range request failed: %v", err)
+	}
+	if string(part.Data) != string(data[131000:400001]) {
+		t.Fatal("unexpected body of a range across chunks")
+	}
+
+	if _, err := kv.Get(started.ID); !errors.Is(err, nats.ErrKeyNotFound) {
+		t.Fatalf("expected the upload state to be removed, got %v", err)
+	}
+	info, err := svc.js.StreamInfo(uploadStream)
+	if err != nil || info.State.Msgs != 0 {
+		t.Fatalf("expected the chunks to be purged, got %+v: %v", info, err)
+	}
+}
+
+func TestUploadEndpointAcceptsRawAndMultipartBodies(t *testing.T) {
+	srv, nc := startTestNATS(t)
+	defer srv.Shutdown()
+	defer nc.Close()
+
+	svc := startService(t, nc)
+	defer svc.shutdown()
+
+	addRetainedBoardStream(t, svc, "rawboard")
+	upload := func(header nats.Header, body []byte) commands.CommandResponse {
+		t.Helper()
+		header.Set("Accept", "application/json")
+		resp, err := nc.RequestMsg(&nats.Msg{Subject: uploadSubject, Header: header, Data: body}, 2*time.Second)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		var response commands.CommandResponse
+		if err := json.Unmarshal(resp.Data, &response); err != nil {
+			t.Fatalf("invalid response json: %v", err)
+		}
+		return response
+	}
+
+	script := []byte("#!/bin/sh\necho deploy\n")
+	raw := upload(nats.Header{
+		"Content-Type":      []string{"application/x-www-form-urlencoded"},
+		filenameHeader:      []string{"deploy%20it.sh"},
+		originalQueryHeader: []string{"board=rawboard&author=ci&ttl=1h&lines=2"},
+	}, script)
+	if raw.Status != "ok" || raw.Board != "rawboard" {
+		t.Fatalf("expected the raw upload to be slung, got %+v", raw)
+	}
+	stored, err := svc.findStoredSling("rawboard", raw.ID)
+	if err != nil || stored == nil {
+		t.Fatalf("failed to read stored sling: %v", err)
+	}
+	if stored.Filename != "deploy it.sh" || stored.Sender != "ci" || stored.ExpiresAt.IsZero() || stored.Excerpt == nil || stored.Excerpt.FirstLine != 2 || stored.MimeType == "application/x-www-form-urlencoded" {
+		t.Fatalf("expected the upload options on the sling, got %+v", stored)
+	}
+
+	var form bytes.Buffer
+	writer := multipart.NewWriter(&form)
+	_ = writer.WriteField("board", "rawboard")
+	_ = writer.WriteField("author", "browser")
+	part, _ := writer.CreateFormFile("file", "costs.csv")
+	_, _ = part.Write([]byte("service,amount\napi,10\n"))
+	_ = writer.Close()
+	multipartResponse := upload(nats.Header{"Content-Type": []string{writer.FormDataContentType()}}, form.Bytes())
+	if multipartResponse.Status != "ok" {
+		t.Fatalf("expected the multipart upload to be slung, got %+v", multipartResponse)
+	}
+	stored, err = svc.findStoredSling("rawboard", multipartResponse.ID)
+	if err != nil || stored == nil {
+		t.Fatalf("failed to read stored sling: %v", err)
+	}
+	if stored.Filename != "costs.csv" || stored.Sender != "browser" || stored.MimeType != "text/csv; charset=utf-8" || stored.Size != 22 {
+		t.Fatalf("expected the form file on the sling, got %+v", stored)
+	}
+
+	if empty := upload(nats.Header{originalQueryHeader: []string{"board=rawboard"}}, nil); empty.Status != "error" {
+		t.Fatalf("expected an empty upload to be refused, got %+v", empty)
+	}
+	if invalid := upload(nats.Header{originalQueryHeader: []string{"board=rawboard&autoplay=often"}}, script); invalid.Status != "error" {
+		t.Fatalf("expected an invalid option to be refused, got %+v", invalid)
+	}
+}
+
+func TestCodeSlingsPickLexerAndBoardStyle(t *testing.T) {
+	srv, nc := startTestNATS(t)
+	defer srv.Shutdown()
+	defer nc.Close()
+
+	svc := startService(t, nc)
+	defer svc.shutdown()
+
+	script := &slingmessage.SlingMessage{
+		ID:       "1",
+		MimeType: "text/plain; charset=utf-8",
+		Filename: "deploy.py",
+		Content:  []byte("def deploy():\n    return True\n"),
+	}
+	if lexer := codeLexer(script); lexer == nil || lexer.Config().Name != "Python" {
+		t.Fatalf("expected the python lexer from the filename, got %v", lexer)
+	}
+	script.Language = "bash"
+	if lexer := codeLexer(script); lexer == nil || lexer.Config().Name != "Bash" {
+		t.Fatalf("expected the explicit language to win, got %v", lexer)
+	}
+	notes := &slingmessage.SlingMessage{ID: "2", MimeType: "text/plain", Filename: "notes.txt", Content: []byte("def not code")}
+	if lexer := codeLexer(notes); lexer != nil {
+		t.Fatalf("expected plain text files to stay plain, got %v", lexer.Config().Name)
+	}
+
+	addRetainedBoardStream(t, svc, "codeboard")
+	created := sendCommand(t, nc, commands.CommandRequest{
+		Type:     commands.CommandFile,
+		Board:    "codeboard",
+		Content:  base64.StdEncoding.EncodeToString([]byte("resource \"aws_s3_bucket\" \"logs\" {}\n")),
+		Filename: "main.tf",
+		Language: "terraform",
+	})
+	if created.Status != "ok" {
+		t.Fatalf("expected ok status, got %+v", created)
+	}
+	stored, err := svc.findStoredSling("codeboard", created.ID)
+	if err != nil || stored == nil {
+		t.Fatalf("failed to read stored sling: %v", err)
+	}
+	if stored.Language != "Terraform" {
+		t.Fatalf("expected the canonical lexer name to be stored, got %q", stored.Language)
+	}
+
+	unknown := sendCommand(t, nc, commands.CommandRequest{
+		Type:     commands.CommandFile,
+		Board:    "codeboard",
+		Content:  base64.StdEncoding.EncodeToString([]byte("x")),
+		Filename: "x.txt",
+		Language: "klingon",
+	})
+	if unknown.Status != "error" {
+		t.Fatalf("expected an unknown language to be rejected, got %+v", unknown)
+	}
+
+	rejected := sendCommand(t, nc, commands.CommandRequest{
+		Type:     commands.CommandBoardUpdate,
+		Board:    "codeboard",
+		Metadata: &commands.BoardMetadata{CodeStyle: "no-such-style"},
+	})
+	if rejected.Status != "error" {
+		t.Fatalf("expected an unknown code style to be rejected, got %+v", rejected)
+	}
+	updated := sendCommand(t, nc, commands.CommandRequest{
+		Type:     commands.CommandBoardUpdate,
+		Board:    "codeboard",
+		Metadata: &commands.BoardMetadata{CodeStyle: "github"},
+	})
+	if updated.Status != "ok" || updated.Metadata == nil || updated.Metadata.CodeStyle != "github" {
+		t.Fatalf("expected the code style to be stored, got %+v", updated)
+	}
+
+	page, err := nc.Request(boardSubjectPrefix+"codeboard", nil, 2*time.Second)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	body := string(page.Data)
+	css, err := codeStyleCSS("github")
+	if err != nil {
+		t.Fatalf("failed to render code style: %v", err)
+	}
+	if !strings.Contains(body, `<style id="code-style">`+css) {
+		t.Fatal("expected the board code style sheet in the page")
+	}
+	if !strings.Contains(body, `class="chroma"`) {
+		t.Fatal("expected the terraform sling to be highlighted")
+	}
+}
+
+func TestCommandsFileCodeExcerpt(t *testing.T) {
+	srv, nc := startTestNATS(t)
+	defer srv.Shutdown()
+	defer nc.Close()
+
+	svc := startService(t, nc)
+	defer svc.shutdown()
+
+	var source strings.Builder
+	for i := 1; i <= 100; i++ {
+		source.WriteString("x" + strconv.Itoa(i) + " := " + strconv.Itoa(i) + "\n")
+	}
+
+	addRetainedBoardStream(t, svc, "excerptboard")
+	created := sendCommand(t, nc, commands.CommandRequest{
+		Type:      commands.CommandFile,
+		Board:     "excerptboard",
+		Content:   base64.StdEncoding.EncodeToString([]byte(source.String())),
+		Filename:  "handler.go",
+		Lines:     "40-80",
+		Highlight: "57",
+		Title:     "handler.go (incident 42)",
+	})
+	if created.Status != "ok" {
+		t.Fatalf("expected ok status, got %+v", created)
+	}
+
+	stored, err := svc.findStoredSling("excerptboard", created.ID)
+	if err != nil || stored == nil {
+		t.Fatalf("failed to read stored sling: %v", err)
+	}
+	if stored.ObjectID != created.ID || len(stored.Content) != 0 {
+		t.Fatalf("expected the file body to be stored as an object, got %+v", stored)
+	}
+	svc.loadObjectContent("excerptboard", stored)
+	if stored.Excerpt == nil || stored.Excerpt.FirstLine != 40 || len(stored.Excerpt.Highlight) != 1 || stored.Excerpt.Highlight[0] != [2]int{57, 57} {
+		t.Fatalf("expected excerpt options to be stored, got %+v", stored.Excerpt)
+	}
+	if got := strings.Count(string(stored.Content), "\n"); got != 41 || !strings.HasPrefix(string(stored.Content), "x40 ") {
+		t.Fatalf("expected only lines 40-80 to be stored, got %d lines", got)
+	}
+
+	card, err := renderSlingCard(stored, templates.SlingMeta{Board: "excerptboard"})
+	if err != nil {
+		t.Fatalf("failed to render excerpt: %v", err)
+	}
+	if !strings.Contains(card, "handler.go (incident 42)") || !strings.Contains(card, "lines 40–80") {
+		t.Fatal("expected the excerpt header")
+	}
+	if !strings.Contains(card, `<span class="ln">40</span>`) || !strings.Contains(card, `<span class="line hl"><span class="ln">57</span>`) {
+		t.Fatalf("expected numbered lines with line 57 highlighted, got %s", card)
+	}
+
+	invalid := sendCommand(t, nc, commands.CommandRequest{
+		Type:     commands.CommandFile,
+		Board:    "excerptboard",
+		Content:  base64.StdEncoding.EncodeToString([]byte(source.String())),
+		Filename: "handler.go",
+		Lines:    "80-40",
+	})
+	if invalid.Status != "error" {
+		t.Fatalf("expected an inverted line range to be rejected, got %+v", invalid)
+	}
+}
+
+func TestCommandsFileRendersSortedTable(t *testing.T) {
+	srv, nc := startTestNATS(t)
+	defer srv.Shutdown()
+	defer nc.Close()
+
+	svc := startService(t, nc)
+	defer svc.shutdown()
+
+	var csv strings.Builder
+	csv.WriteString("service,amount\n")
+	for i := 1; i <= maxTableRows+5; i++ {
+		csv.WriteString("svc-" + strconv.Itoa(i) + "," + strconv.Itoa(i*10) + "\n")
+	}
+
+	addRetainedBoardStream(t, svc, "tableboard")
+	created := sendCommand(t, nc, commands.CommandRequest{
+		Type:     commands.CommandFile,
+		Board:    "tableboard",
+		Content:  base64.StdEncoding.EncodeToString([]byte(csv.String())),
+		Filename: "costs.csv",
+		SortBy:   "-amount",
+	})
+	if created.Status != "ok" {
+		t.Fatalf("expected ok status, got %+v", created)
+	}
+
+	stored, err := svc.findStoredSling("tableboard", created.ID)
+	if err != nil || stored == nil {
+		t.Fatalf("failed to read stored sling: %v", err)
+	}
+	if stored.ObjectID != created.ID || len(stored.Content) != 0 {
+		t.Fatalf("expected the file body to be stored as an object, got %+v", stored)
+	}
+	svc.loadObjectContent("tableboard", stored)
+	if stored.Table == nil || stored.Table.SortBy != "-amount" {
+		t.Fatalf("expected the sort option to be stored, got %+v", stored.Table)
+	}
+
+	card, err := renderSlingCard(stored, templates.SlingMeta{Board: "tableboard"})
+	if err != nil {
+		t.Fatalf("failed to render table: %v", err)
+	}
+	if !strings.Contains(card, "<thead>") || !strings.Contains(card, "amount ▼") {
+		t.Fatalf("expected a header sorted on amount, got %s", card)
+	}
+	if !strings.Contains(card, `<td class="sling-table__cell sling-table__cell--number">2050</td>`) {
+		t.Fatal("expected the amount column to be right-aligned")
+	}
+	if strings.Index(card, "svc-205<") > strings.Index(card, "svc-204<") {
+		t.Fatal("expected rows in descending amount order")
+	}
+	if strings.Contains(card, "svc-1<") || !strings.Contains(card, "5 more rows") {
+		t.Fatal("expected the table to be cut after the first rows")
+	}
+
+	invalid := sendCommand(t, nc, commands.CommandRequest{
+		Type:     commands.CommandFile,
+		Board:    "tableboard",
+		Content:  base64.StdEncoding.EncodeToString([]byte(csv.String())),
+		Filename: "costs.csv",
+		SortBy:   "price",
+	})
+	if invalid.Status != "error" {
+		t.Fatalf("expected an unknown sort column to be rejected, got %+v", invalid)
+	}
+}
+
+func TestStructuredSlingsRenderTreeAndFallback(t *testing.T) {
+	srv, nc := startTestNATS(t)
+	defer srv.Shutdown()
+	defer nc.Close()
+
+	svc := startService(t, nc)
+	defer svc.shutdown()
+
+	addRetainedBoardStream(t, svc, "databoard")
+	created := sendCommand(t, nc, commands.CommandRequest{
+		Type:     commands.CommandFile,
+		Board:    "databoard",
+		Content:  base64.StdEncoding.EncodeToString([]byte(`{"zone":"eu","replicas":3,"limits":{"cpu":"500m","ready":true}}`)),
+		MimeType: "application/json",
+	})
+	if created.Status != "ok" {
+		t.Fatalf("expected ok status, got %+v", created)
+	}
+
+	stored, err := svc.findStoredSling("databoard", created.ID)
+	if err != nil || stored == nil {
+		t.Fatalf("failed to read stored sling: %v", err)
+	}
+	if stored.ObjectID != created.ID || len(stored.Content) != 0 {
+		t.Fatalf("expected the file body to be stored as an object, got %+v", stored)
+	}
+	svc.loadObjectContent("databoard", stored)
+	card, err := renderSlingCard(stored, templates.SlingMeta{Board: "databoard"})
+	if err != nil {
+		t.Fatalf("failed to render json: %v", err)
+	}
+	if !strings.Contains(card, "sling-data__tree") || !strings.Contains(card, `<span class="sling-data__key">limits</span>`) {
+		t.Fatalf("expected a tree for nested json, got %s", card)
+	}
+	if strings.Index(card, ">zone<") > strings.Index(card, ">replicas<") {
+		t.Fatal("expected the tree to keep the key order")
+	}
+	if !strings.Contains(card, `class="chroma"`) || !strings.Contains(card, `<span class="ln">7</span>`) {
+		t.Fatal("expected the pretty-printed source to be highlighted")
+	}
+
+	invalid, err := renderSlingCard(&slingmessage.SlingMessage{
+		ID:       "1",
+		MimeType: "application/x-yaml",
+		Filename: "broken.yaml",
+		Content:  []byte("key: [unclosed\n"),
+	}, templates.SlingMeta{Board: "databoard"})
+	if err != nil {
+		t.Fatalf("failed to render invalid yaml: %v", err)
+	}
+	if !strings.Contains(invalid, "Invalid YAML") || strings.Contains(invalid, "sling-data__tree") || !strings.Contains(invalid, "unclosed") {
+		t.Fatalf("expected the raw text with the parse error, got %s", invalid)
+	}
+}
+
+func TestCommandsFileStoresAttachment(t *testing.T) {
+	srv, nc := startTestNATS(t)
+	defer srv.Shutdown()
+	defer nc.Close()
+
+	svc := startService(t, nc)
+	defer svc.shutdown()
+
+	archive := append([]byte("PK\x03\x04"), make([]byte, 2044)...)
+	addRetainedBoardStream(t, svc, "attachboard")
+	created := sendCommand(t, nc, commands.CommandRequest{
+		Type:     commands.CommandFile,
+		Board:    "attachboard",
+		Content:  base64.StdEncoding.EncodeToString(archive),
+		MimeType: "application/zip",
+		Filename: "logs.zip",
+	})
+	if created.Status != "ok" {
+		t.Fatalf("expected ok status, got %+v", created)
+	}
+
+	stored, err := svc.findStoredSling("attachboard", created.ID)
+	if err != nil || stored == nil {
+		t.Fatalf("failed to read stored sling: %v", err)
+	}
+	if stored.ObjectID != created.ID || stored.Size != int64(len(archive)) || len(stored.Content) != 0 {
+		t.Fatalf("expected the archive to be stored as an object, got %+v", stored)
+	}
+
+	card, err := renderSlingCard(stored, templates.SlingMeta{Board: "attachboard"})
+	if err != nil {
+		t.Fatalf("failed to render attachment: %v", err)
+	}
+	for _, want := range []string{"logs.zip", "application/zip", "2.0 KB", `href="/board/attachboard/objects/` + created.ID + `"`, `download="logs.zip"`} {
+		if !strings.Contains(card, want) {
+			t.Fatalf("expected attachment card to contain %q, got %s", want, card)
+		}
+	}
+
+	object, err := nc.Request(boardSubjectPrefix+"attachboard.OBJECTS."+created.ID, nil, 2*time.Second)
+	if err != nil {
+		t.Fatalf("object request failed: %v", err)
+	}
+	if got := object.Header.Get("Content-Disposition"); got != `attachment; filename=logs.zip` {
+		t.Fatalf("expected the archive to be downloaded, got %q", got)
+	}
+	if object.Header.Get("X-Content-Type-Options") != "nosniff" || object.Header.Get("Content-Security-Policy") != "sandbox" {
+		t.Fatalf("expected objects to be served sandboxed, got %v", object.Header)
+	}
+
+	// Named files of unknown type show their text when it is UTF-8.
+	notes := sendCommand(t, nc, commands.CommandRequest{
+		Type:     commands.CommandFile,
+		Board:    "attachboard",
+		Content:  base64.StdEncoding.EncodeToString([]byte("remember the milk")),
+		MimeType: "application/octet-stream",
+		Filename: "NOTES",
+	})
+	stored, err = svc.findStoredSling("attachboard", notes.ID)
+	if err != nil || stored == nil {
+		t.Fatalf("failed to read stored sling: %v", err)
+	}
+	svc.loadObjectContent("attachboard", stored)
+	card, err = renderSlingCard(stored, templates.SlingMeta{Board: "attachboard"})
+	if err != nil {
+		t.Fatalf("failed to render notes: %v", err)
+	}
+	if !strings.Contains(card, "milk") || strings.Contains(card, "download=") {
+		t.Fatalf("expected the notes to show as text, got %s", card)
+	}
+
+	// Objects whose sling is gone are swept; the others are kept.
+	if err := svc.js.PurgeStream(streamPrefix+"attachboard", &nats.StreamPurgeRequest{Subject: slingSubject("attachboard", "", created.ID)}); err != nil {
+		t.Fatalf("failed to purge sling: %v", err)
+	}
+	if err := svc.sweepObjects("attachboard", time.Now()); err != nil {
+		t.Fatalf("object sweep failed: %v", err)
+	}
+	obs, err := svc.objectBucket("attachboard", false)
+	if err != nil {
+		t.Fatalf("failed to open object store: %v", err)
+	}
+	if _, err := obs.GetInfo(created.ID); !errors.Is(err, nats.ErrObjectNotFound) {
+		t.Fatalf("expected the archive of the aged out sling to be swept, got %v", err)
+	}
+	if _, err := obs.GetInfo(notes.ID); err != nil {
+		t.Fatalf("expected the notes to be kept, got %v", err)
+	}
+}
+
+func TestCommandsFileStoresImageObject(t *testing.T) {
+	srv, nc := startTestNATS(t)
+	defer srv.Shutdown()
+	defer nc.Close()
+
+	svc := startService(t, nc)
+	defer svc.shutdown()
+
+	png := append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 64)...)
+	addRetainedBoardStream(t, svc, "imageboard")
+	created := sendCommand(t, nc, commands.CommandRequest{
+		Type:     commands.CommandFile,
+		Board:    "imageboard",
+		Content:  base64.StdEncoding.EncodeToString(png),
+		Filename: "graph.png",
+	})
+	if created.Status != "ok" {
+		t.Fatalf("expected ok status, got %+v", created)
+	}
+
+	raw, err := svc.js.GetLastMsg(streamPrefix+"imageboard", slingSubject("imageboard", "", created.ID))
+	if err != nil {
+		t.Fatalf("failed to read stream message: %v", err)
+	}
+	var stored slingmessage.SlingMessage
+	if err := json.Unmarshal(raw.Data, &stored); err != nil {
+		t.Fatalf("failed to decode stream message: %v", err)
+	}
+	if stored.ObjectID != created.ID || stored.Size != int64(len(png)) || len(stored.Content) != 0 || stored.MimeType != "image/png" {
+		t.Fatalf("expected only a reference in the stream message, got %+v", stored)
+	}
+
+	svc.loadObjectContent("imageboard", &stored)
+	card, err := renderSlingCard(&stored, templates.SlingMeta{Board: "imageboard"})
+	if err != nil {
+		t.Fatalf("failed to render image: %v", err)
+	}
+	if !strings.Contains(card, `src="/board/imageboard/objects/`+created.ID+`"`) || strings.Contains(card, "data:image/png") {
+		t.Fatalf("expected the image to load from its object, got %s", card)
+	}
+}
+
+func TestBoardObjectServesVideoRanges(t *testing.T) {
+	srv, nc := startTestNATS(t)
+	defer srv.Shutdown()
+	defer nc.Close()
+
+	svc := startService(t, nc)
+	defer svc.shutdown()
+
+	video := make([]byte, 1000)
+	for i := range video {
+		video[i] = byte(i)
+	}
+
+	addRetainedBoardStream(t, svc, "videoboard")
+	created := sendCommand(t, nc, commands.CommandRequest{
+		Type:     commands.CommandFile,
+		Board:    "videoboard",
+		Content:  base64.StdEncoding.EncodeToString(video),
+		MimeType: "video/mp4",
+		Filename: "deploy.mp4",
+		Autoplay: true,
+		Muted:    true,
+		Loop:     true,
+	})
+	if created.Status != "ok" {
+		t.Fatalf("expected ok status, got %+v", created)
+	}
+
+	page, err := nc.Request(boardSubjectPrefix+"videoboard", nil, 2*time.Second)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	body := string(page.Data)
+	if !strings.Contains(body, `<source src="`+objectURL("videoboard", created.ID)+`" type="video/mp4">`) {
+		t.Fatal("expected the video card to load the stored object")
+	}
+	if !strings.Contains(body, "autoplay muted loop") {
+		t.Fatal("expected the playback options on the video player")
+	}
+
+	objectSubject := boardSubjectPrefix + "videoboard.OBJECTS." + created.ID
+	for _, tc := range []struct {
+		rangeHeader  string
+		status       string
+		contentRange string
+		first, last  int
+	}{
+		{"bytes=100-199", "206", "bytes 100-199/1000", 100, 199},
+		{"bytes=900-", "206", "bytes 900-999/1000", 900, 999},
+		{"bytes=-10", "206", "bytes 990-999/1000", 990, 999},
+		{"bytes=2000-", "416", "bytes */1000", 0, -1},
+	} {
+		reply, err := nc.RequestMsg(&nats.Msg{
+			Subject: objectSubject,
+			Header:  nats.Header{"Range": []string{tc.rangeHeader}},
+		}, 2*time.Second)
+		if err != nil {
+			t.Fatalf("range request failed: %v", err)
+		}
+		if got := reply.Header.Get("Status-Code"); got != tc.status {
+			t.Fatalf("%s: expected status %s, got %s", tc.rangeHeader, tc.status, got)
+		}
+		if got := reply.Header.Get("Content-Range"); got != tc.contentRange {
+			t.Fatalf("%s: expected content range %q, got %q", tc.rangeHeader, tc.contentRange, got)
+		}
+		if string(reply.Data) != string(video[tc.first:tc.last+1]) {
+			t.Fatalf("%s: unexpected range body", tc.rangeHeader)
+		}
+	}
+}
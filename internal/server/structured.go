@@ -0,0 +1,197 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/alecthomas/chroma/lexers"
+	"github.com/laetho/slingboard/internal/slingmessage"
+	"github.com/laetho/slingboard/templates"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	jsonMimeType = "application/json"
+	yamlMimeType = "application/x-yaml"
+
+	formatJSON = "json"
+	formatYAML = "yaml"
+
+	// maxTreeNodes bounds the tree of a structured sling. Larger documents
+	// only show their source.
+	maxTreeNodes = 2000
+)
+
+// structuredFormat returns "json" or "yaml" for slings holding structured
+// data, by MIME type or file extension. Excerpts and slings with an explicit
+// language are rendered as code instead.
+func structuredFormat(sling *slingmessage.SlingMessage) string {
+	if sling.Excerpt != nil || sling.Language != "" {
+		return ""
+	}
+	mediaType, _, _ := mime.ParseMediaType(sling.MimeType)
+	switch {
+	case mediaType == jsonMimeType || strings.HasSuffix(mediaType, "+json"):
+		return formatJSON
+	case mediaType == yamlMimeType, mediaType == "application/yaml", mediaType == "text/yaml", mediaType == "text/x-yaml":
+		return formatYAML
+	}
+	switch strings.ToLower(filepath.Ext(sling.Filename)) {
+	case ".json":
+		return formatJSON
+	case ".yaml", ".yml":
+		return formatYAML
+	}
+	return ""
+}
+
+// structuredView pretty-prints and highlights a JSON or YAML sling and builds
+// the tree of its nested values. Content that does not parse keeps its raw
+// text, highlighted, with the parse error.
+func structuredView(sling *slingmessage.SlingMessage, format string) (templates.StructuredData, error) {
+	view := templates.StructuredData{Title: sling.Filename, Format: strings.ToUpper(format)}
+
+	source, documents, err := parseStructured(sling.Content, format)
+	if err != nil {
+		view.Error = err.Error()
+		source = string(sling.Content)
+	}
+	view.Code, err = highlightCode(lexers.Get(format), source, nil)
+	if err != nil {
+		return templates.StructuredData{}, err
+	}
+
+	if len(documents) > 0 {
+		tree := dataTree(documents)
+		if hasNesting(tree) && countNodes(tree) <= maxTreeNodes {
+			view.Tree = &tree
+		}
+	}
+	return view, nil
+}
+
+// parseStructured validates the content and returns it pretty-printed along
+// with its parsed documents. YAML streams may hold several documents.
+func parseStructured(content []byte, format string) (string, []*yaml.Node, error) {
+	if format == formatJSON {
+		var value any
+		if err := json.Unmarshal(content, &value); err != nil {
+			return "", nil, err
+		}
+		var pretty bytes.Buffer
+		if err := json.Indent(&pretty, content, "", "  "); err != nil {
+			return "", nil, err
+		}
+		// JSON is YAML, and the YAML parser keeps the order of object keys.
+		var document yaml.Node
+		if err := yaml.Unmarshal(content, &document); err != nil {
+			return pretty.String(), nil, nil
+		}
+		return pretty.String(), []*yaml.Node{&document}, nil
+	}
+
+	var documents []*yaml.Node
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	for {
+		var document yaml.Node
+		if err := decoder.Decode(&document); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return "", nil, err
+		}
+		documents = append(documents, &document)
+	}
+
+	var pretty bytes.Buffer
+	encoder := yaml.NewEncoder(&pretty)
+	encoder.SetIndent(2)
+	for _, document := range documents {
+		if err := encoder.Encode(document); err != nil {
+			return "", nil, err
+		}
+	}
+	if err := encoder.Close(); err != nil {
+		return "", nil, err
+	}
+	return pretty.String(), documents, nil
+}
+
+// dataTree converts parsed documents into the tree shown on the card. A
+// stream of several YAML documents becomes a list with one entry each.
+func dataTree(documents []*yaml.Node) templates.DataNode {
+	if len(documents) == 1 {
+		return dataNode("", documents[0])
+	}
+	root := templates.DataNode{Kind: templates.DataArray}
+	for i, document := range documents {
+		root.Children = append(root.Children, dataNode("document "+strconv.Itoa(i+1), document))
+	}
+	return root
+}
+
+func dataNode(key string, node *yaml.Node) templates.DataNode {
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		return dataNode(key, node.Content[0])
+	}
+
+	converted := templates.DataNode{Key: key}
+	switch node.Kind {
+	case yaml.MappingNode:
+		converted.Kind = templates.DataObject
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			converted.Children = append(converted.Children, dataNode(node.Content[i].Value, node.Content[i+1]))
+		}
+	case yaml.SequenceNode:
+		converted.Kind = templates.DataArray
+		for i, item := range node.Content {
+			converted.Children = append(converted.Children, dataNode(strconv.Itoa(i), item))
+		}
+	case yaml.AliasNode:
+		// Aliases are shown by name rather than expanded, so documents that
+		// nest aliases cannot blow up the tree.
+		converted.Kind = templates.DataAlias
+		converted.Value = "*" + node.Value
+	default:
+		converted.Kind, converted.Value = scalarKind(node)
+	}
+	return converted
+}
+
+func scalarKind(node *yaml.Node) (string, string) {
+	switch node.ShortTag() {
+	case "!!int", "!!float":
+		return templates.DataNumber, node.Value
+	case "!!bool":
+		return templates.DataBool, node.Value
+	case "!!null":
+		return templates.DataNull, "null"
+	default:
+		return templates.DataString, strconv.Quote(node.Value)
+	}
+}
+
+// hasNesting reports whether the tree holds an object or array inside
+// another; flat documents read well enough as source.
+func hasNesting(node templates.DataNode) bool {
+	for _, child := range node.Children {
+		if child.Kind == templates.DataObject || child.Kind == templates.DataArray {
+			return true
+		}
+	}
+	return false
+}
+
+func countNodes(node templates.DataNode) int {
+	count := 1
+	for _, child := range node.Children {
+		count += countNodes(child)
+	}
+	return count
+}
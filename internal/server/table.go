@@ -0,0 +1,235 @@
+package server
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"mime"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/laetho/slingboard/internal/slingmessage"
+	"github.com/laetho/slingboard/templates"
+)
+
+const (
+	csvMimeType  = "text/csv"
+	tsvMimeType  = "text/tab-separated-values"
+	maxTableRows = 200
+)
+
+var (
+	errUnknownSortColumn = errors.New("unknown sort column")
+	errSortNotTable      = errors.New("sorting only applies to CSV and TSV slings")
+)
+
+// tableSeparator returns the field separator of a CSV or TSV sling, and false
+// for slings that are not tables.
+func tableSeparator(sling *slingmessage.SlingMessage) (rune, bool) {
+	mediaType, _, _ := mime.ParseMediaType(sling.MimeType)
+	switch {
+	case mediaType == csvMimeType:
+		return ',', true
+	case mediaType == tsvMimeType:
+		return '\t', true
+	}
+	switch strings.ToLower(filepath.Ext(sling.Filename)) {
+	case ".csv":
+		return ',', true
+	case ".tsv", ".tab":
+		return '\t', true
+	}
+	return 0, false
+}
+
+// tableData is a parsed CSV or TSV sling.
+type tableData struct {
+	header  []string
+	rows    [][]string
+	numeric []bool
+}
+
+// parseTable reads the records of a CSV or TSV sling. Short rows are padded
+// so every row has a cell per column.
+func parseTable(content []byte, separator rune) (tableData, error) {
+	reader := csv.NewReader(bytes.NewReader(content))
+	reader.Comma = separator
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return tableData{}, err
+	}
+
+	columns := 0
+	for _, record := range records {
+		columns = max(columns, len(record))
+	}
+	for i, record := range records {
+		for len(record) < columns {
+			record = append(record, "")
+		}
+		records[i] = record
+	}
+
+	var table tableData
+	if len(records) > 1 && looksLikeHeader(records[0], records[1:]) {
+		table.header = records[0]
+		records = records[1:]
+	}
+	table.rows = records
+	table.numeric = make([]bool, columns)
+	for column := range columns {
+		table.numeric[column] = isNumericColumn(records, column)
+	}
+	return table, nil
+}
+
+// looksLikeHeader guesses whether the first record names the columns: its
+// cells are distinct, non-empty text, and either a column below holds numbers
+// or none of the names shows up again in its column.
+func looksLikeHeader(first []string, rows [][]string) bool {
+	seen := make(map[string]struct{}, len(first))
+	for _, cell := range first {
+		cell = strings.TrimSpace(cell)
+		if cell == "" || isNumber(cell) {
+			return false
+		}
+		if _, ok := seen[cell]; ok {
+			return false
+		}
+		seen[cell] = struct{}{}
+	}
+
+	for column := range first {
+		if isNumericColumn(rows, column) {
+			return true
+		}
+	}
+	for _, row := range rows {
+		for column, cell := range row {
+			if strings.TrimSpace(cell) == strings.TrimSpace(first[column]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// isNumericColumn reports whether every non-empty cell of the column is a
+// number.
+func isNumericColumn(rows [][]string, column int) bool {
+	found := false
+	for _, row := range rows {
+		cell := strings.TrimSpace(row[column])
+		if cell == "" {
+			continue
+		}
+		if !isNumber(cell) {
+			return false
+		}
+		found = true
+	}
+	return found
+}
+
+func isNumber(cell string) bool {
+	_, ok := parseNumber(cell)
+	return ok
+}
+
+// parseNumber reads numbers the way they show up in exported sheets, with
+// thousands separators or a trailing percent sign.
+func parseNumber(cell string) (float64, bool) {
+	cell = strings.TrimSpace(cell)
+	cell = strings.TrimSuffix(cell, "%")
+	cell = strings.NewReplacer(",", "", "_", "", " ", "").Replace(cell)
+	value, err := strconv.ParseFloat(cell, 64)
+	return value, err == nil
+}
+
+// sortColumn resolves a sort option, a column name or 1-based column number
+// with an optional leading "-" for descending order.
+func (t tableData) sortColumn(sortBy string) (int, bool, error) {
+	sortBy = strings.TrimSpace(sortBy)
+	descending := strings.HasPrefix(sortBy, "-")
+	sortBy = strings.TrimSpace(strings.TrimPrefix(sortBy, "-"))
+
+	for column, name := range t.header {
+		if strings.EqualFold(strings.TrimSpace(name), sortBy) {
+			return column, descending, nil
+		}
+	}
+	if number, err := strconv.Atoi(sortBy); err == nil && number >= 1 && number <= len(t.numeric) {
+		return number - 1, descending, nil
+	}
+	return 0, false, errUnknownSortColumn
+}
+
+// sort orders the rows by a column, comparing numbers as numbers.
+func (t tableData) sort(column int, descending bool) {
+	slices.SortStableFunc(t.rows, func(a, b []string) int {
+		var order int
+		if t.numeric[column] {
+			left, _ := parseNumber(a[column])
+			right, _ := parseNumber(b[column])
+			order = cmp.Compare(left, right)
+		} else {
+			order = strings.Compare(strings.ToLower(a[column]), strings.ToLower(b[column]))
+		}
+		if descending {
+			return -order
+		}
+		return order
+	})
+}
+
+// validateTableSort checks that a sort option names a column of the table
+// being slung, so a typo is reported to the sender instead of being ignored
+// when the card is rendered.
+func validateTableSort(sling *slingmessage.SlingMessage, filename string, sortBy string) error {
+	separator, ok := tableSeparator(&slingmessage.SlingMessage{MimeType: sling.MimeType, Filename: filename})
+	if !ok {
+		return errSortNotTable
+	}
+	table, err := parseTable(sling.Content, separator)
+	if err != nil {
+		return fmt.Errorf("failed to parse table: %w", err)
+	}
+	_, _, err = table.sortColumn(sortBy)
+	return err
+}
+
+// tableView prepares a table sling for its card, sorted as requested and
+// cut to the first maxTableRows rows.
+func tableView(sling *slingmessage.SlingMessage, separator rune) (templates.Table, error) {
+	table, err := parseTable(sling.Content, separator)
+	if err != nil {
+		return templates.Table{}, err
+	}
+
+	view := templates.Table{
+		Title:      sling.Filename,
+		Header:     table.header,
+		Numeric:    table.numeric,
+		SortColumn: -1,
+	}
+	if sling.Table != nil && sling.Table.SortBy != "" {
+		if column, descending, err := table.sortColumn(sling.Table.SortBy); err == nil {
+			table.sort(column, descending)
+			view.SortColumn = column
+			view.SortDescending = descending
+		}
+	}
+	view.Rows = table.rows
+	if len(view.Rows) > maxTableRows {
+		view.More = len(view.Rows) - maxTableRows
+		view.Rows = view.Rows[:maxTableRows]
+	}
+	return view, nil
+}
@@ -0,0 +1,443 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/laetho/slingboard/internal/commands"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nuid"
+)
+
+const (
+	filenameHeader = "X-Filename"
+
+	uploadBucket        = "sb_uploads"
+	uploadStream        = "SLINGBOARD_UPLOADS"
+	uploadSubjectPrefix = "slingboard_uploads."
+
+	// uploadTTL is how long an unfinished upload can be resumed before its
+	// state and chunks are dropped.
+	uploadTTL = 24 * time.Hour
+
+	// uploadCommitTimeout is how long a commit may hold its mark before
+	// another commit takes it over, for marks left by a commit that never
+	// finished on an instance that is still alive.
+	uploadCommitTimeout = 10 * time.Minute
+)
+
+var errUploadNotFound = errors.New("upload not found or expired")
+
+// handleUpload serves POST /api/upload, which slings a file sent as the raw
+// request body, named by the X-Filename header, or as the file field of a
+// multipart form. The board and file options come from the query string or
+// the other form fields, named like the fields of a file command.
+func (s *service) handleUpload(msg *nats.Msg) {
+	values, err := url.ParseQuery(msg.Header.Get(originalQueryHeader))
+	if err != nil {
+		s.respondCommandError(msg, http.StatusBadRequest, "invalid query")
+		return
+	}
+
+	var payload []byte
+	var filename, mimeType string
+	mediaType, params, _ := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if mediaType == "multipart/form-data" {
+		reader := multipart.NewReader(bytes.NewReader(msg.Data), params["boundary"])
+		for {
+			part, err := reader.NextPart()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				s.respondCommandError(msg, http.StatusBadRequest, "invalid multipart body")
+				return
+			}
+			data, err := io.ReadAll(part)
+			if err != nil {
+				s.respondCommandError(msg, http.StatusBadRequest, "invalid multipart body")
+				return
+			}
+			if part.FormName() == "file" && payload == nil {
+				payload, filename, mimeType = data, part.FileName(), part.Header.Get("Content-Type")
+				continue
+			}
+			values.Set(part.FormName(), string(data))
+		}
+	} else {
+		payload, mimeType = msg.Data, msg.Header.Get("Content-Type")
+		filename, _ = url.PathUnescape(msg.Header.Get(filenameHeader))
+	}
+	if len(payload) == 0 {
+		s.respondCommandError(msg, http.StatusBadRequest, "file content is required")
+		return
+	}
+
+	request, err := uploadRequest(values)
+	if err != nil {
+		s.respondCommandError(msg, http.StatusBadRequest, err.Error())
+		return
+	}
+	request.Filename = filename
+	// curl posts raw bodies as form data and browsers send files of unknown
+	// type as application/octet-stream, so neither names the file type.
+	switch mediaType, _, _ := mime.ParseMediaType(mimeType); mediaType {
+	case "", "application/x-www-form-urlencoded", defaultObjectMime:
+		mimeType = detectMimeType(filename, payload)
+	}
+
+	s.createSling(msg, request, mimeType, payload, nil)
+}
+
+// uploadRequest builds the file command of an upload from its query string
+// or form fields.
+func uploadRequest(values url.Values) (commands.CommandRequest, error) {
+	request := commands.CommandRequest{
+		Type:      commands.CommandFile,
+		Board:     values.Get("board"),
+		Author:    values.Get("author"),
+		TTL:       values.Get("ttl"),
+		ParentID:  values.Get("parent_id"),
+		Language:  values.Get("language"),
+		Lines:     values.Get("lines"),
+		Highlight: values.Get("highlight"),
+		Title:     values.Get("title"),
+		SortBy:    values.Get("sort_by"),
+	}
+	for name, flag := range map[string]*bool{"autoplay": &request.Autoplay, "muted": &request.Muted, "loop": &request.Loop} {
+		value := values.Get(name)
+		if value == "" {
+			continue
+		}
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return request, fmt.Errorf("%s must be true or false", name)
+		}
+		*flag = enabled
+	}
+	return request, nil
+}
+
+// upload is the state of a chunked upload, stored in the upload bucket under
+// its ID. Chunks are kept on the upload stream until the upload is committed
+// and Chunks lists their stream sequences in file order. CommitInstance
+// and CommitStarted mark a commit slinging the file, so concurrent commits
+// sling it once.
+type upload struct {
+	Request        commands.CommandRequest `json:"request"`
+	Size           int64                   `json:"size"`
+	Received       int64                   `json:"received"`
+	Chunks         []uint64                `json:"chunks,omitempty"`
+	CommitInstance string                  `json:"commit_instance,omitempty"`
+	CommitStarted  time.Time               `json:"commit_started,omitzero"`
+}
+
+// committing reports whether a commit of the upload is under way. Marks of
+// commits that ran longer than uploadCommitTimeout, or on an instance that
+// has since lost its lease, are left by commits that never finished.
+func (s *service) committing(state upload) bool {
+	if state.CommitInstance == "" || time.Since(state.CommitStarted) > uploadCommitTimeout {
+		return false
+	}
+	return s.instanceAlive(state.CommitInstance)
+}
+
+// uploadBody is the file body of a committed upload, streamed from its
+// chunks into the object store.
+type uploadBody struct {
+	reader io.Reader
+	size   int64
+}
+
+// uploadState returns the upload bucket. When create is set it also makes
+// sure the bucket and the chunk stream exist, updating a chunk stream set up
+// by an earlier version.
+func (s *service) uploadState(create bool) (nats.KeyValue, error) {
+	kv, err := s.js.KeyValue(uploadBucket)
+	if !create || (err != nil && !errors.Is(err, nats.ErrBucketNotFound)) {
+		return kv, err
+	}
+
+	config := &nats.StreamConfig{
+		Name:     uploadStream,
+		Subjects: []string{uploadSubjectPrefix + ">"},
+		Storage:  nats.FileStorage,
+		MaxAge:   uploadTTL,
+	}
+	if _, err := s.js.AddStream(config); errors.Is(err, nats.ErrStreamNameAlreadyInUse) {
+		if _, err := s.js.UpdateStream(config); err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	if kv != nil {
+		return kv, nil
+	}
+	return s.js.CreateKeyValue(&nats.KeyValueConfig{
+		Bucket:  uploadBucket,
+		Storage: nats.FileStorage,
+		TTL:     uploadTTL,
+	})
+}
+
+func loadUpload(kv nats.KeyValue, id string) (upload, uint64, error) {
+	entry, err := kv.Get(id)
+	if err != nil {
+		if errors.Is(err, nats.ErrKeyNotFound) {
+			return upload{}, 0, errUploadNotFound
+		}
+		return upload{}, 0, err
+	}
+
+	var state upload
+	if err := json.Unmarshal(entry.Value(), &state); err != nil {
+		return upload{}, 0, err
+	}
+	return state, entry.Revision(), nil
+}
+
+// handleUploadStart begins a chunked upload of a file. The request carries
+// everything a file command does except the content, plus the file size.
+func (s *service) handleUploadStart(msg *nats.Msg, request commands.CommandRequest) {
+	if request.Size <= 0 {
+		s.respondCommandError(msg, http.StatusBadRequest, "upload size is required")
+		return
+	}
+	if _, err := parseTTL(request.TTL); err != nil {
+		s.respondCommandError(msg, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	kv, err := s.uploadState(true)
+	if err != nil {
+		s.respondCommandError(msg, http.StatusInternalServerError, "failed to open upload store")
+		return
+	}
+
+	request.Type = commands.CommandFile
+	request.Content = ""
+	state, err := json.Marshal(upload{Request: request, Size: request.Size})
+	if err != nil {
+		s.respondCommandError(msg, http.StatusInternalServerError, "failed to encode upload")
+		return
+	}
+	id := nuid.Next()
+	if _, err := kv.Create(id, state); err != nil {
+		s.respondCommandError(msg, http.StatusInternalServerError, "failed to start upload")
+		return
+	}
+
+	s.respondJSON(msg, http.StatusOK, commands.CommandResponse{
+		ID:      id,
+		Status:  "ok",
+		Message: "upload started",
+		Board:   request.Board,
+	})
+}
+
+// handleUploadAppend stores the chunk of an upload at request.Offset. A
+// chunk at any other offset than the bytes received so far is refused with
+// 409 Conflict and the offset to resume from.
+func (s *service) handleUploadAppend(msg *nats.Msg, request commands.CommandRequest) {
+	id := strings.TrimSpace(request.ID)
+	chunk, err := base64.StdEncoding.DecodeString(request.Content)
+	if err != nil || len(chunk) == 0 {
+		s.respondCommandError(msg, http.StatusBadRequest, "invalid chunk content")
+		return
+	}
+
+	kv, err := s.uploadState(false)
+	if err != nil {
+		s.respondUploadError(msg, err)
+		return
+	}
+	state, revision, err := loadUpload(kv, id)
+	if err != nil {
+		s.respondUploadError(msg, err)
+		return
+	}
+	if request.Offset != state.Received {
+		s.respondUploadConflict(msg, id, state.Received)
+		return
+	}
+	if state.Received+int64(len(chunk)) > state.Size {
+		s.respondCommandError(msg, http.StatusBadRequest, "chunk runs past the announced upload size")
+		return
+	}
+
+	ack, err := s.js.Publish(uploadSubjectPrefix+id, chunk)
+	if err != nil {
+		s.respondCommandError(msg, http.StatusBadGateway, "failed to store chunk")
+		return
+	}
+	state.Received += int64(len(chunk))
+	state.Chunks = append(state.Chunks, ack.Sequence)
+	data, err := json.Marshal(state)
+	if err != nil {
+		s.respondCommandError(msg, http.StatusInternalServerError, "failed to encode upload")
+		return
+	}
+	// Another append at the same offset got there first; its chunk counts
+	// and the client resumes after it.
+	if _, err := kv.Update(id, data, revision); err != nil {
+		if current, _, err := loadUpload(kv, id); err == nil {
+			s.respondUploadConflict(msg, id, current.Received)
+			return
+		}
+		s.respondCommandError(msg, http.StatusInternalServerError, "failed to update upload")
+		return
+	}
+
+	s.respondJSON(msg, http.StatusOK, commands.CommandResponse{
+		ID:      id,
+		Status:  "ok",
+		Message: "chunk stored",
+		Offset:  state.Received,
+	})
+}
+
+// handleUploadCommit slings a completely received upload and drops its
+// chunks. Bodies too large to render are streamed into the object store
+// chunk by chunk; smaller ones, and files sent with a line range or sort
+// column, are read into memory like a file command.
+func (s *service) handleUploadCommit(msg *nats.Msg, request commands.CommandRequest) {
+	id := strings.TrimSpace(request.ID)
+	kv, err := s.uploadState(false)
+	if err != nil {
+		s.respondUploadError(msg, err)
+		return
+	}
+	state, revision, err := loadUpload(kv, id)
+	if err != nil {
+		s.respondUploadError(msg, err)
+		return
+	}
+	if state.Received != state.Size {
+		s.respondCommandError(msg, http.StatusBadRequest, fmt.Sprintf("upload has %d of %d bytes", state.Received, state.Size))
+		return
+	}
+	if s.committing(state) {
+		s.respondCommandError(msg, http.StatusConflict, "upload is already being committed")
+		return
+	}
+
+	// Only the commit that marks the upload on the revision it read slings
+	// the file. A failed commit clears the mark so the client can retry, and
+	// the mark of a commit that crashed or hung is taken over.
+	state.CommitInstance = s.id
+	state.CommitStarted = time.Now().UTC()
+	marked, err := json.Marshal(state)
+	if err != nil {
+		s.respondCommandError(msg, http.StatusInternalServerError, "failed to encode upload")
+		return
+	}
+	markRevision, err := kv.Update(id, marked, revision)
+	if err != nil {
+		s.respondCommandError(msg, http.StatusConflict, "upload is already being committed")
+		return
+	}
+	state.CommitInstance = ""
+	state.CommitStarted = time.Time{}
+	committed := false
+	defer func() {
+		if committed {
+			return
+		}
+		if unmarked, err := json.Marshal(state); err == nil {
+			if _, err := kv.Update(id, unmarked, markRevision); err != nil {
+				log.Printf("Failed to reopen upload %s: %v", id, err)
+			}
+		}
+	}()
+
+	reader := bufio.NewReader(&chunkReader{js: s.js, chunks: state.Chunks})
+	mimeType := state.Request.MimeType
+	if mimeType == "" {
+		head, _ := reader.Peek(512)
+		mimeType = detectMimeType(state.Request.Filename, head)
+	}
+
+	var payload []byte
+	var body *uploadBody
+	if state.Size <= maxRenderedObject || needsFileContent(state.Request) {
+		if payload, err = io.ReadAll(reader); err != nil {
+			s.respondCommandError(msg, http.StatusInternalServerError, "failed to read upload")
+			return
+		}
+	} else {
+		body = &uploadBody{reader: reader, size: state.Size}
+	}
+	if !s.createSling(msg, state.Request, mimeType, payload, body) {
+		return
+	}
+	committed = true
+
+	if err := s.js.PurgeStream(uploadStream, &nats.StreamPurgeRequest{Subject: uploadSubjectPrefix + id}); err != nil {
+		log.Printf("Failed to purge chunks of upload %s: %v", id, err)
+	}
+	if err := kv.Delete(id); err != nil {
+		log.Printf("Failed to delete upload %s: %v", id, err)
+	}
+}
+
+// needsFileContent reports whether a file command has options that read its
+// content when the sling is created.
+func needsFileContent(request commands.CommandRequest) bool {
+	return request.Lines != "" || request.Highlight != "" || strings.TrimSpace(request.Title) != "" || strings.TrimSpace(request.SortBy) != ""
+}
+
+func (s *service) respondUploadConflict(msg *nats.Msg, id string, offset int64) {
+	s.respondJSON(msg, http.StatusConflict, commands.CommandResponse{
+		ID:      id,
+		Status:  "error",
+		Message: fmt.Sprintf("upload continues at offset %d", offset),
+		Offset:  offset,
+	})
+}
+
+func (s *service) respondUploadError(msg *nats.Msg, err error) {
+	if errors.Is(err, errUploadNotFound) || errors.Is(err, nats.ErrBucketNotFound) {
+		s.respondCommandError(msg, http.StatusNotFound, errUploadNotFound.Error())
+		return
+	}
+	s.respondCommandError(msg, http.StatusInternalServerError, "failed to load upload")
+}
+
+// chunkReader reads the chunks of an upload from the upload stream in order,
+// holding one chunk in memory at a time.
+type chunkReader struct {
+	js     nats.JetStreamContext
+	chunks []uint64
+	data   []byte
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for len(r.data) == 0 {
+		if len(r.chunks) == 0 {
+			return 0, io.EOF
+		}
+		msg, err := r.js.GetMsg(uploadStream, r.chunks[0])
+		if err != nil {
+			return 0, err
+		}
+		r.data = msg.Data
+		r.chunks = r.chunks[1:]
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
@@ -8,6 +8,7 @@ import (
 	"io"
 	"mime"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
@@ -16,14 +17,93 @@ import (
 	"github.com/laetho/slingboard/internal/commands"
 )
 
+const (
+	// uploadChunkSize is the size of the chunks files larger than it are
+	// uploaded in. Base64 encoded in a command, a chunk stays well below the
+	// default NATS max payload of 1MB.
+	uploadChunkSize = 512 << 10
+
+	// uploadRetries is how often a chunk is sent again after a failed
+	// request before the upload gives up.
+	uploadRetries = 3
+)
+
 type Client struct {
 	baseURL    string
 	httpClient *http.Client
+	progress   ProgressFunc
 }
 
+// ProgressFunc is told how many bytes of a file have been uploaded.
+type ProgressFunc func(sent int64, total int64)
+
 type BoardList struct {
-	Status string   `json:"status"`
-	Boards []string `json:"boards"`
+	Status   string                   `json:"status"`
+	Boards   []string                 `json:"boards"`
+	Metadata []commands.BoardMetadata `json:"board_metadata,omitempty"`
+}
+
+// SendOption adjusts the command sent for a new sling.
+type SendOption func(*commands.CommandRequest)
+
+// WithTTL removes the sling from the board once ttl has passed.
+func WithTTL(ttl time.Duration) SendOption {
+	return func(request *commands.CommandRequest) {
+		if ttl > 0 {
+			request.TTL = ttl.String()
+		}
+	}
+}
+
+// WithReplyTo posts the sling as a reply in the thread of the sling with the
+// given ID.
+func WithReplyTo(id string) SendOption {
+	return func(request *commands.CommandRequest) {
+		request.ParentID = id
+	}
+}
+
+// WithLanguage highlights the sling as source code in the given language
+// instead of detecting it from the filename and content.
+func WithLanguage(language string) SendOption {
+	return func(request *commands.CommandRequest) {
+		request.Language = language
+	}
+}
+
+// WithExcerpt shows only the given line range of a text file, such as
+// "40-80", and highlights the lines in highlight, such as "57" or "60-62".
+// Either may be empty.
+func WithExcerpt(lines string, highlight string) SendOption {
+	return func(request *commands.CommandRequest) {
+		request.Lines = lines
+		request.Highlight = highlight
+	}
+}
+
+// WithTitle sets the header shown above a code sling instead of its filename.
+func WithTitle(title string) SendOption {
+	return func(request *commands.CommandRequest) {
+		request.Title = title
+	}
+}
+
+// WithPlayback sets the player options of a video or audio sling. Browsers
+// only autoplay muted media.
+func WithPlayback(autoplay bool, muted bool, loop bool) SendOption {
+	return func(request *commands.CommandRequest) {
+		request.Autoplay = autoplay
+		request.Muted = muted
+		request.Loop = loop
+	}
+}
+
+// WithSortBy sorts a CSV or TSV sling by a column, given by header name or
+// 1-based number. A leading "-" sorts in descending order.
+func WithSortBy(column string) SendOption {
+	return func(request *commands.CommandRequest) {
+		request.SortBy = column
+	}
 }
 
 func NewClient(baseURL string) *Client {
@@ -38,69 +118,312 @@ func NewClient(baseURL string) *Client {
 	}
 }
 
-func (c *Client) SendText(board string, message string) error {
-	return c.sendCommand(commands.CommandRequest{
+// OnProgress reports the progress of files large enough to be uploaded in
+// chunks to fn.
+func (c *Client) OnProgress(fn ProgressFunc) {
+	c.progress = fn
+}
+
+// SendText slings a message. The response carries the ID of the new sling,
+// which the delete, update, pin, react and reply commands take.
+func (c *Client) SendText(board string, message string, opts ...SendOption) (commands.CommandResponse, error) {
+	return c.sendCommandResponse(applySendOptions(commands.CommandRequest{
 		Type:    commands.CommandText,
 		Board:   board,
 		Content: message,
-	})
+	}, opts))
 }
 
-func (c *Client) SendURL(board string, url string) error {
-	return c.sendCommand(commands.CommandRequest{
+func (c *Client) SendURL(board string, url string, opts ...SendOption) (commands.CommandResponse, error) {
+	return c.sendCommandResponse(applySendOptions(commands.CommandRequest{
 		Type:    commands.CommandURL,
 		Board:   board,
 		Content: url,
-	})
+	}, opts))
 }
 
-func (c *Client) SendFile(board string, file string) error {
-	data, err := os.ReadFile(file)
+// SendFile slings a file. Files up to one chunk are posted as is to the
+// upload endpoint. Larger files are uploaded in chunks, so they are not bound
+// by the NATS max payload, and an interrupted chunk is sent again from where
+// the server left off.
+func (c *Client) SendFile(board string, file string, opts ...SendOption) (commands.CommandResponse, error) {
+	f, err := os.Open(file)
 	if err != nil {
-		return fmt.Errorf("failed to read file: %w", err)
+		return commands.CommandResponse{}, fmt.Errorf("failed to read file: %w", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return commands.CommandResponse{}, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	if info.Size() > uploadChunkSize {
+		head := make([]byte, 512)
+		n, err := f.ReadAt(head, 0)
+		if err != nil && err != io.EOF {
+			return commands.CommandResponse{}, fmt.Errorf("failed to read file: %w", err)
+		}
+		return c.sendFileChunked(f, info.Size(), applySendOptions(commands.CommandRequest{
+			Type:     commands.CommandUploadStart,
+			Board:    board,
+			MimeType: detectMimeType(file, head[:n]),
+			Filename: filepath.Base(file),
+			Size:     info.Size(),
+		}, opts))
 	}
 
-	mimeType := detectMimeType(file, data)
-	encoded := base64.StdEncoding.EncodeToString(data)
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return commands.CommandResponse{}, fmt.Errorf("failed to read file: %w", err)
+	}
 
-	return c.sendCommand(commands.CommandRequest{
+	return c.uploadFile(data, applySendOptions(commands.CommandRequest{
 		Type:     commands.CommandFile,
 		Board:    board,
-		Content:  encoded,
-		MimeType: mimeType,
+		MimeType: detectMimeType(file, data),
 		Filename: filepath.Base(file),
+	}, opts))
+}
+
+// uploadFile posts a file as the raw body of the upload endpoint, with the
+// file options of the command in the query string.
+func (c *Client) uploadFile(data []byte, command commands.CommandRequest) (commands.CommandResponse, error) {
+	request, err := http.NewRequest(http.MethodPost, c.baseURL+"/api/upload?"+uploadQuery(command).Encode(), bytes.NewReader(data))
+	if err != nil {
+		return commands.CommandResponse{}, fmt.Errorf("failed to build request: %w", err)
+	}
+	request.Header.Set("Content-Type", command.MimeType)
+	request.Header.Set("X-Filename", url.PathEscape(command.Filename))
+	request.Header.Set("Accept", "application/json")
+
+	status, body, err := c.do(request)
+	if err != nil {
+		return commands.CommandResponse{}, err
+	}
+	return decodeCommandResponse(status, body)
+}
+
+// uploadQuery returns the options of a file command as upload query
+// parameters.
+func uploadQuery(command commands.CommandRequest) url.Values {
+	values := url.Values{}
+	for name, value := range map[string]string{
+		"board":     command.Board,
+		"author":    command.Author,
+		"ttl":       command.TTL,
+		"parent_id": command.ParentID,
+		"language":  command.Language,
+		"lines":     command.Lines,
+		"highlight": command.Highlight,
+		"title":     command.Title,
+		"sort_by":   command.SortBy,
+	} {
+		if value != "" {
+			values.Set(name, value)
+		}
+	}
+	for name, flag := range map[string]bool{"autoplay": command.Autoplay, "muted": command.Muted, "loop": command.Loop} {
+		if flag {
+			values.Set(name, "true")
+		}
+	}
+	return values
+}
+
+// sendFileChunked uploads a file with the upload commands: start, a chunk
+// at a time from the offset the server reports, then commit, which answers
+// with the new sling.
+func (c *Client) sendFileChunked(f io.ReaderAt, size int64, start commands.CommandRequest) (commands.CommandResponse, error) {
+	started, err := c.sendCommandResponse(start)
+	if err != nil {
+		return commands.CommandResponse{}, err
+	}
+
+	chunk := make([]byte, uploadChunkSize)
+	for offset := int64(0); offset < size; {
+		n, err := f.ReadAt(chunk, offset)
+		if n == 0 && err != nil {
+			return commands.CommandResponse{}, fmt.Errorf("failed to read file: %w", err)
+		}
+		offset, err = c.appendChunk(started.ID, offset, chunk[:n])
+		if err != nil {
+			return commands.CommandResponse{}, err
+		}
+		if c.progress != nil {
+			c.progress(offset, size)
+		}
+	}
+
+	return c.sendCommandResponse(commands.CommandRequest{
+		Type: commands.CommandUploadCommit,
+		ID:   started.ID,
 	})
 }
 
-func (c *Client) sendCommand(command commands.CommandRequest) error {
-	_, err := c.sendCommandResponse(command)
-	return err
+// appendChunk sends one chunk of an upload and returns the offset of the
+// next one. Requests that fail or meet a server error are retried; when the server holds a different
+// offset, such as after a retried chunk did arrive, the upload resumes there.
+func (c *Client) appendChunk(id string, offset int64, chunk []byte) (int64, error) {
+	request := commands.CommandRequest{
+		Type:    commands.CommandUploadAppend,
+		ID:      id,
+		Offset:  offset,
+		Content: base64.StdEncoding.EncodeToString(chunk),
+	}
+
+	var err error
+	for attempt := 0; attempt <= uploadRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * time.Second)
+		}
+		var status int
+		var body []byte
+		status, body, err = c.postCommand(request)
+		if err != nil {
+			continue
+		}
+		if status == http.StatusConflict {
+			var conflict commands.CommandResponse
+			if err := json.Unmarshal(body, &conflict); err != nil {
+				return 0, fmt.Errorf("failed to decode response: %w", err)
+			}
+			return conflict.Offset, nil
+		}
+		var response commands.CommandResponse
+		response, err = decodeCommandResponse(status, body)
+		if err != nil {
+			if status >= http.StatusInternalServerError {
+				continue
+			}
+			return 0, err
+		}
+		return response.Offset, nil
+	}
+	return 0, err
+}
+
+// BoardOption adjusts the command sent when creating a board.
+type BoardOption func(*commands.CommandRequest)
+
+// WithRetention overrides the server's default retention for a new board.
+func WithRetention(retention *commands.BoardRetention) BoardOption {
+	return func(request *commands.CommandRequest) {
+		request.Retention = retention
+	}
+}
+
+// WithMetadata sets the title, description, owner and tags of a new board.
+func WithMetadata(metadata *commands.BoardMetadata) BoardOption {
+	return func(request *commands.CommandRequest) {
+		request.Metadata = metadata
+	}
+}
+
+func applySendOptions(request commands.CommandRequest, opts []SendOption) commands.CommandRequest {
+	for _, opt := range opts {
+		opt(&request)
+	}
+	return request
+}
+
+func (c *Client) SlingDelete(board string, id string) (commands.CommandResponse, error) {
+	return c.sendCommandResponse(commands.CommandRequest{
+		Type:  commands.CommandSlingDelete,
+		Board: board,
+		ID:    id,
+	})
+}
+
+func (c *Client) SlingUpdate(board string, id string, content string) (commands.CommandResponse, error) {
+	return c.sendCommandResponse(commands.CommandRequest{
+		Type:    commands.CommandSlingUpdate,
+		Board:   board,
+		ID:      id,
+		Content: content,
+	})
+}
+
+func (c *Client) SlingPin(board string, id string) (commands.CommandResponse, error) {
+	return c.sendCommandResponse(commands.CommandRequest{
+		Type:  commands.CommandSlingPin,
+		Board: board,
+		ID:    id,
+	})
+}
+
+func (c *Client) SlingUnpin(board string, id string) (commands.CommandResponse, error) {
+	return c.sendCommandResponse(commands.CommandRequest{
+		Type:  commands.CommandSlingUnpin,
+		Board: board,
+		ID:    id,
+	})
+}
+
+// SlingReact adds an emoji reaction to a sling. An author reacts at most once
+// with each emoji and may take the reaction back with SlingUnreact.
+func (c *Client) SlingReact(board string, id string, reaction string, author string) (commands.CommandResponse, error) {
+	return c.sendCommandResponse(commands.CommandRequest{
+		Type:     commands.CommandSlingReact,
+		Board:    board,
+		Author:   author,
+		ID:       id,
+		Reaction: reaction,
+	})
+}
+
+// SlingUnreact removes the reaction an author added to a sling.
+func (c *Client) SlingUnreact(board string, id string, reaction string, author string) (commands.CommandResponse, error) {
+	return c.sendCommandResponse(commands.CommandRequest{
+		Type:     commands.CommandSlingUnreact,
+		Board:    board,
+		Author:   author,
+		ID:       id,
+		Reaction: reaction,
+	})
 }
 
 func (c *Client) sendCommandResponse(command commands.CommandRequest) (commands.CommandResponse, error) {
+	status, body, err := c.postCommand(command)
+	if err != nil {
+		return commands.CommandResponse{}, err
+	}
+	return decodeCommandResponse(status, body)
+}
+
+// postCommand posts a command to the API and returns the status and body of
+// the response.
+func (c *Client) postCommand(command commands.CommandRequest) (int, []byte, error) {
 	payload, err := json.Marshal(command)
 	if err != nil {
-		return commands.CommandResponse{}, fmt.Errorf("failed to marshal command: %w", err)
+		return 0, nil, fmt.Errorf("failed to marshal command: %w", err)
 	}
 
 	request, err := http.NewRequest(http.MethodPost, c.baseURL+"/api/commands", bytes.NewReader(payload))
 	if err != nil {
-		return commands.CommandResponse{}, fmt.Errorf("failed to build request: %w", err)
+		return 0, nil, fmt.Errorf("failed to build request: %w", err)
 	}
 	request.Header.Set("Content-Type", "application/json")
 	request.Header.Set("Accept", "application/json")
+	return c.do(request)
+}
 
+// do sends a request and returns the status and body of the response.
+func (c *Client) do(request *http.Request) (int, []byte, error) {
 	response, err := c.httpClient.Do(request)
 	if err != nil {
-		return commands.CommandResponse{}, fmt.Errorf("failed to send command: %w", err)
+		return 0, nil, fmt.Errorf("failed to send command: %w", err)
 	}
 	defer response.Body.Close()
 
 	body, err := io.ReadAll(response.Body)
 	if err != nil {
-		return commands.CommandResponse{}, fmt.Errorf("failed to read response: %w", err)
+		return 0, nil, fmt.Errorf("failed to read response: %w", err)
 	}
-	if response.StatusCode < http.StatusOK || response.StatusCode >= http.StatusMultipleChoices {
+	return response.StatusCode, body, nil
+}
+
+func decodeCommandResponse(status int, body []byte) (commands.CommandResponse, error) {
+	if status < http.StatusOK || status >= http.StatusMultipleChoices {
 		return commands.CommandResponse{}, fmt.Errorf("command failed: %s", strings.TrimSpace(string(body)))
 	}
 
@@ -126,11 +449,43 @@ func (c *Client) BoardList() (BoardList, error) {
 		return BoardList{}, err
 	}
 
-	return BoardList{Status: response.Status, Boards: response.Boards}, nil
+	return BoardList{Status: response.Status, Boards: response.Boards, Metadata: response.BoardMetadata}, nil
+}
+
+func (c *Client) BoardCreate(board string, opts ...BoardOption) (commands.CommandResponse, error) {
+	request := commands.CommandRequest{Type: commands.CommandBoardCreate, Board: board}
+	for _, opt := range opts {
+		opt(&request)
+	}
+	return c.sendCommandResponse(request)
 }
 
-func (c *Client) BoardCreate(board string) (commands.CommandResponse, error) {
-	return c.sendCommandResponse(commands.CommandRequest{Type: commands.CommandBoardCreate, Board: board})
+// BoardConfig changes the retention of an existing board. A nil retention
+// returns the current settings.
+func (c *Client) BoardConfig(board string, retention *commands.BoardRetention) (commands.CommandResponse, error) {
+	return c.sendCommandResponse(commands.CommandRequest{
+		Type:      commands.CommandBoardConfig,
+		Board:     board,
+		Retention: retention,
+	})
+}
+
+// BoardDelete deletes a board and everything slung to it.
+func (c *Client) BoardDelete(board string) (commands.CommandResponse, error) {
+	return c.sendCommandResponse(commands.CommandRequest{
+		Type:  commands.CommandBoardDelete,
+		Board: board,
+	})
+}
+
+// BoardUpdate changes the metadata of an existing board. Empty fields keep
+// their stored value.
+func (c *Client) BoardUpdate(board string, metadata *commands.BoardMetadata) (commands.CommandResponse, error) {
+	return c.sendCommandResponse(commands.CommandRequest{
+		Type:     commands.CommandBoardUpdate,
+		Board:    board,
+		Metadata: metadata,
+	})
 }
 
 func detectMimeType(filename string, data []byte) string {
@@ -138,6 +493,9 @@ func detectMimeType(filename string, data []byte) string {
 	if ext == ".md" || ext == ".markdown" {
 		return "text/markdown"
 	}
+	if ext == ".yaml" || ext == ".yml" {
+		return "application/x-yaml"
+	}
 
 	peekSize := min(len(data), 512)
 	mimeType := http.DetectContentType(data[:peekSize])
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -94,7 +95,7 @@ func setupResponder(t *testing.T, nc *nats.Conn, handler func(req commands.Comma
 
 		handler(req)
 
-		resp, _ := json.Marshal(commands.CommandResponse{Status: "ok"})
+		resp, _ := json.Marshal(commands.CommandResponse{Status: "ok", ID: "42", Board: req.Board})
 		msg.RespondMsg(&nats.Msg{
 			Header: nats.Header{
 				"Status-Code":    []string{"200"},
@@ -113,6 +114,43 @@ func setupResponder(t *testing.T, nc *nats.Conn, handler func(req commands.Comma
 	}
 }
 
+// setupUploadResponder answers file uploads and hands each request message to
+// handler.
+func setupUploadResponder(t *testing.T, nc *nats.Conn, handler func(msg *nats.Msg)) {
+	t.Helper()
+
+	_, err := nc.Subscribe("h8s.http.POST.localhost.api.upload", func(msg *nats.Msg) {
+		handler(msg)
+
+		resp, _ := json.Marshal(commands.CommandResponse{Status: "ok"})
+		msg.RespondMsg(&nats.Msg{
+			Header: nats.Header{
+				"Status-Code":    []string{"200"},
+				"Content-Type":   []string{"application/json"},
+				"Content-Length": []string{strconv.Itoa(len(resp))},
+			},
+			Data: resp,
+		})
+	})
+	if err != nil {
+		t.Fatalf("failed to subscribe to upload subject: %v", err)
+	}
+	if err := nc.Flush(); err != nil {
+		t.Fatalf("failed to flush subscription: %v", err)
+	}
+}
+
+// uploadQueryOf returns the query string an upload was posted with.
+func uploadQueryOf(t *testing.T, msg *nats.Msg) url.Values {
+	t.Helper()
+
+	query, err := url.ParseQuery(msg.Header.Get("X-H8s-Original-Query"))
+	if err != nil {
+		t.Fatalf("invalid upload query: %v", err)
+	}
+	return query
+}
+
 func TestSendTextUsesH8SProxy(t *testing.T) {
 	harness := startHarness(t)
 
@@ -122,9 +160,13 @@ func TestSendTextUsesH8SProxy(t *testing.T) {
 	})
 
 	client := NewClient(harness.baseURL)
-	if err := client.SendText("testboard", "hello"); err != nil {
+	response, err := client.SendText("testboard", "hello")
+	if err != nil {
 		t.Fatalf("send text failed: %v", err)
 	}
+	if response.ID != "42" || response.Board != "testboard" {
+		t.Fatalf("expected the response to carry the new sling, got %+v", response)
+	}
 
 	if got.Type != commands.CommandText {
 		t.Fatalf("expected type text, got %q", got.Type)
@@ -149,7 +191,7 @@ func TestSendURLUsesH8SProxy(t *testing.T) {
 	})
 
 	client := NewClient(harness.baseURL)
-	if err := client.SendURL("testboard", "https://example.com"); err != nil {
+	if _, err := client.SendURL("testboard", "https://example.com"); err != nil {
 		t.Fatalf("send url failed: %v", err)
 	}
 
@@ -167,9 +209,9 @@ func TestSendURLUsesH8SProxy(t *testing.T) {
 func TestSendFileUsesH8SProxy(t *testing.T) {
 	harness := startHarness(t)
 
-	var got commands.CommandRequest
-	setupResponder(t, harness.natsConn, func(req commands.CommandRequest) {
-		got = req
+	var got *nats.Msg
+	setupUploadResponder(t, harness.natsConn, func(msg *nats.Msg) {
+		got = msg
 	})
 
 	tmpDir := t.TempDir()
@@ -179,29 +221,24 @@ func TestSendFileUsesH8SProxy(t *testing.T) {
 	}
 
 	client := NewClient(harness.baseURL)
-	if err := client.SendFile("testboard", filePath); err != nil {
+	if _, err := client.SendFile("testboard", filePath); err != nil {
 		t.Fatalf("send file failed: %v", err)
 	}
 
-	if got.Type != commands.CommandFile {
-		t.Fatalf("expected type file, got %q", got.Type)
-	}
-	if got.Board != "testboard" {
-		t.Fatalf("expected board testboard, got %q", got.Board)
+	if got == nil {
+		t.Fatal("expected the file to be posted to the upload endpoint")
 	}
-	if got.Filename != "note.txt" {
-		t.Fatalf("expected filename note.txt, got %q", got.Filename)
+	if board := uploadQueryOf(t, got).Get("board"); board != "testboard" {
+		t.Fatalf("expected board testboard, got %q", board)
 	}
-	if got.MimeType != "text/plain; charset=utf-8" {
-		t.Fatalf("expected mime type text/plain; charset=utf-8, got %q", got.MimeType)
+	if filename := got.Header.Get("X-Filename"); filename != "note.txt" {
+		t.Fatalf("expected filename note.txt, got %q", filename)
 	}
-
-	decoded, err := base64.StdEncoding.DecodeString(got.Content)
-	if err != nil {
-		t.Fatalf("failed to decode base64 content: %v", err)
+	if mimeType := got.Header.Get("Content-Type"); mimeType != "text/plain; charset=utf-8" {
+		t.Fatalf("expected mime type text/plain; charset=utf-8, got %q", mimeType)
 	}
-	if string(decoded) != "hello" {
-		t.Fatalf("expected decoded content hello, got %q", string(decoded))
+	if string(got.Data) != "hello" {
+		t.Fatalf("expected the raw file as body, got %q", string(got.Data))
 	}
 }
 
@@ -303,7 +340,361 @@ func TestCommandErrorResponse(t *testing.T) {
 	}
 
 	client := NewClient(harness.baseURL)
-	if err := client.SendText("testboard", "hello"); err == nil {
+	if _, err := client.SendText("testboard", "hello"); err == nil {
 		t.Fatal("expected error response")
 	}
 }
+
+func TestSlingDeleteUsesJSON(t *testing.T) {
+	harness := startHarness(t)
+
+	var got commands.CommandRequest
+	setupResponder(t, harness.natsConn, func(req commands.CommandRequest) {
+		got = req
+	})
+
+	client := NewClient(harness.baseURL)
+	if _, err := client.SlingDelete("testboard", "42"); err != nil {
+		t.Fatalf("sling delete failed: %v", err)
+	}
+
+	if got.Type != commands.CommandSlingDelete {
+		t.Fatalf("expected type sling.delete, got %q", got.Type)
+	}
+	if got.Board != "testboard" || got.ID != "42" {
+		t.Fatalf("expected sling 42 on testboard, got %+v", got)
+	}
+}
+
+func TestSlingUpdateUsesJSON(t *testing.T) {
+	harness := startHarness(t)
+
+	var got commands.CommandRequest
+	setupResponder(t, harness.natsConn, func(req commands.CommandRequest) {
+		got = req
+	})
+
+	client := NewClient(harness.baseURL)
+	if _, err := client.SlingUpdate("testboard", "42", "fixed"); err != nil {
+		t.Fatalf("sling update failed: %v", err)
+	}
+
+	if got.Type != commands.CommandSlingUpdate {
+		t.Fatalf("expected type sling.update, got %q", got.Type)
+	}
+	if got.ID != "42" || got.Content != "fixed" {
+		t.Fatalf("expected sling 42 with new content, got %+v", got)
+	}
+}
+
+func TestSlingPinUsesJSON(t *testing.T) {
+	harness := startHarness(t)
+
+	var got []commands.CommandRequest
+	setupResponder(t, harness.natsConn, func(req commands.CommandRequest) {
+		got = append(got, req)
+	})
+
+	client := NewClient(harness.baseURL)
+	if _, err := client.SlingPin("testboard", "42"); err != nil {
+		t.Fatalf("sling pin failed: %v", err)
+	}
+	if _, err := client.SlingUnpin("testboard", "42"); err != nil {
+		t.Fatalf("sling unpin failed: %v", err)
+	}
+
+	if len(got) != 2 || got[0].Type != commands.CommandSlingPin || got[1].Type != commands.CommandSlingUnpin {
+		t.Fatalf("expected pin then unpin, got %+v", got)
+	}
+}
+
+func TestSendTextWithTTL(t *testing.T) {
+	harness := startHarness(t)
+
+	var got commands.CommandRequest
+	setupResponder(t, harness.natsConn, func(req commands.CommandRequest) {
+		got = req
+	})
+
+	client := NewClient(harness.baseURL)
+	if _, err := client.SendText("testboard", "standup", WithTTL(15*time.Minute)); err != nil {
+		t.Fatalf("send text failed: %v", err)
+	}
+
+	if got.TTL != "15m0s" {
+		t.Fatalf("expected ttl 15m0s, got %q", got.TTL)
+	}
+}
+
+func TestBoardConfigSendsRetention(t *testing.T) {
+	harness := startHarness(t)
+
+	var got commands.CommandRequest
+	setupResponder(t, harness.natsConn, func(req commands.CommandRequest) {
+		got = req
+	})
+
+	client := NewClient(harness.baseURL)
+	retention := &commands.BoardRetention{Retention: "limits", MaxAge: "72h"}
+	if _, err := client.BoardConfig("alpha", retention); err != nil {
+		t.Fatalf("board config failed: %v", err)
+	}
+
+	if got.Type != commands.CommandBoardConfig || got.Board != "alpha" {
+		t.Fatalf("expected board.config for alpha, got %+v", got)
+	}
+	if got.Retention == nil || got.Retention.MaxAge != "72h" {
+		t.Fatalf("expected retention to be sent, got %+v", got.Retention)
+	}
+}
+
+func TestBoardDeleteUsesJSON(t *testing.T) {
+	harness := startHarness(t)
+
+	var got commands.CommandRequest
+	setupResponder(t, harness.natsConn, func(req commands.CommandRequest) {
+		got = req
+	})
+
+	client := NewClient(harness.baseURL)
+	if _, err := client.BoardDelete("alpha"); err != nil {
+		t.Fatalf("board delete failed: %v", err)
+	}
+
+	if got.Type != commands.CommandBoardDelete || got.Board != "alpha" {
+		t.Fatalf("expected board.delete for alpha, got %+v", got)
+	}
+}
+
+func TestBoardUpdateSendsMetadata(t *testing.T) {
+	harness := startHarness(t)
+
+	var got commands.CommandRequest
+	setupResponder(t, harness.natsConn, func(req commands.CommandRequest) {
+		got = req
+	})
+
+	client := NewClient(harness.baseURL)
+	metadata := &commands.BoardMetadata{Title: "Alpha team", Tags: []string{"ops"}}
+	if _, err := client.BoardUpdate("alpha", metadata); err != nil {
+		t.Fatalf("board update failed: %v", err)
+	}
+
+	if got.Type != commands.CommandBoardUpdate || got.Board != "alpha" {
+		t.Fatalf("expected board.update for alpha, got %+v", got)
+	}
+	if got.Metadata == nil || got.Metadata.Title != "Alpha team" || len(got.Metadata.Tags) != 1 {
+		t.Fatalf("expected metadata to be sent, got %+v", got.Metadata)
+	}
+}
+
+func TestSlingReactUsesJSON(t *testing.T) {
+	harness := startHarness(t)
+
+	var got commands.CommandRequest
+	setupResponder(t, harness.natsConn, func(req commands.CommandRequest) {
+		got = req
+	})
+
+	client := NewClient(harness.baseURL)
+	if _, err := client.SlingReact("alpha", "42", "👍", "ada"); err != nil {
+		t.Fatalf("sling react failed: %v", err)
+	}
+
+	if got.Type != commands.CommandSlingReact || got.ID != "42" || got.Reaction != "👍" || got.Author != "ada" {
+		t.Fatalf("expected sling.react for 42, got %+v", got)
+	}
+
+	if _, err := client.SlingUnreact("alpha", "42", "👍", "ada"); err != nil {
+		t.Fatalf("sling unreact failed: %v", err)
+	}
+	if got.Type != commands.CommandSlingUnreact || got.ID != "42" || got.Author != "ada" {
+		t.Fatalf("expected sling.unreact for 42, got %+v", got)
+	}
+}
+
+func TestSendTextWithReplyTo(t *testing.T) {
+	harness := startHarness(t)
+
+	var got commands.CommandRequest
+	setupResponder(t, harness.natsConn, func(req commands.CommandRequest) {
+		got = req
+	})
+
+	client := NewClient(harness.baseURL)
+	if _, err := client.SendText("testboard", "tacos", WithReplyTo("42")); err != nil {
+		t.Fatalf("send text failed: %v", err)
+	}
+
+	if got.Type != commands.CommandText || got.ParentID != "42" {
+		t.Fatalf("expected text reply to 42, got %+v", got)
+	}
+}
+
+func TestSendFileWithLanguage(t *testing.T) {
+	harness := startHarness(t)
+
+	var got url.Values
+	setupUploadResponder(t, harness.natsConn, func(msg *nats.Msg) {
+		got = uploadQueryOf(t, msg)
+	})
+
+	filePath := filepath.Join(t.TempDir(), "Jenkinsfile")
+	if err := os.WriteFile(filePath, []byte("pipeline {}"), 0o644); err != nil {
+		t.Fatalf("failed to write temp file: %v", err)
+	}
+
+	client := NewClient(harness.baseURL)
+	if _, err := client.SendFile("testboard", filePath, WithLanguage("groovy")); err != nil {
+		t.Fatalf("send file failed: %v", err)
+	}
+
+	if got.Get("language") != "groovy" {
+		t.Fatalf("expected language groovy, got %q", got.Get("language"))
+	}
+}
+
+func TestSendFileWithExcerpt(t *testing.T) {
+	harness := startHarness(t)
+
+	var got url.Values
+	setupUploadResponder(t, harness.natsConn, func(msg *nats.Msg) {
+		got = uploadQueryOf(t, msg)
+	})
+
+	filePath := filepath.Join(t.TempDir(), "handler.go")
+	if err := os.WriteFile(filePath, []byte("package main\n"), 0o644); err != nil {
+		t.Fatalf("failed to write temp file: %v", err)
+	}
+
+	client := NewClient(harness.baseURL)
+	if _, err := client.SendFile("testboard", filePath, WithExcerpt("40-80", "57"), WithTitle("handler")); err != nil {
+		t.Fatalf("send file failed: %v", err)
+	}
+
+	if got.Get("lines") != "40-80" || got.Get("highlight") != "57" || got.Get("title") != "handler" {
+		t.Fatalf("expected excerpt options, got %+v", got)
+	}
+}
+
+func TestSendFileWithSortBy(t *testing.T) {
+	harness := startHarness(t)
+
+	var got *nats.Msg
+	setupUploadResponder(t, harness.natsConn, func(msg *nats.Msg) {
+		got = msg
+	})
+
+	filePath := filepath.Join(t.TempDir(), "costs.csv")
+	if err := os.WriteFile(filePath, []byte("service,amount\napi,10\n"), 0o644); err != nil {
+		t.Fatalf("failed to write temp file: %v", err)
+	}
+
+	client := NewClient(harness.baseURL)
+	if _, err := client.SendFile("testboard", filePath, WithSortBy("-amount")); err != nil {
+		t.Fatalf("send file failed: %v", err)
+	}
+
+	if uploadQueryOf(t, got).Get("sort_by") != "-amount" || got.Header.Get("X-Filename") != "costs.csv" {
+		t.Fatalf("expected the sort option, got %+v", got.Header)
+	}
+}
+
+func TestSendFileUploadsLargeFilesInChunks(t *testing.T) {
+	harness := startHarness(t)
+
+	var (
+		start     commands.CommandRequest
+		received  []byte
+		appends   int
+		committed string
+	)
+	_, err := harness.natsConn.Subscribe("h8s.http.POST.localhost.api.commands", func(msg *nats.Msg) {
+		var req commands.CommandRequest
+		if err := json.Unmarshal(msg.Data, &req); err != nil {
+			return
+		}
+
+		status := http.StatusOK
+		response := commands.CommandResponse{Status: "ok", ID: "upload-1"}
+		switch req.Type {
+		case commands.CommandUploadStart:
+			start = req
+		case commands.CommandUploadAppend:
+			appends++
+			chunk, _ := base64.StdEncoding.DecodeString(req.Content)
+			if req.Offset != int64(len(received)) {
+				status = http.StatusConflict
+				response.Status = "error"
+			} else {
+				received = append(received, chunk...)
+			}
+			// The second chunk is stored but its response is lost, so the
+			// client sends it again and resumes from the conflict.
+			if appends == 2 {
+				status = http.StatusBadGateway
+				response.Status = "error"
+			}
+			response.Offset = int64(len(received))
+		case commands.CommandUploadCommit:
+			committed = req.ID
+		}
+
+		resp, _ := json.Marshal(response)
+		msg.RespondMsg(&nats.Msg{
+			Header: nats.Header{
+				"Status-Code":    []string{strconv.Itoa(status)},
+				"Content-Type":   []string{"application/json"},
+				"Content-Length": []string{strconv.Itoa(len(resp))},
+			},
+			Data: resp,
+		})
+	})
+	if err != nil {
+		t.Fatalf("failed to subscribe to command subject: %v", err)
+	}
+	if err := harness.natsConn.Flush(); err != nil {
+		t.Fatalf("failed to flush subscription: %v", err)
+	}
+
+	data := make([]byte, 2*uploadChunkSize+100)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	filePath := filepath.Join(t.TempDir(), "dump.bin")
+	if err := os.WriteFile(filePath, data, 0o644); err != nil {
+		t.Fatalf("failed to write temp file: %v", err)
+	}
+
+	var progress []int64
+	client := NewClient(harness.baseURL)
+	client.OnProgress(func(sent int64, total int64) {
+		if total != int64(len(data)) {
+			t.Errorf("expected total %d, got %d", len(data), total)
+		}
+		progress = append(progress, sent)
+	})
+	response, err := client.SendFile("testboard", filePath, WithTTL(time.Hour))
+	if err != nil {
+		t.Fatalf("send file failed: %v", err)
+	}
+	if response.ID != "upload-1" {
+		t.Fatalf("expected the commit response, got %+v", response)
+	}
+
+	if start.Size != int64(len(data)) || start.Filename != "dump.bin" || start.Board != "testboard" || start.TTL != "1h0m0s" || start.Content != "" {
+		t.Fatalf("expected the upload to start with the file metadata and options, got %+v", start)
+	}
+	if string(received) != string(data) {
+		t.Fatalf("expected the chunks to add up to the file, got %d of %d bytes", len(received), len(data))
+	}
+	if appends != 4 {
+		t.Fatalf("expected three chunks and one retry, got %d appends", appends)
+	}
+	if committed != "upload-1" {
+		t.Fatalf("expected the upload to be committed, got %q", committed)
+	}
+	if len(progress) != 3 || progress[len(progress)-1] != int64(len(data)) {
+		t.Fatalf("expected progress after every chunk, got %v", progress)
+	}
+}
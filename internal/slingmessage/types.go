@@ -3,9 +3,46 @@ package slingmessage
 import "time"
 
 type SlingMessage struct {
-	ID        string    `json:"id"`        // Unique message identifier
-	Sender    string    `json:"sender"`    // Identifier of the sender (e.g., username or ID)
-	Timestamp time.Time `json:"timestamp"` // Time the message was created
-	MimeType  string    `json:"mime_type"` // MIME type of the content (e.g., text/plain, image/png, application/pdf)
-	Content   []byte    `json:"content"`   // Arbitrary content (text, images, PDFs, etc.)
+	ID        string        `json:"id"`                  // Unique message identifier
+	Sender    string        `json:"sender"`              // Identifier of the sender (e.g., username or ID)
+	Timestamp time.Time     `json:"timestamp"`           // Time the message was created
+	MimeType  string        `json:"mime_type"`           // MIME type of the content (e.g., text/plain, image/png, application/pdf)
+	Content   []byte        `json:"content"`             // Arbitrary content (text, images, PDFs, etc.)
+	EditedAt  time.Time     `json:"edited_at,omitzero"`  // Time of the latest edit; set on revisions of an existing sling
+	ExpiresAt time.Time     `json:"expires_at,omitzero"` // Time the sling is removed from the board; zero keeps it for the board retention
+	ParentID  string        `json:"parent_id,omitempty"` // ID of the sling this one replies to; empty for top-level slings
+	Filename  string        `json:"filename,omitempty"`  // Original filename of file slings
+	ObjectID  string        `json:"object_id,omitempty"` // Name of the board object holding the content; empty when Content is inline
+	Size      int64         `json:"size,omitempty"`      // Size of the content in bytes when it is stored as a board object
+	Language  string        `json:"language,omitempty"`  // Syntax highlighting language chosen by the sender; empty to detect it
+	Excerpt   *CodeExcerpt  `json:"excerpt,omitempty"`   // Line numbering, highlighted lines and title of code excerpts
+	Playback  *Playback     `json:"playback,omitempty"`  // Player options of video and audio slings
+	Table     *TableOptions `json:"table,omitempty"`     // Display options of CSV and TSV slings
+}
+
+// TableOptions holds the display options of CSV and TSV slings.
+type TableOptions struct {
+	SortBy string `json:"sort_by,omitempty"` // Column name or 1-based number to sort rows by; a leading "-" sorts descending
+}
+
+// Playback holds the player options of video and audio slings on wall
+// screens.
+type Playback struct {
+	Autoplay bool `json:"autoplay,omitempty"` // Start playing when the board is opened; browsers only allow it muted
+	Muted    bool `json:"muted,omitempty"`    // Start with the sound off
+	Loop     bool `json:"loop,omitempty"`     // Start over at the end
+}
+
+// CodeExcerpt describes the part of a source file a code sling shows.
+type CodeExcerpt struct {
+	Title     string   `json:"title,omitempty"`      // Header shown above the code; defaults to the filename
+	FirstLine int      `json:"first_line,omitempty"` // Line number of the first line of the content in the source file
+	Highlight [][2]int `json:"highlight,omitempty"`  // Inclusive line ranges to highlight, numbered as in the source file
+}
+
+// Reaction is the aggregated count of one emoji reaction on a sling.
+type Reaction struct {
+	Emoji   string   `json:"emoji"`             // Emoji viewers reacted with
+	Count   int      `json:"count"`             // Number of times it was added
+	Authors []string `json:"authors,omitempty"` // Named reactors, who may take their reaction back
 }
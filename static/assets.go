@@ -2,5 +2,7 @@ package staticfiles
 
 import "embed"
 
-//go:embed style.css
+//go:generate curl -fsSL -o vendor/mermaid.min.js https://cdn.jsdelivr.net/npm/mermaid@11.4.1/dist/mermaid.min.js
+
+//go:embed style.css vendor
 var FS embed.FS
@@ -8,7 +8,24 @@ package templates
 import "github.com/a-h/templ"
 import templruntime "github.com/a-h/templ/runtime"
 
-func BoardCard(name string) templ.Component {
+import "strings"
+
+// BoardMeta holds the board details shown on index cards and the board
+// header. Title falls back to the board name.
+type BoardMeta struct {
+	Name        string
+	Title       string
+	Description string
+	Owner       string
+	CreatedAt   string
+	Tags        []string
+}
+
+func (b BoardMeta) searchText() string {
+	return strings.ToLower(strings.Join(append([]string{b.Name, b.Title, b.Description, b.Owner}, b.Tags...), " "))
+}
+
+func boardDetails(board BoardMeta) templ.Component {
 	return templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
 		templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
 		if templ_7745c5c3_CtxErr := ctx.Err(); templ_7745c5c3_CtxErr != nil {
@@ -29,42 +46,216 @@ func BoardCard(name string) templ.Component {
 			templ_7745c5c3_Var1 = templ.NopComponent
 		}
 		ctx = templ.ClearChildren(ctx)
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 1, "<a href=\"")
+		if board.Owner != "" || board.CreatedAt != "" {
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 1, "<p class=\"board-details text-xs text-slate-500\">")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			if board.Owner != "" {
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 2, "<span>by ")
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+				var templ_7745c5c3_Var2 string
+				templ_7745c5c3_Var2, templ_7745c5c3_Err = templ.JoinStringErrs(board.Owner)
+				if templ_7745c5c3_Err != nil {
+					return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/index.templ`, Line: 24, Col: 30}
+				}
+				_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var2))
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 3, "</span> ")
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+			}
+			if board.CreatedAt != "" {
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 4, "<span>created ")
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+				var templ_7745c5c3_Var3 string
+				templ_7745c5c3_Var3, templ_7745c5c3_Err = templ.JoinStringErrs(board.CreatedAt)
+				if templ_7745c5c3_Err != nil {
+					return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/index.templ`, Line: 27, Col: 39}
+				}
+				_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var3))
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 5, "</span>")
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 6, "</p>")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+		}
+		if len(board.Tags) > 0 {
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 7, "<ul class=\"board-tags\">")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			for _, tag := range board.Tags {
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 8, "<li class=\"board-tag\">")
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+				var templ_7745c5c3_Var4 string
+				templ_7745c5c3_Var4, templ_7745c5c3_Err = templ.JoinStringErrs(tag)
+				if templ_7745c5c3_Err != nil {
+					return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/index.templ`, Line: 34, Col: 35}
+				}
+				_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var4))
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 9, "</li>")
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 10, "</ul>")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+		}
+		return nil
+	})
+}
+
+func BoardCard(board BoardMeta) templ.Component {
+	return templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
+		templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
+		if templ_7745c5c3_CtxErr := ctx.Err(); templ_7745c5c3_CtxErr != nil {
+			return templ_7745c5c3_CtxErr
+		}
+		templ_7745c5c3_Buffer, templ_7745c5c3_IsBuffer := templruntime.GetBuffer(templ_7745c5c3_W)
+		if !templ_7745c5c3_IsBuffer {
+			defer func() {
+				templ_7745c5c3_BufErr := templruntime.ReleaseBuffer(templ_7745c5c3_Buffer)
+				if templ_7745c5c3_Err == nil {
+					templ_7745c5c3_Err = templ_7745c5c3_BufErr
+				}
+			}()
+		}
+		ctx = templ.InitializeContext(ctx)
+		templ_7745c5c3_Var5 := templ.GetChildren(ctx)
+		if templ_7745c5c3_Var5 == nil {
+			templ_7745c5c3_Var5 = templ.NopComponent
+		}
+		ctx = templ.ClearChildren(ctx)
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 11, "<a href=\"")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		var templ_7745c5c3_Var2 templ.SafeURL = templ.SafeURL("/board/" + name + "/")
-		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(string(templ_7745c5c3_Var2)))
+		var templ_7745c5c3_Var6 templ.SafeURL = templ.SafeURL("/board/" + board.Name + "/")
+		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(string(templ_7745c5c3_Var6)))
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 2, "\" data-board-name=\"")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 12, "\" data-board-name=\"")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		var templ_7745c5c3_Var3 string
-		templ_7745c5c3_Var3, templ_7745c5c3_Err = templ.JoinStringErrs(name)
+		var templ_7745c5c3_Var7 string
+		templ_7745c5c3_Var7, templ_7745c5c3_Err = templ.JoinStringErrs(board.Name)
 		if templ_7745c5c3_Err != nil {
-			return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/index.templ`, Line: 5, Col: 27}
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/index.templ`, Line: 42, Col: 33}
 		}
-		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var3))
+		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var7))
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 3, "\" class=\"group rounded-2xl border border-slate-800/70 bg-slate-900/60 p-6 transition hover:-translate-y-1 hover:border-slate-600/70 hover:bg-slate-900/80\"><div class=\"flex items-start justify-between\"><div><p class=\"text-xs uppercase tracking-[0.2em] text-slate-500\">slingBoard</p><h3 class=\"mt-2 text-xl font-semibold text-slate-100\">")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 13, "\" data-board-search=\"")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		var templ_7745c5c3_Var4 string
-		templ_7745c5c3_Var4, templ_7745c5c3_Err = templ.JoinStringErrs(name)
+		var templ_7745c5c3_Var8 string
+		templ_7745c5c3_Var8, templ_7745c5c3_Err = templ.JoinStringErrs(board.searchText())
 		if templ_7745c5c3_Err != nil {
-			return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/index.templ`, Line: 10, Col: 68}
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/index.templ`, Line: 43, Col: 43}
 		}
-		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var4))
+		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var8))
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 14, "\" class=\"group rounded-2xl border border-slate-800/70 bg-slate-900/60 p-6 transition hover:-translate-y-1 hover:border-slate-600/70 hover:bg-slate-900/80\"><div class=\"flex items-start justify-between\"><div><p class=\"text-xs uppercase tracking-[0.2em] text-slate-500\">slingBoard</p><h3 class=\"mt-2 text-xl font-semibold text-slate-100\">")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		var templ_7745c5c3_Var9 string
+		templ_7745c5c3_Var9, templ_7745c5c3_Err = templ.JoinStringErrs(board.Title)
+		if templ_7745c5c3_Err != nil {
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/index.templ`, Line: 48, Col: 75}
+		}
+		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var9))
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 4, "</h3></div><span class=\"rounded-full border border-slate-700/60 bg-slate-800/60 px-3 py-1 text-xs uppercase tracking-[0.2em] text-slate-300\">View</span></div><p class=\"mt-4 text-sm text-slate-400\">Open the live stream for this slingBoard.</p></a>")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 15, "</h3>")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		if board.Title != board.Name {
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 16, "<p class=\"text-xs text-slate-500\">")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			var templ_7745c5c3_Var10 string
+			templ_7745c5c3_Var10, templ_7745c5c3_Err = templ.JoinStringErrs(board.Name)
+			if templ_7745c5c3_Err != nil {
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/index.templ`, Line: 50, Col: 56}
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var10))
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 17, "</p>")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 18, "</div><span class=\"rounded-full border border-slate-700/60 bg-slate-800/60 px-3 py-1 text-xs uppercase tracking-[0.2em] text-slate-300\">View</span></div>")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		if board.Description != "" {
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 19, "<p class=\"mt-4 text-sm text-slate-400\">")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			var templ_7745c5c3_Var11 string
+			templ_7745c5c3_Var11, templ_7745c5c3_Err = templ.JoinStringErrs(board.Description)
+			if templ_7745c5c3_Err != nil {
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/index.templ`, Line: 58, Col: 64}
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var11))
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 20, "</p>")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+		} else {
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 21, "<p class=\"mt-4 text-sm text-slate-400\">Open the live stream for this slingBoard.</p>")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 22, "<div class=\"mt-4\">")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		templ_7745c5c3_Err = boardDetails(board).Render(ctx, templ_7745c5c3_Buffer)
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 23, "</div></a>")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
@@ -88,12 +279,12 @@ func BoardsIndex(boardCards string) templ.Component {
 			}()
 		}
 		ctx = templ.InitializeContext(ctx)
-		templ_7745c5c3_Var5 := templ.GetChildren(ctx)
-		if templ_7745c5c3_Var5 == nil {
-			templ_7745c5c3_Var5 = templ.NopComponent
+		templ_7745c5c3_Var12 := templ.GetChildren(ctx)
+		if templ_7745c5c3_Var12 == nil {
+			templ_7745c5c3_Var12 = templ.NopComponent
 		}
 		ctx = templ.ClearChildren(ctx)
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 5, "<!doctype html><html lang=\"en\"><head><meta charset=\"UTF-8\"><meta name=\"viewport\" content=\"width=device-width, initial-scale=1.0\"><title>SlingBoard boards</title><link rel=\"stylesheet\" href=\"/static/style.css\"><script src=\"https://cdn.tailwindcss.com\"></script><script type=\"module\" src=\"https://cdn.jsdelivr.net/gh/starfederation/datastar@1.0.0-RC.7/bundles/datastar.js\"></script></head><body class=\"min-h-screen bg-slate-950 text-slate-100 hero-gradient\"><header class=\"border-b border-slate-800/70 bg-slate-950/70 backdrop-blur-xl\"><div class=\"mx-auto flex max-w-6xl flex-wrap items-center justify-between gap-6 px-6 py-6\"><div><p class=\"text-sm uppercase tracking-[0.2em] text-slate-400\">SlingBoard</p><h1 class=\"mt-2 text-3xl font-semibold\">slingBoards</h1><p class=\"mt-2 max-w-xl text-sm text-slate-400\">Search existing slingBoards or create a new one to start slinging.</p></div><div class=\"flex w-full flex-col gap-3 sm:w-auto sm:min-w-[280px]\"><label class=\"text-xs uppercase tracking-[0.2em] text-slate-500\">Search slingBoards</label> <input id=\"board-search\" type=\"search\" placeholder=\"Search\" class=\"rounded-xl border border-slate-700/70 bg-slate-900/70 px-4 py-3 text-sm text-slate-200 placeholder:text-slate-600 focus:border-slate-500 focus:outline-none\"></div></div></header><main class=\"mx-auto max-w-6xl px-6 py-10\"><section class=\"rounded-3xl border border-slate-800/70 bg-slate-900/40 p-6 shadow-2xl\"><div class=\"flex flex-wrap items-center justify-between gap-6\"><div><h2 class=\"text-xl font-semibold\">Create a slingBoard</h2><p class=\"mt-1 text-sm text-slate-400\">slingBoard names are URI compatible and lower-case.</p></div><div class=\"flex w-full flex-col gap-3 sm:w-auto sm:flex-row\"><input id=\"board-create\" type=\"text\" placeholder=\"new-board\" class=\"flex-1 rounded-xl border border-slate-700/70 bg-slate-900/70 px-4 py-3 text-sm text-slate-200 placeholder:text-slate-600 focus:border-slate-500 focus:outline-none\"> <button id=\"board-create-btn\" class=\"rounded-xl bg-slate-200 px-5 py-3 text-sm font-semibold text-slate-900 transition hover:bg-white\">Create</button></div></div></section><section class=\"mt-10\"><div class=\"flex items-center justify-between\"><h2 class=\"text-xl font-semibold\">Available slingBoards</h2><span class=\"text-xs uppercase tracking-[0.2em] text-slate-500\" id=\"board-count\"></span></div><div id=\"board-empty\" class=\"mt-6 rounded-2xl border border-dashed border-slate-800/70 bg-slate-900/40 p-10 text-center text-sm text-slate-400\">Waiting for slings...</div><div id=\"board-list\" class=\"board-grid mt-6\">")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 24, "<!doctype html><html lang=\"en\"><head><meta charset=\"UTF-8\"><meta name=\"viewport\" content=\"width=device-width, initial-scale=1.0\"><title>SlingBoard boards</title><link rel=\"stylesheet\" href=\"/static/style.css\"><script src=\"https://cdn.tailwindcss.com\"></script><script type=\"module\" src=\"https://cdn.jsdelivr.net/gh/starfederation/datastar@1.0.0-RC.7/bundles/datastar.js\"></script></head><body class=\"min-h-screen bg-slate-950 text-slate-100 hero-gradient\"><header class=\"border-b border-slate-800/70 bg-slate-950/70 backdrop-blur-xl\"><div class=\"mx-auto flex max-w-6xl flex-wrap items-center justify-between gap-6 px-6 py-6\"><div><p class=\"text-sm uppercase tracking-[0.2em] text-slate-400\">SlingBoard</p><h1 class=\"mt-2 text-3xl font-semibold\">slingBoards</h1><p class=\"mt-2 max-w-xl text-sm text-slate-400\">Search existing slingBoards or create a new one to start slinging.</p></div><div class=\"flex w-full flex-col gap-3 sm:w-auto sm:min-w-[280px]\"><label class=\"text-xs uppercase tracking-[0.2em] text-slate-500\">Search slingBoards</label> <input id=\"board-search\" type=\"search\" placeholder=\"Search\" class=\"rounded-xl border border-slate-700/70 bg-slate-900/70 px-4 py-3 text-sm text-slate-200 placeholder:text-slate-600 focus:border-slate-500 focus:outline-none\"></div></div></header><main class=\"mx-auto max-w-6xl px-6 py-10\"><section class=\"rounded-3xl border border-slate-800/70 bg-slate-900/40 p-6 shadow-2xl\"><div class=\"flex flex-wrap items-center justify-between gap-6\"><div><h2 class=\"text-xl font-semibold\">Create a slingBoard</h2><p class=\"mt-1 text-sm text-slate-400\">slingBoard names are URI compatible and lower-case.</p></div><div class=\"flex w-full flex-col gap-3 sm:w-auto sm:flex-row\"><input id=\"board-create\" type=\"text\" placeholder=\"new-board\" class=\"flex-1 rounded-xl border border-slate-700/70 bg-slate-900/70 px-4 py-3 text-sm text-slate-200 placeholder:text-slate-600 focus:border-slate-500 focus:outline-none\"> <button id=\"board-create-btn\" class=\"rounded-xl bg-slate-200 px-5 py-3 text-sm font-semibold text-slate-900 transition hover:bg-white\">Create</button></div></div></section><section class=\"mt-10\"><div class=\"flex items-center justify-between\"><h2 class=\"text-xl font-semibold\">Available slingBoards</h2><span class=\"text-xs uppercase tracking-[0.2em] text-slate-500\" id=\"board-count\"></span></div><div id=\"board-empty\" class=\"mt-6 rounded-2xl border border-dashed border-slate-800/70 bg-slate-900/40 p-10 text-center text-sm text-slate-400\">Waiting for slings...</div><div id=\"board-list\" class=\"board-grid mt-6\">")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
@@ -101,7 +292,60 @@ func BoardsIndex(boardCards string) templ.Component {
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 6, "</div></section></main><script>\n    const boardList = document.getElementById(\"board-list\");\n    const boardEmpty = document.getElementById(\"board-empty\");\n    const boardSearch = document.getElementById(\"board-search\");\n    const boardCreate = document.getElementById(\"board-create\");\n    const boardCreateBtn = document.getElementById(\"board-create-btn\");\n    const boardCount = document.getElementById(\"board-count\");\n\n    const normalizeBoardName = (value) => {\n      const lowered = value.trim().toLowerCase();\n      let output = \"\";\n      let lastDash = false;\n      for (const ch of lowered) {\n        if ((ch >= \"a\" && ch <= \"z\") || (ch >= \"0\" && ch <= \"9\") || ch === \"-\" || ch === \"_\") {\n          output += ch;\n          lastDash = false;\n        } else if (!lastDash) {\n          output += \"-\";\n          lastDash = true;\n        }\n      }\n      return output.replace(/^-+|-+$/g, \"\");\n    };\n\n    const updateEmptyState = () => {\n      const visibleCards = boardList.querySelectorAll(\"[data-board-name]:not(.hidden)\").length;\n      boardEmpty.classList.toggle(\"hidden\", visibleCards > 0);\n      boardCount.textContent = visibleCards > 0 ? visibleCards + \" slingBoards\" : \"\";\n    };\n\n    const filterBoards = () => {\n      const query = normalizeBoardName(boardSearch.value || \"\");\n      boardList.querySelectorAll(\"[data-board-name]\").forEach((card) => {\n        const match = card.dataset.boardName.includes(query);\n        card.classList.toggle(\"hidden\", !match);\n      });\n      updateEmptyState();\n    };\n\n    const createBoard = () => {\n      const name = normalizeBoardName(boardCreate.value || \"\");\n      if (!name) {\n        return;\n      }\n\n      const existing = boardList.querySelector('[data-board-name=\"' + name + '\"]');\n      if (!existing) {\n        const card = document.createElement(\"a\");\n        card.href = \"/board/\" + name + \"/\";\n        card.dataset.boardName = name;\n        card.className = \"group rounded-2xl border border-slate-800/70 bg-slate-900/60 p-6 transition hover:-translate-y-1 hover:border-slate-600/70 hover:bg-slate-900/80\";\n        card.innerHTML =\n          '<div class=\"flex items-start justify-between\">' +\n          '<div>' +\n          '<p class=\"text-xs uppercase tracking-[0.2em] text-slate-500\">slingBoard</p>' +\n          '<h3 class=\"mt-2 text-xl font-semibold text-slate-100\">' + name + '</h3>' +\n          '</div>' +\n          '<span class=\"rounded-full border border-slate-700/60 bg-slate-800/60 px-3 py-1 text-xs uppercase tracking-[0.2em] text-slate-300\">View</span>' +\n          '</div>' +\n          '<p class=\"mt-4 text-sm text-slate-400\">Open the live stream for this slingBoard.</p>';\n        boardList.prepend(card);\n        updateEmptyState();\n      }\n\n      window.location.href = \"/board/\" + name + \"/\";\n    };\n\n    boardSearch?.addEventListener(\"input\", filterBoards);\n    boardCreateBtn?.addEventListener(\"click\", createBoard);\n    boardCreate?.addEventListener(\"keydown\", (event) => {\n      if (event.key === \"Enter\") {\n        event.preventDefault();\n        createBoard();\n      }\n    });\n\n    updateEmptyState();\n  </script></body></html>")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 25, "</div></section></main><script>\n    const boardList = document.getElementById(\"board-list\");\n    const boardEmpty = document.getElementById(\"board-empty\");\n    const boardSearch = document.getElementById(\"board-search\");\n    const boardCreate = document.getElementById(\"board-create\");\n    const boardCreateBtn = document.getElementById(\"board-create-btn\");\n    const boardCount = document.getElementById(\"board-count\");\n\n    const normalizeBoardName = (value) => {\n      const lowered = value.trim().toLowerCase();\n      let output = \"\";\n      let lastDash = false;\n      for (const ch of lowered) {\n        if ((ch >= \"a\" && ch <= \"z\") || (ch >= \"0\" && ch <= \"9\") || ch === \"-\" || ch === \"_\") {\n          output += ch;\n          lastDash = false;\n        } else if (!lastDash) {\n          output += \"-\";\n          lastDash = true;\n        }\n      }\n      return output.replace(/^-+|-+$/g, \"\");\n    };\n\n    const updateEmptyState = () => {\n      const visibleCards = boardList.querySelectorAll(\"[data-board-name]:not(.hidden)\").length;\n      boardEmpty.classList.toggle(\"hidden\", visibleCards > 0);\n      boardCount.textContent = visibleCards > 0 ? visibleCards + \" slingBoards\" : \"\";\n    };\n\n    const filterBoards = () => {\n      const query = normalizeBoardName(boardSearch.value || \"\");\n      boardList.querySelectorAll(\"[data-board-name]\").forEach((card) => {\n        const searchText = card.dataset.boardSearch || card.dataset.boardName;\n        const match = card.dataset.boardName.includes(query) || searchText.includes((boardSearch.value || \"\").trim().toLowerCase());\n        card.classList.toggle(\"hidden\", !match);\n      });\n      updateEmptyState();\n    };\n\n    const createBoard = () => {\n      const name = normalizeBoardName(boardCreate.value || \"\");\n      if (!name) {\n        return;\n      }\n\n      const existing = boardList.querySelector('[data-board-name=\"' + name + '\"]');\n      if (!existing) {\n        const card = document.createElement(\"a\");\n        card.href = \"/board/\" + name + \"/\";\n        card.dataset.boardName = name;\n        card.className = \"group rounded-2xl border border-slate-800/70 bg-slate-900/60 p-6 transition hover:-translate-y-1 hover:border-slate-600/70 hover:bg-slate-900/80\";\n        card.innerHTML =\n          '<div class=\"flex items-start justify-between\">' +\n          '<div>' +\n          '<p class=\"text-xs uppercase tracking-[0.2em] text-slate-500\">slingBoard</p>' +\n          '<h3 class=\"mt-2 text-xl font-semibold text-slate-100\">' + name + '</h3>' +\n          '</div>' +\n          '<span class=\"rounded-full border border-slate-700/60 bg-slate-800/60 px-3 py-1 text-xs uppercase tracking-[0.2em] text-slate-300\">View</span>' +\n          '</div>' +\n          '<p class=\"mt-4 text-sm text-slate-400\">Open the live stream for this slingBoard.</p>';\n        boardList.prepend(card);\n        updateEmptyState();\n      }\n\n      window.location.href = \"/board/\" + name + \"/\";\n    };\n\n    boardSearch?.addEventListener(\"input\", filterBoards);\n    boardCreateBtn?.addEventListener(\"click\", createBoard);\n    boardCreate?.addEventListener(\"keydown\", (event) => {\n      if (event.key === \"Enter\") {\n        event.preventDefault();\n        createBoard();\n      }\n    });\n\n    updateEmptyState();\n  </script></body></html>")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		return nil
+	})
+}
+
+// codeStyleSheet is the style element holding a board's code colours.
+func codeStyleSheet(css string) templ.Component {
+	return templ.Raw(`<style id="code-style">` + css + `</style>`)
+}
+
+// CodeStyleChanged swaps the code highlighting colours of a board in place.
+func CodeStyleChanged(css string) templ.Component {
+	return templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
+		templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
+		if templ_7745c5c3_CtxErr := ctx.Err(); templ_7745c5c3_CtxErr != nil {
+			return templ_7745c5c3_CtxErr
+		}
+		templ_7745c5c3_Buffer, templ_7745c5c3_IsBuffer := templruntime.GetBuffer(templ_7745c5c3_W)
+		if !templ_7745c5c3_IsBuffer {
+			defer func() {
+				templ_7745c5c3_BufErr := templruntime.ReleaseBuffer(templ_7745c5c3_Buffer)
+				if templ_7745c5c3_Err == nil {
+					templ_7745c5c3_Err = templ_7745c5c3_BufErr
+				}
+			}()
+		}
+		ctx = templ.InitializeContext(ctx)
+		templ_7745c5c3_Var13 := templ.GetChildren(ctx)
+		if templ_7745c5c3_Var13 == nil {
+			templ_7745c5c3_Var13 = templ.NopComponent
+		}
+		ctx = templ.ClearChildren(ctx)
+		templ_7745c5c3_Var14 := templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
+			templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
+			templ_7745c5c3_Buffer, templ_7745c5c3_IsBuffer := templruntime.GetBuffer(templ_7745c5c3_W)
+			if !templ_7745c5c3_IsBuffer {
+				defer func() {
+					templ_7745c5c3_BufErr := templruntime.ReleaseBuffer(templ_7745c5c3_Buffer)
+					if templ_7745c5c3_Err == nil {
+						templ_7745c5c3_Err = templ_7745c5c3_BufErr
+					}
+				}()
+			}
+			ctx = templ.InitializeContext(ctx)
+			templ_7745c5c3_Err = codeStyleSheet(css).Render(ctx, templ_7745c5c3_Buffer)
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			return nil
+		})
+		templ_7745c5c3_Err = patch("outer", "").Render(templ.WithChildren(ctx, templ_7745c5c3_Var14), templ_7745c5c3_Buffer)
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
@@ -109,7 +353,10 @@ func BoardsIndex(boardCards string) templ.Component {
 	})
 }
 
-func BoardView(board string) templ.Component {
+// BoardView renders a board with its pinned slings and the latest slings from
+// its history. historySeq is the last stream sequence included in history, so
+// the websocket replay can skip slings that are already on the page.
+func BoardView(board BoardMeta, codeCSS string, pinned string, history string, more bool, historySeq string) templ.Component {
 	return templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
 		templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
 		if templ_7745c5c3_CtxErr := ctx.Err(); templ_7745c5c3_CtxErr != nil {
@@ -125,51 +372,124 @@ func BoardView(board string) templ.Component {
 			}()
 		}
 		ctx = templ.InitializeContext(ctx)
-		templ_7745c5c3_Var6 := templ.GetChildren(ctx)
-		if templ_7745c5c3_Var6 == nil {
-			templ_7745c5c3_Var6 = templ.NopComponent
+		templ_7745c5c3_Var15 := templ.GetChildren(ctx)
+		if templ_7745c5c3_Var15 == nil {
+			templ_7745c5c3_Var15 = templ.NopComponent
 		}
 		ctx = templ.ClearChildren(ctx)
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 7, "<!doctype html><html lang=\"en\"><head><meta charset=\"UTF-8\"><meta name=\"viewport\" content=\"width=device-width, initial-scale=1.0\"><title>SlingBoard · ")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 26, "<!doctype html><html lang=\"en\"><head><meta charset=\"UTF-8\"><meta name=\"viewport\" content=\"width=device-width, initial-scale=1.0\"><title>SlingBoard · ")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		var templ_7745c5c3_Var7 string
-		templ_7745c5c3_Var7, templ_7745c5c3_Err = templ.JoinStringErrs(board)
+		var templ_7745c5c3_Var16 string
+		templ_7745c5c3_Var16, templ_7745c5c3_Err = templ.JoinStringErrs(board.Title)
 		if templ_7745c5c3_Err != nil {
-			return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/index.templ`, Line: 162, Col: 30}
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/index.templ`, Line: 226, Col: 36}
 		}
-		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var7))
+		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var16))
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 8, "</title><link rel=\"stylesheet\" href=\"/static/style.css\"><script src=\"https://cdn.tailwindcss.com\"></script><script type=\"module\" src=\"https://cdn.jsdelivr.net/gh/starfederation/datastar@1.0.0-RC.7/bundles/datastar.js\"></script></head><body class=\"h-screen bg-slate-950 text-slate-100 hero-gradient\"><header class=\"fixed top-0 left-0 w-full z-10 border-b border-slate-800/70 bg-slate-950/70 backdrop-blur-xl\"><div class=\"mx-auto flex max-w-6xl items-center justify-between px-6 py-4\"><div><p class=\"text-sm uppercase tracking-[0.2em] text-slate-400\"><a href=\"/\" class=\"hover:text-slate-200\">slingBoard</a></p><h1 class=\"text-2xl font-semibold\">")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 27, "</title><link rel=\"stylesheet\" href=\"/static/style.css\">")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		var templ_7745c5c3_Var8 string
-		templ_7745c5c3_Var8, templ_7745c5c3_Err = templ.JoinStringErrs(board)
+		templ_7745c5c3_Err = codeStyleSheet(codeCSS).Render(ctx, templ_7745c5c3_Buffer)
 		if templ_7745c5c3_Err != nil {
-			return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/index.templ`, Line: 172, Col: 50}
+			return templ_7745c5c3_Err
 		}
-		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var8))
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 28, "<script src=\"https://cdn.tailwindcss.com\"></script><script type=\"module\" src=\"https://cdn.jsdelivr.net/gh/starfederation/datastar@1.0.0-RC.7/bundles/datastar.js\"></script></head><body class=\"h-screen bg-slate-950 text-slate-100 hero-gradient\"><header class=\"fixed top-0 left-0 w-full z-10 border-b border-slate-800/70 bg-slate-950/70 backdrop-blur-xl\"><div class=\"mx-auto flex max-w-6xl items-center justify-between px-6 py-4\"><div><p class=\"text-sm uppercase tracking-[0.2em] text-slate-400\"><a href=\"/\" class=\"hover:text-slate-200\">slingBoard</a></p><h1 class=\"text-2xl font-semibold\" title=\"")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 9, "</h1></div><div class=\"user-pill\" id=\"user-pill\"><span class=\"user-pill__label\">You:</span> <span id=\"current-user-name\"></span> <button type=\"button\" id=\"user-regenerate\" class=\"user-pill__action\" aria-label=\"Regenerate username\">↻</button></div><div class=\"flex items-center gap-3\"><a href=\"/\" class=\"rounded-full border border-slate-700/60 bg-slate-900/80 px-4 py-2 text-xs font-semibold uppercase tracking-[0.2em] text-slate-300\">All slingBoards</a> <button id=\"grid-toggle\" class=\"rounded-full border border-slate-700/60 bg-slate-900/80 px-4 py-2 text-xs font-semibold uppercase tracking-[0.2em] text-slate-300\">Grid view</button></div></div></header><div id=\"slingboard\" class=\"scroll-container\" data-board-name=\"")
+		var templ_7745c5c3_Var17 string
+		templ_7745c5c3_Var17, templ_7745c5c3_Err = templ.JoinStringErrs(board.Description)
+		if templ_7745c5c3_Err != nil {
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/index.templ`, Line: 237, Col: 68}
+		}
+		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var17))
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		var templ_7745c5c3_Var9 string
-		templ_7745c5c3_Var9, templ_7745c5c3_Err = templ.JoinStringErrs(board)
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 29, "\">")
 		if templ_7745c5c3_Err != nil {
-			return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/index.templ`, Line: 186, Col: 71}
+			return templ_7745c5c3_Err
 		}
-		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var9))
+		var templ_7745c5c3_Var18 string
+		templ_7745c5c3_Var18, templ_7745c5c3_Err = templ.JoinStringErrs(board.Title)
+		if templ_7745c5c3_Err != nil {
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/index.templ`, Line: 237, Col: 84}
+		}
+		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var18))
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 30, "</h1><div class=\"board-header-details\">")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		templ_7745c5c3_Err = boardDetails(board).Render(ctx, templ_7745c5c3_Buffer)
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 31, "</div></div><div class=\"user-pill\" id=\"user-pill\"><span class=\"user-pill__label\">You:</span> <span id=\"current-user-name\"></span> <button type=\"button\" id=\"user-regenerate\" class=\"user-pill__action\" aria-label=\"Regenerate username\">↻</button></div><div class=\"flex items-center gap-3\"><a href=\"/\" class=\"rounded-full border border-slate-700/60 bg-slate-900/80 px-4 py-2 text-xs font-semibold uppercase tracking-[0.2em] text-slate-300\">All slingBoards</a> <button id=\"grid-toggle\" class=\"rounded-full border border-slate-700/60 bg-slate-900/80 px-4 py-2 text-xs font-semibold uppercase tracking-[0.2em] text-slate-300\">Grid view</button></div></div></header><div id=\"slingboard\" class=\"scroll-container\" data-board-name=\"")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		var templ_7745c5c3_Var19 string
+		templ_7745c5c3_Var19, templ_7745c5c3_Err = templ.JoinStringErrs(board.Name)
+		if templ_7745c5c3_Err != nil {
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/index.templ`, Line: 254, Col: 76}
+		}
+		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var19))
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 32, "\" data-history-seq=\"")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		var templ_7745c5c3_Var20 string
+		templ_7745c5c3_Var20, templ_7745c5c3_Err = templ.JoinStringErrs(historySeq)
+		if templ_7745c5c3_Err != nil {
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/index.templ`, Line: 254, Col: 108}
+		}
+		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var20))
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 33, "\"><div id=\"pinned\">")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		templ_7745c5c3_Err = templ.Raw(pinned).Render(ctx, templ_7745c5c3_Buffer)
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 34, "</div><div id=\"slings\">")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		if history != "" {
+			templ_7745c5c3_Err = templ.Raw(history).Render(ctx, templ_7745c5c3_Buffer)
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+		} else {
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 35, "<div class=\"sling text-white\" id=\"sling-placeholder\"><div class=\"sling-card text-2xl font-semibold text-center\">Waiting for slings...</div></div>")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 36, "</div>")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		templ_7745c5c3_Err = SlingsOlder(more).Render(ctx, templ_7745c5c3_Buffer)
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 10, "\"><div id=\"slings\"><div class=\"sling text-white\" id=\"sling-placeholder\"><div class=\"sling-card text-2xl font-semibold text-center\">Waiting for slings...</div></div></div></div><button id=\"add-sling\" class=\"floating-action\" aria-label=\"Add sling\" type=\"button\">+</button><div id=\"add-sling-modal\" class=\"modal\" aria-hidden=\"true\"><div class=\"modal__backdrop\" data-modal-close></div><div class=\"modal__card\" role=\"dialog\" aria-modal=\"true\" aria-labelledby=\"add-sling-title\"><div class=\"modal__header\"><div><p class=\"text-xs uppercase tracking-[0.2em] text-slate-400\">slingBoard</p><h2 id=\"add-sling-title\" class=\"text-2xl font-semibold\">Add sling</h2></div><button type=\"button\" class=\"modal__close\" data-modal-close aria-label=\"Close\">×</button></div><div class=\"modal__tabs\" role=\"tablist\"><button class=\"modal__tab is-active\" type=\"button\" data-tab=\"text\">Message</button> <button class=\"modal__tab\" type=\"button\" data-tab=\"url\">URL</button> <button class=\"modal__tab\" type=\"button\" data-tab=\"file\">File</button></div><form id=\"add-sling-form\" class=\"modal__body\"><div class=\"modal__panel\" data-panel=\"text\"><label class=\"modal__label\">Message</label> <textarea id=\"sling-message\" rows=\"5\" placeholder=\"Write a message\" class=\"modal__input\"></textarea></div><div class=\"modal__panel hidden\" data-panel=\"url\"><label class=\"modal__label\">URL</label> <input id=\"sling-url\" type=\"url\" placeholder=\"https://\" class=\"modal__input\"></div><div class=\"modal__panel hidden\" data-panel=\"file\"><label class=\"modal__label\">File</label> <input id=\"sling-file\" type=\"file\" class=\"modal__input\"></div><p id=\"sling-status\" class=\"modal__status\"></p><div class=\"modal__actions\"><button type=\"button\" class=\"btn-secondary\" data-modal-close>Cancel</button> <button type=\"submit\" class=\"btn-primary\">Send sling</button></div></form></div></div><script type=\"module\">\n    window.addEventListener(\"DOMContentLoaded\", () => {\n      const container = document.querySelector(\".scroll-container\");\n      const slings = document.getElementById(\"slings\");\n      const gridToggle = document.getElementById(\"grid-toggle\");\n      const addButton = document.getElementById(\"add-sling\");\n      const modal = document.getElementById(\"add-sling-modal\");\n      const modalClose = modal?.querySelectorAll(\"[data-modal-close]\") || [];\n      const tabs = modal?.querySelectorAll(\"[data-tab]\") || [];\n      const panels = modal?.querySelectorAll(\"[data-panel]\") || [];\n      const form = document.getElementById(\"add-sling-form\");\n      const status = document.getElementById(\"sling-status\");\n      const messageInput = document.getElementById(\"sling-message\");\n      const urlInput = document.getElementById(\"sling-url\");\n      const fileInput = document.getElementById(\"sling-file\");\n      const userName = document.getElementById(\"current-user-name\");\n      const userRegenerate = document.getElementById(\"user-regenerate\");\n      let activeTab = \"text\";\n\n      const adjectives = [\"brave\", \"calm\", \"curious\", \"eager\", \"gentle\", \"kind\", \"lively\", \"mellow\", \"quiet\", \"witty\"];\n      const animals = [\"otter\", \"fox\", \"hawk\", \"panda\", \"tiger\", \"koala\", \"owl\", \"whale\", \"lynx\", \"swift\"];\n\n      const generateUser = () => {\n        const adjective = adjectives[Math.floor(Math.random() * adjectives.length)];\n        const animal = animals[Math.floor(Math.random() * animals.length)];\n        return `${adjective}-${animal}`;\n      };\n\n      const getLocalUser = () => {\n        const stored = window.localStorage.getItem(\"sling_user\");\n        if (stored) {\n          return stored;\n        }\n        const generated = generateUser();\n        window.localStorage.setItem(\"sling_user\", generated);\n        return generated;\n      };\n\n      let localUser = getLocalUser();\n\n      if (!container || !slings) {\n        return;\n      }\n\n      let isUserScrolling = false;\n      let lastScrollPosition = container.scrollTop;\n      let isGridMode = false;\n\n      const setGridMode = (enabled) => {\n        isGridMode = enabled;\n        container.classList.toggle(\"grid-mode\", enabled);\n        slings.classList.toggle(\"grid-mode\", enabled);\n        gridToggle.textContent = enabled ? \"Scroll view\" : \"Grid view\";\n      };\n\n      const trimSlings = () => {\n        const items = slings.querySelectorAll(\".sling\");\n        if (items.length <= 20) {\n          return;\n        }\n        for (let i = items.length - 1; i >= 20; i -= 1) {\n          items[i].remove();\n        }\n      };\n\n      container.addEventListener(\"scroll\", () => {\n        isUserScrolling = Math.abs(container.scrollTop - lastScrollPosition) > 10;\n        lastScrollPosition = container.scrollTop;\n      });\n\n      gridToggle?.addEventListener(\"click\", () => {\n        setGridMode(!isGridMode);\n      });\n\n      slings.addEventListener(\"click\", (event) => {\n        if (!isGridMode) {\n          return;\n        }\n        const target = event.target.closest(\"[data-sling-id]\");\n        if (!target) {\n          return;\n        }\n        setGridMode(false);\n        target.scrollIntoView({ behavior: \"smooth\", block: \"start\" });\n      });\n\n      const protocol = window.location.protocol === \"https:\" ? \"wss\" : \"ws\";\n      const ws = new WebSocket(protocol + \"://\" + window.location.host + window.location.pathname);\n\n      const setStatus = (text, isError = false) => {\n        if (!status) {\n          return;\n        }\n        status.textContent = text;\n        status.classList.toggle(\"is-error\", isError);\n      };\n\n      const setUserBadge = () => {\n        if (userName) {\n          userName.textContent = localUser;\n        }\n      };\n\n      const openModal = () => {\n        modal?.classList.add(\"is-open\");\n        modal?.setAttribute(\"aria-hidden\", \"false\");\n        setStatus(\"\");\n      };\n\n      const closeModal = () => {\n        modal?.classList.remove(\"is-open\");\n        modal?.setAttribute(\"aria-hidden\", \"true\");\n        setStatus(\"\");\n        if (messageInput) messageInput.value = \"\";\n        if (urlInput) urlInput.value = \"\";\n        if (fileInput) fileInput.value = \"\";\n      };\n\n      const setActiveTab = (name) => {\n        activeTab = name;\n        tabs.forEach((tab) => tab.classList.toggle(\"is-active\", tab.dataset.tab === name));\n        panels.forEach((panel) => panel.classList.toggle(\"hidden\", panel.dataset.panel !== name));\n      };\n\n      const readFileAsBase64 = (file) =>\n        new Promise((resolve, reject) => {\n          const reader = new FileReader();\n          reader.onload = () => {\n            const result = String(reader.result || \"\");\n            const commaIndex = result.indexOf(\",\");\n            if (commaIndex === -1) {\n              reject(new Error(\"Invalid file encoding\"));\n              return;\n            }\n            resolve(result.slice(commaIndex + 1));\n          };\n          reader.onerror = () => reject(new Error(\"Failed to read file\"));\n          reader.readAsDataURL(file);\n        });\n\n      const submitSling = async (event) => {\n        event.preventDefault();\n        const board = container.dataset.boardName || \"\";\n        if (!board) {\n          setStatus(\"Missing board name\", true);\n          return;\n        }\n\n        let payload = { type: activeTab, board: board, author: localUser, content: \"\" };\n\n        try {\n          if (activeTab === \"text\") {\n            const value = messageInput?.value.trim() || \"\";\n            if (!value) {\n              setStatus(\"Message is required\", true);\n              return;\n            }\n            payload.content = value;\n          } else if (activeTab === \"url\") {\n            const value = urlInput?.value.trim() || \"\";\n            if (!value) {\n              setStatus(\"URL is required\", true);\n              return;\n            }\n            payload.content = value;\n          } else if (activeTab === \"file\") {\n            const file = fileInput?.files?.[0];\n            if (!file) {\n              setStatus(\"File is required\", true);\n              return;\n            }\n            const encoded = await readFileAsBase64(file);\n            payload = {\n              type: \"file\",\n              board: board,\n              author: localUser,\n              content: encoded,\n              filename: file.name,\n              mime_type: file.type || \"application/octet-stream\",\n            };\n          }\n\n          setStatus(\"Sending...\");\n\n          const response = await fetch(\"/api/commands\", {\n            method: \"POST\",\n            headers: { \"Content-Type\": \"application/json\", Accept: \"application/json\" },\n            body: JSON.stringify(payload),\n          });\n\n          const data = await response.json().catch(() => ({}));\n          if (!response.ok || data.status === \"error\") {\n            throw new Error(data.message || \"Failed to send sling\");\n          }\n\n          closeModal();\n        } catch (error) {\n          setStatus(error.message || \"Failed to send sling\", true);\n        }\n      };\n\n      setUserBadge();\n\n      addButton?.addEventListener(\"click\", openModal);\n      modalClose.forEach((button) => button.addEventListener(\"click\", closeModal));\n      tabs.forEach((tab) => tab.addEventListener(\"click\", () => setActiveTab(tab.dataset.tab)));\n      form?.addEventListener(\"submit\", submitSling);\n      userRegenerate?.addEventListener(\"click\", () => {\n        localUser = generateUser();\n        window.localStorage.setItem(\"sling_user\", localUser);\n        setUserBadge();\n      });\n      window.addEventListener(\"keydown\", (event) => {\n        if (event.key === \"Escape\") {\n          closeModal();\n        }\n      });\n\n      const formatTimestamps = (root = document) => {\n        const timestamps = root.querySelectorAll(\"[data-timestamp]\");\n        timestamps.forEach((element) => {\n          const value = element.dataset.timestamp;\n          if (!value) {\n            return;\n          }\n          const date = new Date(value);\n          if (Number.isNaN(date.getTime())) {\n            element.textContent = value;\n            return;\n          }\n          element.textContent = date.toLocaleTimeString([], { hour: \"2-digit\", minute: \"2-digit\" });\n        });\n      };\n\n      ws.addEventListener(\"message\", (event) => {\n        const html = event.data;\n\n        document.dispatchEvent(\n          new CustomEvent(\"datastar-fetch\", {\n            detail: {\n              type: \"datastar-patch-elements\",\n              argsRaw: {\n                selector: \"#slings\",\n                mode: \"prepend\",\n                elements: html,\n              },\n            },\n          }),\n        );\n\n        window.requestAnimationFrame(() => {\n          trimSlings();\n\n          const firstSling = slings.firstElementChild;\n          if (firstSling) {\n            formatTimestamps(firstSling);\n            firstSling.classList.add(\"sling--focus\");\n            window.setTimeout(() => firstSling.classList.remove(\"sling--focus\"), 2000);\n            firstSling.scrollIntoView({ behavior: \"smooth\", block: \"start\" });\n          }\n        });\n      });\n\n    });\n  </script></body></html>")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 37, "</div><button id=\"add-sling\" class=\"floating-action\" aria-label=\"Add sling\" type=\"button\">+</button><div id=\"add-sling-modal\" class=\"modal\" aria-hidden=\"true\"><div class=\"modal__backdrop\" data-modal-close></div><div class=\"modal__card\" role=\"dialog\" aria-modal=\"true\" aria-labelledby=\"add-sling-title\"><div class=\"modal__header\"><div><p class=\"text-xs uppercase tracking-[0.2em] text-slate-400\">slingBoard</p><h2 id=\"add-sling-title\" class=\"text-2xl font-semibold\">Add sling</h2></div><button type=\"button\" class=\"modal__close\" data-modal-close aria-label=\"Close\">×</button></div><div class=\"modal__tabs\" role=\"tablist\"><button class=\"modal__tab is-active\" type=\"button\" data-tab=\"text\">Message</button> <button class=\"modal__tab\" type=\"button\" data-tab=\"url\">URL</button> <button class=\"modal__tab\" type=\"button\" data-tab=\"file\">File</button></div><form id=\"add-sling-form\" class=\"modal__body\"><div class=\"modal__panel\" data-panel=\"text\"><label class=\"modal__label\">Message</label> <textarea id=\"sling-message\" rows=\"5\" placeholder=\"Write a message\" class=\"modal__input\"></textarea></div><div class=\"modal__panel hidden\" data-panel=\"url\"><label class=\"modal__label\">URL</label> <input id=\"sling-url\" type=\"url\" placeholder=\"https://\" class=\"modal__input\"></div><div class=\"modal__panel hidden\" data-panel=\"file\"><label class=\"modal__label\">File</label> <input id=\"sling-file\" type=\"file\" class=\"modal__input\"></div><div class=\"modal__panel\"><label class=\"modal__label\" for=\"sling-ttl\">Expires</label> <select id=\"sling-ttl\" class=\"modal__input\"><option value=\"\">Never</option> <option value=\"5m\">In 5 minutes</option> <option value=\"15m\">In 15 minutes</option> <option value=\"1h\">In 1 hour</option> <option value=\"8h\">In 8 hours</option></select></div><p id=\"sling-status\" class=\"modal__status\"></p><div class=\"modal__actions\"><button type=\"button\" class=\"btn-secondary\" data-modal-close>Cancel</button> <button type=\"submit\" class=\"btn-primary\">Send sling</button></div></form></div></div><script type=\"module\">\n    window.addEventListener(\"DOMContentLoaded\", () => {\n      const container = document.querySelector(\".scroll-container\");\n      const slings = document.getElementById(\"slings\");\n      const pinned = document.getElementById(\"pinned\");\n      const gridToggle = document.getElementById(\"grid-toggle\");\n      const addButton = document.getElementById(\"add-sling\");\n      const modal = document.getElementById(\"add-sling-modal\");\n      const modalClose = modal?.querySelectorAll(\"[data-modal-close]\") || [];\n      const tabs = modal?.querySelectorAll(\"[data-tab]\") || [];\n      const panels = modal?.querySelectorAll(\"[data-panel]\") || [];\n      const form = document.getElementById(\"add-sling-form\");\n      const status = document.getElementById(\"sling-status\");\n      const messageInput = document.getElementById(\"sling-message\");\n      const urlInput = document.getElementById(\"sling-url\");\n      const fileInput = document.getElementById(\"sling-file\");\n      const ttlInput = document.getElementById(\"sling-ttl\");\n      const modalTitle = document.getElementById(\"add-sling-title\");\n      const userName = document.getElementById(\"current-user-name\");\n      const userRegenerate = document.getElementById(\"user-regenerate\");\n      let activeTab = \"text\";\n\n      const adjectives = [\"brave\", \"calm\", \"curious\", \"eager\", \"gentle\", \"kind\", \"lively\", \"mellow\", \"quiet\", \"witty\"];\n      const animals = [\"otter\", \"fox\", \"hawk\", \"panda\", \"tiger\", \"koala\", \"owl\", \"whale\", \"lynx\", \"swift\"];\n\n      const generateUser = () => {\n        const adjective = adjectives[Math.floor(Math.random() * adjectives.length)];\n        const animal = animals[Math.floor(Math.random() * animals.length)];\n        return `${adjective}-${animal}`;\n      };\n\n      const getLocalUser = () => {\n        const stored = window.localStorage.getItem(\"sling_user\");\n        if (stored) {\n          return stored;\n        }\n        const generated = generateUser();\n        window.localStorage.setItem(\"sling_user\", generated);\n        return generated;\n      };\n\n      let localUser = getLocalUser();\n\n      if (!container || !slings) {\n        return;\n      }\n\n      const historySeq = Number(container.dataset.historySeq || 0);\n      let olderLoaded = false;\n      let loadingOlder = false;\n\n      // Slings up to historySeq were rendered with the page or are reachable\n      // through \"load older\", so the websocket replay skips them.\n      const isHistory = (element) => {\n        const seq = Number(element?.dataset?.seq || 0);\n        return seq > 0 && seq <= historySeq;\n      };\n\n      let isUserScrolling = false;\n      let lastScrollPosition = container.scrollTop;\n      let isGridMode = false;\n\n      const setGridMode = (enabled) => {\n        isGridMode = enabled;\n        container.classList.toggle(\"grid-mode\", enabled);\n        slings.classList.toggle(\"grid-mode\", enabled);\n        pinned?.classList.toggle(\"grid-mode\", enabled);\n        gridToggle.textContent = enabled ? \"Scroll view\" : \"Grid view\";\n        if (enabled) {\n          container.querySelectorAll(\".sling-pdf\").forEach((viewer) => showPdfPage(viewer, 1));\n        }\n      };\n\n      // Built-in PDF viewers jump to the page in the URL fragment, so paging\n      // only swaps the fragment of the frame.\n      const showPdfPage = (viewer, page) => {\n        const frame = viewer.querySelector(\".sling-pdf__frame\");\n        const current = viewer.querySelector(\"[data-pdf-current]\");\n        if (!frame || !current || current.textContent === String(page)) {\n          return;\n        }\n        current.textContent = String(page);\n        frame.src = viewer.dataset.pdfSrc + \"#page=\" + page + \"&toolbar=0&navpanes=0&view=FitH\";\n      };\n\n      const turnPdfPage = (button) => {\n        const viewer = button.closest(\".sling-pdf\");\n        const current = viewer?.querySelector(\"[data-pdf-current]\");\n        if (!viewer || !current) {\n          return;\n        }\n        showPdfPage(viewer, Math.max(1, Number(current.textContent) + Number(button.dataset.pdfPage)));\n      };\n\n      const trimSlings = () => {\n        if (olderLoaded) {\n          return;\n        }\n        const items = slings.querySelectorAll(\":scope > .sling\");\n        if (items.length <= 20) {\n          return;\n        }\n        for (let i = items.length - 1; i >= 20; i -= 1) {\n          items[i].remove();\n        }\n        document.getElementById(\"load-older\")?.removeAttribute(\"hidden\");\n      };\n\n      const oldestSeq = () => {\n        let oldest = 0;\n        slings.querySelectorAll(\":scope > .sling[data-seq]\").forEach((sling) => {\n          const seq = Number(sling.dataset.seq);\n          if (seq > 0 && (oldest === 0 || seq < oldest)) {\n            oldest = seq;\n          }\n        });\n        return oldest;\n      };\n\n      const loadOlder = async () => {\n        const board = container.dataset.boardName;\n        const before = oldestSeq();\n        if (!board || before === 0) {\n          return;\n        }\n\n        const response = await fetch(\"/board/\" + encodeURIComponent(board) + \"/history?before=\" + before);\n        if (!response.ok) {\n          return;\n        }\n        const parsed = document.createElement(\"template\");\n        parsed.innerHTML = await response.text();\n\n        const control = parsed.content.getElementById(\"slings-older\");\n        control?.remove();\n        if (control) {\n          document.getElementById(\"slings-older\")?.replaceWith(control);\n        }\n\n        olderLoaded = true;\n        loadingOlder = true;\n        Array.from(parsed.content.children).forEach((sling) => {\n          if (!sling.id || !document.getElementById(sling.id)) {\n            slings.append(sling);\n          }\n        });\n        formatTimestamps(slings);\n        updateReplyCounts(slings);\n        window.setTimeout(() => {\n          loadingOlder = false;\n        }, 0);\n      };\n\n      container.addEventListener(\"click\", (event) => {\n        if (event.target.closest(\"#load-older\")) {\n          loadOlder();\n        }\n      });\n\n      container.addEventListener(\"scroll\", () => {\n        isUserScrolling = Math.abs(container.scrollTop - lastScrollPosition) > 10;\n        lastScrollPosition = container.scrollTop;\n      });\n\n      gridToggle?.addEventListener(\"click\", () => {\n        setGridMode(!isGridMode);\n      });\n\n      const sendSlingCommand = async (type, id, extra = {}) => {\n        const board = container.dataset.boardName || \"\";\n        if (!board) {\n          return;\n        }\n        try {\n          const response = await fetch(\"/api/commands\", {\n            method: \"POST\",\n            headers: { \"Content-Type\": \"application/json\", Accept: \"application/json\" },\n            body: JSON.stringify({ type: type, board: board, id: id, content: \"\", author: localUser, ...extra }),\n          });\n          const data = await response.json().catch(() => ({}));\n          if (!response.ok || data.status === \"error\") {\n            throw new Error(data.message || \"Failed to update sling\");\n          }\n        } catch (error) {\n          window.alert(error.message || \"Failed to update sling\");\n        }\n      };\n\n      const handleSlingAction = (event) => {\n        const copyButton = event.target.closest(\"[data-sling-copy]\");\n        if (copyButton) {\n          event.stopPropagation();\n          const id = copyButton.dataset.slingCopy;\n          if (navigator.clipboard) {\n            navigator.clipboard.writeText(id).catch(() => window.prompt(\"Sling ID\", id));\n          } else {\n            window.prompt(\"Sling ID\", id);\n          }\n          return true;\n        }\n        const deleteButton = event.target.closest(\"[data-sling-delete]\");\n        if (deleteButton) {\n          event.stopPropagation();\n          if (window.confirm(\"Delete this sling for everyone?\")) {\n            sendSlingCommand(\"sling.delete\", deleteButton.dataset.slingDelete);\n          }\n          return true;\n        }\n        const pdfButton = event.target.closest(\"[data-pdf-page]\");\n        if (pdfButton) {\n          event.stopPropagation();\n          turnPdfPage(pdfButton);\n          return true;\n        }\n        const replyButton = event.target.closest(\"[data-sling-reply]\");\n        if (replyButton) {\n          event.stopPropagation();\n          openModal(replyButton.dataset.slingReply, replyButton.dataset.slingAuthor);\n          return true;\n        }\n        const reactButton = event.target.closest(\"[data-sling-react]\");\n        if (reactButton) {\n          event.stopPropagation();\n          const authors = (reactButton.dataset.reactionAuthors || \"\").split(\"\\n\");\n          const type = authors.includes(localUser) ? \"sling.unreact\" : \"sling.react\";\n          sendSlingCommand(type, reactButton.dataset.slingReact, { reaction: reactButton.dataset.reaction });\n          return true;\n        }\n        const pinButton = event.target.closest(\"[data-sling-pin]\");\n        if (pinButton) {\n          event.stopPropagation();\n          const isPinned = Boolean(pinButton.closest(\"#pinned\"));\n          sendSlingCommand(isPinned ? \"sling.unpin\" : \"sling.pin\", pinButton.dataset.slingPin);\n          return true;\n        }\n        return false;\n      };\n\n      pinned?.addEventListener(\"click\", handleSlingAction);\n\n      slings.addEventListener(\"click\", (event) => {\n        if (handleSlingAction(event)) {\n          return;\n        }\n        if (!isGridMode) {\n          return;\n        }\n        const target = event.target.closest(\"[data-sling-id]\");\n        if (!target) {\n          return;\n        }\n        setGridMode(false);\n        target.scrollIntoView({ behavior: \"smooth\", block: \"start\" });\n      });\n\n      // The websocket replays the slings published after this page was\n      // rendered, starting from the sequence passed along in a cookie.\n      document.cookie = \"slingboard_history_seq=\" + historySeq + \"; path=\" + window.location.pathname + \"; max-age=60; samesite=strict\";\n      const protocol = window.location.protocol === \"https:\" ? \"wss\" : \"ws\";\n      const ws = new WebSocket(protocol + \"://\" + window.location.host + window.location.pathname);\n\n      const setStatus = (text, isError = false) => {\n        if (!status) {\n          return;\n        }\n        status.textContent = text;\n        status.classList.toggle(\"is-error\", isError);\n      };\n\n      const setUserBadge = () => {\n        if (userName) {\n          userName.textContent = localUser;\n        }\n      };\n\n      let replyTo = \"\";\n\n      const openModal = (parentID = \"\", parentAuthor = \"\") => {\n        replyTo = parentID;\n        if (modalTitle) {\n          modalTitle.textContent = parentID ? \"Reply to \" + (parentAuthor || \"sling\") : \"Add sling\";\n        }\n        modal?.classList.add(\"is-open\");\n        modal?.setAttribute(\"aria-hidden\", \"false\");\n        setStatus(\"\");\n      };\n\n      const closeModal = () => {\n        modal?.classList.remove(\"is-open\");\n        modal?.setAttribute(\"aria-hidden\", \"true\");\n        setStatus(\"\");\n        if (messageInput) messageInput.value = \"\";\n        if (urlInput) urlInput.value = \"\";\n        if (fileInput) fileInput.value = \"\";\n        if (ttlInput) ttlInput.value = \"\";\n        replyTo = \"\";\n      };\n\n      const setActiveTab = (name) => {\n        activeTab = name;\n        tabs.forEach((tab) => tab.classList.toggle(\"is-active\", tab.dataset.tab === name));\n        panels.forEach((panel) => panel.classList.toggle(\"hidden\", panel.dataset.panel !== name));\n      };\n\n      const submitSling = async (event) => {\n        event.preventDefault();\n        const board = container.dataset.boardName || \"\";\n        if (!board) {\n          setStatus(\"Missing board name\", true);\n          return;\n        }\n\n        let payload = { type: activeTab, board: board, author: localUser, content: \"\" };\n\n        try {\n          if (activeTab === \"text\") {\n            const value = messageInput?.value.trim() || \"\";\n            if (!value) {\n              setStatus(\"Message is required\", true);\n              return;\n            }\n            payload.content = value;\n          } else if (activeTab === \"url\") {\n            const value = urlInput?.value.trim() || \"\";\n            if (!value) {\n              setStatus(\"URL is required\", true);\n              return;\n            }\n            payload.content = value;\n          }\n\n          if (ttlInput?.value) {\n            payload.ttl = ttlInput.value;\n          }\n          if (replyTo) {\n            payload.parent_id = replyTo;\n          }\n\n          // Files are posted as they are to the upload endpoint, with the\n          // other fields of the command as form fields.\n          let request = {\n            method: \"POST\",\n            headers: { \"Content-Type\": \"application/json\", Accept: \"application/json\" },\n            body: JSON.stringify(payload),\n          };\n          let endpoint = \"/api/commands\";\n          if (activeTab === \"file\") {\n            const file = fileInput?.files?.[0];\n            if (!file) {\n              setStatus(\"File is required\", true);\n              return;\n            }\n            const form = new FormData();\n            [\"board\", \"author\", \"ttl\", \"parent_id\"].forEach((name) => {\n              if (payload[name]) {\n                form.append(name, payload[name]);\n              }\n            });\n            form.append(\"file\", file);\n            request = { method: \"POST\", headers: { Accept: \"application/json\" }, body: form };\n            endpoint = \"/api/upload\";\n          }\n\n          setStatus(\"Sending...\");\n\n          const response = await fetch(endpoint, request);\n\n          const data = await response.json().catch(() => ({}));\n          if (!response.ok || data.status === \"error\") {\n            throw new Error(data.message || \"Failed to send sling\");\n          }\n\n          closeModal();\n        } catch (error) {\n          setStatus(error.message || \"Failed to send sling\", true);\n        }\n      };\n\n      setUserBadge();\n\n      addButton?.addEventListener(\"click\", () => openModal());\n      modalClose.forEach((button) => button.addEventListener(\"click\", closeModal));\n      tabs.forEach((tab) => tab.addEventListener(\"click\", () => setActiveTab(tab.dataset.tab)));\n      form?.addEventListener(\"submit\", submitSling);\n      userRegenerate?.addEventListener(\"click\", () => {\n        localUser = generateUser();\n        window.localStorage.setItem(\"sling_user\", localUser);\n        setUserBadge();\n      });\n      window.addEventListener(\"keydown\", (event) => {\n        if (event.key === \"Escape\") {\n          closeModal();\n        }\n      });\n\n      const updateReplyCounts = (root = document) => {\n        root.querySelectorAll(\".sling-replies\").forEach((thread) => {\n          const count = thread.querySelectorAll(\".sling-replies__list > .sling\").length;\n          const summary = thread.querySelector(\".sling-replies__summary\");\n          if (summary) {\n            summary.textContent = count === 1 ? \"1 reply\" : count + \" replies\";\n          }\n          thread.hidden = count === 0;\n        });\n      };\n\n      const formatTimestamps = (root = document) => {\n        const timestamps = root.querySelectorAll(\"[data-timestamp]\");\n        timestamps.forEach((element) => {\n          const value = element.dataset.timestamp;\n          if (!value) {\n            return;\n          }\n          const date = new Date(value);\n          if (Number.isNaN(date.getTime())) {\n            element.textContent = value;\n            return;\n          }\n          element.textContent = date.toLocaleTimeString([], { hour: \"2-digit\", minute: \"2-digit\" });\n          if (element.dataset.editedAt) {\n            element.textContent += \" · edited\";\n          }\n          const expires = new Date(element.dataset.expiresAt || \"\");\n          if (!Number.isNaN(expires.getTime())) {\n            element.textContent += \" · until \" + expires.toLocaleTimeString([], { hour: \"2-digit\", minute: \"2-digit\" });\n          }\n        });\n      };\n\n      const removeExpiredSlings = () => {\n        const now = Date.now();\n        container.querySelectorAll(\".sling[data-expires-at]\").forEach((sling) => {\n          const expires = new Date(sling.dataset.expiresAt || \"\").getTime();\n          if (!Number.isNaN(expires) && expires <= now) {\n            sling.remove();\n          }\n        });\n      };\n\n      window.setInterval(removeExpiredSlings, 1000);\n\n      const focusSling = (sling) => {\n        if (!sling) {\n          return;\n        }\n        formatTimestamps(sling);\n        sling.classList.add(\"sling--focus\");\n        window.setTimeout(() => sling.classList.remove(\"sling--focus\"), 2000);\n        sling.scrollIntoView({ behavior: \"smooth\", block: \"start\" });\n      };\n\n      const focusNewestSling = () => {\n        if (isGridMode) {\n          return;\n        }\n        const placeholder = slings.querySelector(\"#sling-placeholder\");\n        placeholder?.remove();\n        const firstSling = slings.querySelector(\".sling\");\n        if (!firstSling) {\n          return;\n        }\n        focusSling(firstSling);\n        trimSlings();\n      };\n\n      let focusPending = false;\n      const scheduleFocusNewestSling = () => {\n        if (isGridMode || focusPending) {\n          return;\n        }\n        focusPending = true;\n        window.requestAnimationFrame(() => {\n          focusPending = false;\n          focusNewestSling();\n        });\n      };\n\n      // Mermaid fences arrive as text and are drawn once they are on the\n      // page. The mermaid script is only loaded by boards that have one;\n      // diagrams not drawn, e.g. when the script fails to load, show their\n      // source.\n      let mermaidScript = null;\n      let mermaidFailed = false;\n      const renderDiagrams = () => {\n        const nodes = container.querySelectorAll(\"pre.mermaid:not([data-processed])\");\n        if (nodes.length === 0 || mermaidFailed) {\n          return;\n        }\n        if (window.mermaid) {\n          window.mermaid.run({ nodes }).catch(() => {});\n          return;\n        }\n        if (!mermaidScript) {\n          mermaidScript = document.createElement(\"script\");\n          mermaidScript.src = \"/static/mermaid.min.js\";\n          mermaidScript.addEventListener(\"load\", () => {\n            window.mermaid?.initialize({ startOnLoad: false, securityLevel: \"strict\", theme: \"dark\" });\n            renderDiagrams();\n          });\n          mermaidScript.addEventListener(\"error\", () => {\n            mermaidFailed = true;\n            console.warn(\"mermaid could not be loaded, diagrams show their source\");\n          });\n          document.head.appendChild(mermaidScript);\n        }\n      };\n\n      const slingObserver = new MutationObserver((mutations) => {\n        if (mutations.some((mutation) => mutation.addedNodes.length > 0)) {\n          renderDiagrams();\n        }\n        // Replies land inside a thread and should not pull focus to the top.\n        const hasNewSling = mutations.some((mutation) => mutation.target === slings && mutation.addedNodes.length > 0);\n        if (hasNewSling && !loadingOlder) {\n          scheduleFocusNewestSling();\n        }\n      });\n\n      slingObserver.observe(slings, { childList: true, subtree: true });\n      renderDiagrams();\n      formatTimestamps(container);\n      updateReplyCounts(container);\n\n      const patchElements = (argsRaw) => {\n        document.dispatchEvent(\n          new CustomEvent(\"datastar-fetch\", {\n            detail: {\n              type: \"datastar-patch-elements\",\n              argsRaw: argsRaw,\n            },\n          }),\n        );\n      };\n\n      ws.addEventListener(\"message\", (event) => {\n        const html = event.data;\n\n        // Fragments wrapped in <template data-patch-mode> carry their own\n        // patch instructions; everything else is a new sling.\n        const parsed = document.createElement(\"template\");\n        parsed.innerHTML = html;\n        const first = parsed.content.firstElementChild;\n        if (first?.tagName === \"TEMPLATE\" && first.dataset.patchMode) {\n          parsed.content.querySelectorAll(\":scope > template[data-patch-mode]\").forEach((patch) => {\n            const element = patch.content.firstElementChild;\n            if (isHistory(element)) {\n              return;\n            }\n            // Replayed replies may already be in their thread.\n            if (patch.dataset.patchMode === \"append\" && element?.id && document.getElementById(element.id)) {\n              return;\n            }\n            const argsRaw = { mode: patch.dataset.patchMode, elements: patch.innerHTML };\n            if (patch.dataset.patchSelector) {\n              argsRaw.selector = patch.dataset.patchSelector;\n            }\n            patchElements(argsRaw);\n          });\n          window.requestAnimationFrame(() => {\n            formatTimestamps(container);\n            updateReplyCounts(container);\n          });\n          return;\n        }\n\n        // Replayed slings may already be on screen, e.g. pinned ones.\n        if (isHistory(first) || (first?.id && document.getElementById(first.id))) {\n          return;\n        }\n\n        patchElements({\n          selector: \"#slings\",\n          mode: \"prepend\",\n          elements: html,\n        });\n\n        scheduleFocusNewestSling();\n      });\n\n    });\n  </script></body></html>")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}